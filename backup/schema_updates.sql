@@ -0,0 +1,255 @@
+-- Schema changes applied on top of the dump in this folder, run in order.
+
+-- products keep the option ids selected for each attribute
+ALTER TABLE `products` ADD COLUMN `Fields` json NULL;
+
+-- variants (SKUs) of one product listing
+CREATE TABLE `productvariants` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `ProductId` int unsigned NOT NULL,
+  `Sku` varchar(64) NOT NULL,
+  `Price` bigint NOT NULL,
+  `Stock` int unsigned NOT NULL,
+  `Options` json NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `productvariants_sku_uk` (`Sku`),
+  KEY `productvariants_product_fk` (`ProductId`),
+  CONSTRAINT `productvariants_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- prices in minor units with ISO 4217 currency
+ALTER TABLE `products`
+  ADD COLUMN `PriceAmount` bigint NOT NULL DEFAULT 0,
+  ADD COLUMN `PriceCurrency` char(3) NOT NULL DEFAULT 'USD',
+  ADD KEY `products_price_idx` (`PriceCurrency`, `PriceAmount`);
+ALTER TABLE `productvariants`
+  CHANGE COLUMN `Price` `PriceAmount` bigint NOT NULL,
+  ADD COLUMN `PriceCurrency` char(3) NOT NULL DEFAULT 'USD';
+
+-- inventory: stock per product (ProductVariantId = 0) or per variant,
+-- reservations held by checkouts and the append only movement ledger
+ALTER TABLE `productvariants` DROP COLUMN `Stock`;
+CREATE TABLE `stocklevels` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `ProductId` int unsigned NOT NULL,
+  `ProductVariantId` int unsigned NOT NULL DEFAULT 0,
+  `OnHand` int unsigned NOT NULL,
+  `Reserved` int unsigned NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `stocklevels_product_variant_uk` (`ProductId`, `ProductVariantId`),
+  CONSTRAINT `stocklevels_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE `stockreservations` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `StockLevelId` int unsigned NOT NULL,
+  `Quantity` int unsigned NOT NULL,
+  `Status` varchar(16) NOT NULL,
+  `ExpiresAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `stockreservations_status_expires_idx` (`Status`, `ExpiresAt`),
+  CONSTRAINT `stockreservations_level_fk` FOREIGN KEY (`StockLevelId`) REFERENCES `stocklevels` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE `stockmovements` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `StockLevelId` int unsigned NOT NULL,
+  `Kind` varchar(16) NOT NULL,
+  `Quantity` int unsigned NOT NULL,
+  `ReservationId` int unsigned NOT NULL DEFAULT 0,
+  `CreatedAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `stockmovements_level_fk` (`StockLevelId`),
+  CONSTRAINT `stockmovements_level_fk` FOREIGN KEY (`StockLevelId`) REFERENCES `stocklevels` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- carts of users (UserId) or anonymous sessions (SessionKey, UserId = 0)
+CREATE TABLE `carts` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `UserId` int unsigned NOT NULL DEFAULT 0,
+  `SessionKey` varchar(64) NOT NULL DEFAULT '',
+  `UpdatedAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `carts_user_idx` (`UserId`),
+  KEY `carts_session_idx` (`SessionKey`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE `cartitems` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `CartId` int unsigned NOT NULL,
+  `ProductId` int unsigned NOT NULL,
+  `ProductVariantId` int unsigned NOT NULL DEFAULT 0,
+  `Quantity` int unsigned NOT NULL,
+  `PriceAmount` bigint NOT NULL,
+  `PriceCurrency` char(3) NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `cartitems_cart_fk` (`CartId`),
+  CONSTRAINT `cartitems_cart_fk` FOREIGN KEY (`CartId`) REFERENCES `carts` (`Id`),
+  CONSTRAINT `cartitems_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- orders created by checkout, totals in minor units
+CREATE TABLE `orders` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `UserId` int unsigned NOT NULL,
+  `Status` varchar(16) NOT NULL,
+  `TotalAmount` bigint NOT NULL,
+  `Currency` char(3) NOT NULL,
+  `CreatedAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `orders_user_idx` (`UserId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE `orderlines` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `OrderId` int unsigned NOT NULL,
+  `ProductId` int unsigned NOT NULL,
+  `ProductVariantId` int unsigned NOT NULL DEFAULT 0,
+  `Quantity` int unsigned NOT NULL,
+  `UnitPriceAmount` bigint NOT NULL,
+  `LineTotalAmount` bigint NOT NULL,
+  `Currency` char(3) NOT NULL,
+  `ReservationId` int unsigned NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `orderlines_order_fk` (`OrderId`),
+  CONSTRAINT `orderlines_order_fk` FOREIGN KEY (`OrderId`) REFERENCES `orders` (`Id`),
+  CONSTRAINT `orderlines_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- payments through providers and the provider events already handled
+CREATE TABLE `payments` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `OrderId` int unsigned NOT NULL,
+  `Provider` varchar(32) NOT NULL,
+  `ProviderRef` varchar(128) NOT NULL,
+  `Amount` bigint NOT NULL,
+  `Currency` char(3) NOT NULL,
+  `Status` varchar(16) NOT NULL,
+  `UpdatedAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `payments_provider_ref_uk` (`Provider`, `ProviderRef`),
+  CONSTRAINT `payments_order_fk` FOREIGN KEY (`OrderId`) REFERENCES `orders` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+CREATE TABLE `paymentevents` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `Provider` varchar(32) NOT NULL,
+  `EventId` varchar(128) NOT NULL,
+  `Type` varchar(32) NOT NULL,
+  `ProcessedAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `paymentevents_provider_event_uk` (`Provider`, `EventId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- stored responses of Idempotency-Key requests, replayed on retries
+CREATE TABLE `idempotencykeys` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `IdemKey` varchar(128) NOT NULL,
+  `Route` varchar(128) NOT NULL,
+  `RequestHash` char(64) NOT NULL,
+  `StatusCode` int NOT NULL DEFAULT 0,
+  `ResponseBody` mediumtext NULL,
+  `ExpiresAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `idempotencykeys_key_route_uk` (`IdemKey`, `Route`),
+  KEY `idempotencykeys_expires_idx` (`ExpiresAt`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- reviews of completed orders and rating aggregates of products and sellers
+ALTER TABLE `products`
+  ADD COLUMN `SellerId` int unsigned NOT NULL DEFAULT 0,
+  ADD COLUMN `Rating` json NULL,
+  ADD KEY `products_seller_idx` (`SellerId`);
+
+CREATE TABLE `reviews` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `UserId` int unsigned NOT NULL,
+  `SellerId` int unsigned NOT NULL DEFAULT 0,
+  `Rating` tinyint unsigned NOT NULL,
+  `Comment` varchar(2000) NOT NULL DEFAULT '',
+  `CreatedAt` datetime NOT NULL,
+  `OrderId` int unsigned NOT NULL,
+  `ProductId` int unsigned NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `reviews_order_product_uk` (`OrderId`, `ProductId`),
+  KEY `reviews_product_idx` (`ProductId`, `Id`),
+  CONSTRAINT `reviews_order_fk` FOREIGN KEY (`OrderId`) REFERENCES `orders` (`Id`),
+  CONSTRAINT `reviews_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `sellerratings` (
+  `Id` int unsigned NOT NULL,
+  `Rating` json NOT NULL,
+  PRIMARY KEY (`Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- watchlists, saved searches and the notifications queued by the saved search matcher
+CREATE TABLE `watchlistitems` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `UserId` int unsigned NOT NULL,
+  `CreatedAt` datetime NOT NULL,
+  `ProductId` int unsigned NOT NULL,
+  PRIMARY KEY (`Id`),
+  UNIQUE KEY `watchlistitems_user_product_uk` (`UserId`, `ProductId`),
+  CONSTRAINT `watchlistitems_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `savedsearchs` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `UserId` int unsigned NOT NULL,
+  `Name` varchar(80) NOT NULL,
+  `Filter` json NULL,
+  `CreatedAt` datetime NOT NULL,
+  `ProductTypeId` int unsigned NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `savedsearchs_user_idx` (`UserId`),
+  KEY `savedsearchs_product_type_idx` (`ProductTypeId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- one notification per saved search and product, so a product matched twice is not notified twice
+CREATE TABLE `notifications` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `UserId` int unsigned NOT NULL,
+  `Kind` varchar(32) NOT NULL,
+  `Message` varchar(255) NOT NULL,
+  `SavedSearchId` int unsigned NOT NULL DEFAULT 0,
+  `IsRead` tinyint(1) NOT NULL DEFAULT 0,
+  `CreatedAt` datetime NOT NULL,
+  `ProductId` int unsigned NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `notifications_user_idx` (`UserId`, `IsRead`, `Id`),
+  UNIQUE KEY `notifications_search_product_uk` (`SavedSearchId`, `ProductId`),
+  CONSTRAINT `notifications_product_fk` FOREIGN KEY (`ProductId`) REFERENCES `products` (`Id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- optional, only needed with infrastructure.SearchMysqlFullTextFallback
+ALTER TABLE `products` ADD FULLTEXT KEY `products_name_fulltext` (`Name`);
+
+-- creation and update times filled by the repo, products and variants are soft deleted so orders keep their rows
+ALTER TABLE `products`
+  ADD `CreatedAt` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD `UpdatedAt` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD `DeletedAt` datetime NULL,
+  ADD KEY `products_deleted_idx` (`DeletedAt`);
+ALTER TABLE `productvariants`
+  ADD `CreatedAt` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD `UpdatedAt` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD `DeletedAt` datetime NULL,
+  ADD KEY `productvariants_deleted_idx` (`DeletedAt`);
+-- a soft deleted variant frees its sku, Alive is NULL once deleted and a unique key never matches NULLs
+ALTER TABLE `productvariants`
+  ADD `Alive` tinyint GENERATED ALWAYS AS (IF(`DeletedAt` IS NULL, 1, NULL)) VIRTUAL,
+  DROP KEY `productvariants_sku_uk`,
+  ADD UNIQUE KEY `productvariants_sku_uk` (`Sku`, `Alive`);
+ALTER TABLE `producttypes`
+  ADD `CreatedAt` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD `UpdatedAt` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD `CreatedBy` int unsigned NOT NULL DEFAULT 0;
+
+-- audit log of the audited schemas, written in the transaction of each change
+CREATE TABLE `auditlogs` (
+  `Id` int unsigned NOT NULL AUTO_INCREMENT,
+  `Entity` varchar(64) NOT NULL,
+  `EntityId` int unsigned NOT NULL,
+  `ActorId` int unsigned NOT NULL DEFAULT 0,
+  `Action` varchar(16) NOT NULL,
+  `Changes` json NOT NULL,
+  `CreatedAt` datetime NOT NULL,
+  PRIMARY KEY (`Id`),
+  KEY `auditlogs_entity_idx` (`Entity`, `EntityId`, `Id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyUserToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	valid := SignUserToken(secret, 42, now.Add(time.Hour))
+	tests := []struct {
+		name    string
+		secret  []byte
+		token   string
+		want    uint32
+		wantErr error
+	}{
+		{name: "valid", secret: secret, token: valid, want: 42},
+		{name: "other secret", secret: []byte("other"), token: valid, wantErr: ErrInvalidToken},
+		{name: "no secret", token: valid, wantErr: ErrNoSecret},
+		{name: "expired", secret: secret, token: SignUserToken(secret, 42, now), wantErr: ErrExpiredToken},
+		{name: "user changed", secret: secret, token: "43" + valid[2:], wantErr: ErrInvalidToken},
+		{name: "anonymous user", secret: secret, token: SignUserToken(secret, 0, now.Add(time.Hour)), wantErr: ErrInvalidToken},
+		{name: "raw user id", secret: secret, token: "42", wantErr: ErrInvalidToken},
+		{name: "empty", secret: secret, token: "", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyUserToken(tt.secret, tt.token, now)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("VerifyUserToken() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
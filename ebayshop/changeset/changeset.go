@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -22,6 +23,7 @@ type Schema interface {
 	Validators() map[string]*Box
 }
 
+// FieldOp is a bit of the ops of a Box, at most 32 of them fit
 type FieldOp uint8
 
 const (
@@ -29,25 +31,99 @@ const (
 	Nullable
 	NotNullable
 	JSONOp
+	// the repo fills the fields of these ops, a value cast explicitly is kept
+	CreatedAtOp
+	UpdatedAtOp
+	DeletedAtOp
+	CreatedByOp
+	// NotAuditedOp leaves the field out of the audit log, like a counter every write bumps
+	NotAuditedOp
 )
 
+// AuditOps are the ops of fields filled by the repo
+var AuditOps = []FieldOp{CreatedAtOp, UpdatedAtOp, DeletedAtOp, CreatedByOp}
+
 type Box struct {
 	id             uint32
-	ops            uint8
+	ops            uint32
 	size           int
 	val            interface{}
 	UpdatedCol     string
 	RelTbName      string
 	dateTimeFormat string
+	hasRange       bool
+	min            int64
+	max            int64
+	allowed        map[string]bool
+}
+
+// Range limits an integer field to [min, max], checked by ValidValues
+func (b *Box) Range(min int64, max int64) *Box {
+	b.hasRange = true
+	b.min = min
+	b.max = max
+	return b
+}
+
+// OneOf limits a string field to the given values, checked by ValidValues
+func (b *Box) OneOf(values ...string) *Box {
+	b.allowed = make(map[string]bool, len(values))
+	for _, value := range values {
+		b.allowed[value] = true
+	}
+	return b
+}
+
+func (b *Box) checkValue(col string) error {
+	if b.val == nil {
+		return nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(b.val))
+	if b.hasRange {
+		var n int64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.Uint() > uint64(b.max) {
+				return fmt.Errorf("field %v out of range [%v, %v]", col, b.min, b.max)
+			}
+			n = int64(rv.Uint())
+		default:
+			return fmt.Errorf("field %v is not a number", col)
+		}
+		if n < b.min || n > b.max {
+			return fmt.Errorf("field %v out of range [%v, %v]", col, b.min, b.max)
+		}
+	}
+	if b.allowed != nil {
+		if rv.Kind() != reflect.String || !b.allowed[rv.String()] {
+			return fmt.Errorf("field %v has value not allowed [%v]", col, b.val)
+		}
+	}
+	return nil
 }
 
 func (b *Box) DateTimeFormat(format string) *Box {
 	b.dateTimeFormat = format
 	return b
 }
-func (b *Box) GetOps() uint8 {
+func (b *Box) GetOps() uint32 {
 	return b.ops
 }
+
+func (b *Box) HasOp(op FieldOp) bool {
+	return b.ops&(1<<op) != 0
+}
+
+func (b *Box) isAudit() bool {
+	for _, op := range AuditOps {
+		if b.HasOp(op) {
+			return true
+		}
+	}
+	return false
+}
 func (b *Box) SetEmbeddedClass(class Schema, colUpdate ...string) *Box {
 	b.val = class
 	if len(colUpdate) > 0 {
@@ -204,7 +280,8 @@ func CastValues(schema Schema, values map[string]interface{}) *ChangeSet {
 	}
 	var id uint32
 	for col, box := range cs.Boxes {
-		if box.ops&(1<<NotNullable) != 0 {
+		// audit fields are filled by the repo, a changeset is valid without them
+		if box.ops&(1<<NotNullable) != 0 && !box.isAudit() {
 			cs.NotNullFields |= 1 << id
 			box.id = id
 		}
@@ -219,11 +296,7 @@ func CastValues(schema Schema, values map[string]interface{}) *ChangeSet {
 	}
 	for col, value := range values {
 		if _, ok := cs.Boxes[col]; ok {
-			if cs.NotNullFields&(1<<cs.Boxes[col].id) != 0 {
-				if value != "" || value != 0 || value != nil || (cs.Boxes[col].ops&(1<<AI)) != 0 {
-					cs.NotNullFields &= ^(1 << cs.Boxes[col].id)
-				}
-			}
+			cs.given(col, value)
 
 			if value != nil && reflect.TypeOf(value).Kind() == reflect.String {
 				if len(value.(string)) <= cs.Boxes[col].size {
@@ -242,27 +315,66 @@ func CastValues(schema Schema, values map[string]interface{}) *ChangeSet {
 				continue
 			}
 
-			if value != "" || value != 0 {
-				cs.CastedBoxes = append(cs.CastedBoxes, col)
-			}
+			// values passed explicitly are always written, even zero ones
+			cs.CastedBoxes = append(cs.CastedBoxes, col)
 		}
 	}
 	cs.ReflectSchema = rschema
 	return cs
 }
 
+// given marks a not null field as set by value, a zero value like 0 or "" is a value,
+// nil is not
+func (cs *ChangeSet) given(col string, value interface{}) {
+	if !cs.notNull(col) || isNilValue(value) {
+		return
+	}
+	cs.NotNullFields &= ^(1 << cs.Boxes[col].id)
+}
+
+// notNull tells if col is a not null field an insert has to give, ids of the other boxes
+// are not bits of NotNullFields
+func (cs *ChangeSet) notNull(col string) bool {
+	box := cs.Boxes[col]
+	return box.ops&(1<<NotNullable) != 0 && !box.isAudit()
+}
+
+func isNilValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// ValidInsert tells if every not null field was given, the repo refuses to insert otherwise
 func (cs *ChangeSet) ValidInsert() bool {
 	return cs.NotNullFields == 0
 }
 
+// ValidValues checks casted fields against Range and OneOf of their boxes
+func (cs *ChangeSet) ValidValues() error {
+	for _, col := range cs.CastedBoxes {
+		if err := cs.Boxes[col].checkValue(col); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (cs *ChangeSet) NotNullErrors() error {
 	errs := fmt.Sprintf("Required Fields aren't Nullable (")
 	errFields := []string{}
 	for col, box := range cs.Boxes {
-		if (cs.NotNullFields & (1 << box.id)) != 0 {
+		if cs.notNull(col) && (cs.NotNullFields&(1<<box.id)) != 0 {
 			errFields = append(errFields, col)
 		}
 	}
+	sort.Strings(errFields)
 
 	for i, errField := range errFields {
 		errs += errField
@@ -301,11 +413,7 @@ func (cs *ChangeSet) AppendCastValue(interfaceSchema interface{}, values map[str
 	rschema := reflect.Indirect(reflect.ValueOf(interfaceSchema))
 	for col, value := range values {
 		if _, ok := cs.Boxes[col]; ok {
-			if cs.NotNullFields&(1<<cs.Boxes[col].id) != 0 {
-				if value != "" || value != 0 || value != nil || (cs.Boxes[col].ops&(1<<AI)) != 0 {
-					cs.NotNullFields &= ^(1 << cs.Boxes[col].id)
-				}
-			}
+			cs.given(col, value)
 
 			if reflect.TypeOf(value).Kind() == reflect.String {
 				if len(value.(string)) <= cs.Boxes[col].size {
@@ -323,9 +431,33 @@ func (cs *ChangeSet) AppendCastValue(interfaceSchema interface{}, values map[str
 				continue
 			}
 
-			if value != "" || value != 0 {
-				cs.CastedBoxes = append(cs.CastedBoxes, col)
-			}
+			// values passed explicitly are always written, even zero ones
+			cs.CastedBoxes = append(cs.CastedBoxes, col)
 		}
 	}
 }
+
+// Stamp casts a field the repo fills, a field already casted keeps its value.
+// A value is set through a pointer when the field is one, like a nullable DeletedAt
+func (cs *ChangeSet) Stamp(col string, value interface{}) bool {
+	box, ok := cs.Boxes[col]
+	if !ok {
+		return false
+	}
+	for _, casted := range cs.CastedBoxes {
+		if casted == col {
+			return false
+		}
+	}
+	box.Val(value)
+	field := cs.ReflectSchema.FieldByName(col)
+	rv := reflect.ValueOf(value)
+	if field.Kind() == reflect.Ptr && rv.Kind() != reflect.Ptr {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr
+	}
+	field.Set(rv)
+	cs.CastedBoxes = append(cs.CastedBoxes, col)
+	return true
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"ebayclone/dto/audit_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type AdminController struct {
+	service *service.AuditService
+	group   *gin.RouterGroup
+}
+
+func (c *AdminController) ListAudit() {
+	c.group.GET("/audit", func(context *gin.Context) {
+		var dto audit_dto.AuditListReq
+		if err := context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ListAudit(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitAdminController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &AdminController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewAuditService(debug),
+	}
+	c.ListAudit()
+}
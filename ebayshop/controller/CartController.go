@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"ebayclone/dto/cart_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type CartController struct {
+	service *service.CartService
+	group   *gin.RouterGroup
+}
+
+func (c *CartController) GetCart() {
+	c.group.GET("", func(context *gin.Context) {
+		base_response := c.service.GetCart(context, cartIdentityOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *CartController) AddItem() {
+	c.group.POST("/add", func(context *gin.Context) {
+		var dto cart_dto.CartAddItemReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.AddItem(context, cartIdentityOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *CartController) RemoveItem() {
+	c.group.POST("/remove", func(context *gin.Context) {
+		var dto cart_dto.CartRemoveItemReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.RemoveItem(context, cartIdentityOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *CartController) UpdateQuantity() {
+	c.group.POST("/update_quantity", func(context *gin.Context) {
+		var dto cart_dto.CartUpdateQuantityReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.UpdateQuantity(context, cartIdentityOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *CartController) Checkout() {
+	c.group.POST("/checkout", func(context *gin.Context) {
+		base_response := c.service.Checkout(context, cartIdentityOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitCartController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &CartController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewCartService(debug),
+	}
+	c.GetCart()
+	c.AddItem()
+	c.RemoveItem()
+	c.UpdateQuantity()
+	c.Checkout()
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"ebayclone/dto/inventory_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type InventoryController struct {
+	service *service.InventoryService
+	group   *gin.RouterGroup
+}
+
+func (c *InventoryController) ReceiveStock() {
+	c.group.POST("/receive", func(context *gin.Context) {
+		var dto inventory_dto.StockChangeReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ReceiveStock(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *InventoryController) ReturnStock() {
+	c.group.POST("/return", func(context *gin.Context) {
+		var dto inventory_dto.StockChangeReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ReturnStock(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *InventoryController) ReserveStock() {
+	c.group.POST("/reserve", func(context *gin.Context) {
+		var dto inventory_dto.StockReserveReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ReserveStock(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *InventoryController) ReleaseReservation() {
+	c.group.POST("/release", func(context *gin.Context) {
+		var dto inventory_dto.ReservationReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ReleaseReservation(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *InventoryController) SellReservation() {
+	c.group.POST("/sell", func(context *gin.Context) {
+		var dto inventory_dto.ReservationReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.SellReservation(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *InventoryController) GetMovements() {
+	c.group.GET("/movements/:stock_level_id", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("stock_level_id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.GetMovements(context, userIdOf(context), uint32(id))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitInventoryController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &InventoryController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewInventoryService(debug),
+	}
+	c.ReceiveStock()
+	c.ReturnStock()
+	c.ReserveStock()
+	c.ReleaseReservation()
+	c.SellReservation()
+	c.GetMovements()
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"ebayclone/dto/notification_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type NotificationController struct {
+	service *service.NotificationService
+	group   *gin.RouterGroup
+}
+
+func (c *NotificationController) ListNotifications() {
+	c.group.GET("", func(context *gin.Context) {
+		var dto notification_dto.NotificationListReq
+		if err := context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ListNotifications(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *NotificationController) MarkRead() {
+	c.group.POST("/read", func(context *gin.Context) {
+		var dto notification_dto.NotificationReadReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.MarkRead(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitNotificationController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &NotificationController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewNotificationService(debug),
+	}
+	c.ListNotifications()
+	c.MarkRead()
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"ebayclone/dto/review_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type OrderController struct {
+	service       *service.OrderService
+	reviewService *service.ReviewService
+	group         *gin.RouterGroup
+}
+
+func (c *OrderController) GetOrderById() {
+	c.group.GET("/:id", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.GetOrderById(context, uint32(id), userIdOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *OrderController) CompleteOrder() {
+	c.group.POST("/:id/complete", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.CompleteOrder(context, uint32(id), userIdOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *OrderController) CreateReview() {
+	c.group.POST("/:id/review", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		var dto review_dto.ReviewCreateReq
+		if err = context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.reviewService.CreateReview(context, uint32(id), userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitOrderController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &OrderController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewOrderService(debug),
+
+		reviewService: service.NewReviewService(debug),
+	}
+	c.GetOrderById()
+	c.CompleteOrder()
+	c.CreateReview()
+}
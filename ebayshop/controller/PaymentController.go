@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"ebayclone/dto/payment_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const (
+	HeaderPaymentProvider  = "X-Payment-Provider"
+	HeaderPaymentSignature = "X-Payment-Signature"
+)
+
+type PaymentController struct {
+	service *service.PaymentService
+	group   *gin.RouterGroup
+}
+
+func (c *PaymentController) Pay() {
+	c.group.POST("/pay", func(context *gin.Context) {
+		var dto payment_dto.PaymentPayReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.Pay(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *PaymentController) Webhook() {
+	c.group.POST("/webhook", func(context *gin.Context) {
+		// the signature covers the exact bytes, so the body is not decoded before verification
+		payload, err := context.GetRawData()
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.HandleWebhook(context, context.GetHeader(HeaderPaymentProvider), payload, context.GetHeader(HeaderPaymentSignature))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitPaymentController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &PaymentController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewPaymentService(debug),
+	}
+	c.Pay()
+	c.Webhook()
+}
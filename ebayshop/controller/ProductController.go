@@ -2,36 +2,137 @@ package controller
 
 import (
 	"ebayclone/dto/product"
+	"ebayclone/dto/review_dto"
+	"ebayclone/middleware"
 	"ebayclone/service"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 )
 
 type ProductController struct {
-	service *service.ProductService
-	group   *gin.RouterGroup
+	service       *service.ProductService
+	reviewService *service.ReviewService
+	searchService *service.SearchService
+	group         *gin.RouterGroup
+
+	idempotency gin.HandlerFunc
 }
 
 func (c *ProductController) CreateProduct() {
-	c.group.POST("/create", func(context *gin.Context) {
+	c.group.POST("/create", c.idempotency, func(context *gin.Context) {
 		var dto product.ProductCreateReq
 		err := context.ShouldBindJSON(&dto)
 		if err != nil {
 			fmt.Println(err)
 			context.JSON(http.StatusBadRequest, "wrong format")
 		} else {
+			dto.SellerId = userIdOf(context)
 			base_response := c.service.CreateProduct(context, &dto)
 			context.JSON(base_response.StatusCode, base_response)
 		}
 	})
 }
 
+func (c *ProductController) ListProducts() {
+	c.group.GET("/list", func(context *gin.Context) {
+		var dto product.ProductListReq
+		if err := context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.ListProducts(context, &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *ProductController) GetProductById() {
+	c.group.GET("/:id", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.GetProductById(context, uint32(id))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *ProductController) SearchProducts() {
+	c.group.GET("/search", func(context *gin.Context) {
+		var dto product.ProductSearchReq
+		if err := context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.searchService.SearchProducts(context, &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *ProductController) UpdateProduct() {
+	c.group.POST("/:id/update", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		var dto product.ProductUpdateReq
+		if err = context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.UpdateProduct(context, uint32(id), userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *ProductController) DeleteProduct() {
+	c.group.POST("/:id/delete", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.DeleteProduct(context, uint32(id), userIdOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *ProductController) ListProductReviews() {
+	c.group.GET("/:id/reviews", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		var dto review_dto.ReviewListReq
+		if err = context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.reviewService.ListProductReviews(context, uint32(id), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
 func InitProductController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
 	p := &ProductController{
 		group:   parentGroup.Group(prefixRootApi),
 		service: service.NewProductServiceManager(debug),
+
+		reviewService: service.NewReviewService(debug),
+		searchService: service.NewSearchService(debug),
+
+		idempotency: middleware.Idempotency(service.NewIdempotencyService(debug)),
 	}
 
 	p.CreateProduct()
+	p.ListProducts()
+	p.GetProductById()
+	p.ListProductReviews()
+	p.SearchProducts()
+	p.UpdateProduct()
+	p.DeleteProduct()
 }
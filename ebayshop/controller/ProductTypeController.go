@@ -3,18 +3,22 @@ package controller
 import (
 	dto2 "ebayclone/dto"
 	"ebayclone/dto/product_type_dto"
+	"ebayclone/middleware"
 	"ebayclone/service"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strconv"
 )
 
 type ProductTypeController struct {
 	service *service.ProductTypeService
 	group   *gin.RouterGroup
+
+	idempotency gin.HandlerFunc
 }
 
 func (c *ProductTypeController) CreateProductType() {
-	c.group.POST("/create", func(context *gin.Context) {
+	c.group.POST("/create", c.idempotency, func(context *gin.Context) {
 		var dto product_type_dto.ProductTypeCreateReq
 		err := context.ShouldBindJSON(&dto)
 		if err != nil {
@@ -38,12 +42,32 @@ func (c *ProductTypeController) GetAllProductType() {
 	})
 }
 
+func (c *ProductTypeController) GetProductTypeProducts() {
+	c.group.GET("/:id/products", func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 32)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		var dto product_type_dto.ProductTypeProductsReq
+		if err = context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.GetProductTypeProducts(context, uint32(id), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
 func InitProductTypeController(parentGroup *gin.RouterGroup, rootApiPathResource string, debug bool) {
 	productTypeObjectController := &ProductTypeController{
 		service: service.NewProductTypeService(debug),
 		group:   parentGroup.Group(rootApiPathResource),
+
+		idempotency: middleware.Idempotency(service.NewIdempotencyService(debug)),
 	}
 	productTypeObjectController.CreateProductType()
 	productTypeObjectController.UpdateProductType()
 	productTypeObjectController.GetAllProductType()
+	productTypeObjectController.GetProductTypeProducts()
 }
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"ebayclone/dto/seller_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type SellerController struct {
+	service *service.SellerService
+	group   *gin.RouterGroup
+}
+
+func (c *SellerController) GetSalesReport() {
+	c.group.GET("/dashboard/sales", func(context *gin.Context) {
+		var dto seller_dto.SalesReportReq
+		if err := context.ShouldBindQuery(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.GetSalesReport(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitSellerController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &SellerController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewSellerService(debug),
+	}
+	c.GetSalesReport()
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"ebayclone/dto/watchlist_dto"
+	"ebayclone/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type WatchlistController struct {
+	service *service.WatchlistService
+	group   *gin.RouterGroup
+}
+
+func (c *WatchlistController) GetWatchlist() {
+	c.group.GET("", func(context *gin.Context) {
+		base_response := c.service.GetWatchlist(context, userIdOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *WatchlistController) AddToWatchlist() {
+	c.group.POST("/add", func(context *gin.Context) {
+		var dto watchlist_dto.WatchlistAddReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.AddToWatchlist(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *WatchlistController) RemoveFromWatchlist() {
+	c.group.POST("/remove", func(context *gin.Context) {
+		var dto watchlist_dto.WatchlistRemoveReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.RemoveFromWatchlist(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *WatchlistController) ListSavedSearches() {
+	c.group.GET("/saved_search", func(context *gin.Context) {
+		base_response := c.service.ListSavedSearches(context, userIdOf(context))
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *WatchlistController) CreateSavedSearch() {
+	c.group.POST("/saved_search/create", func(context *gin.Context) {
+		var dto watchlist_dto.SavedSearchCreateReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.CreateSavedSearch(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func (c *WatchlistController) DeleteSavedSearch() {
+	c.group.POST("/saved_search/delete", func(context *gin.Context) {
+		var dto watchlist_dto.SavedSearchDeleteReq
+		if err := context.ShouldBindJSON(&dto); err != nil {
+			context.JSON(http.StatusBadRequest, "wrong format")
+			return
+		}
+		base_response := c.service.DeleteSavedSearch(context, userIdOf(context), &dto)
+		context.JSON(base_response.StatusCode, base_response)
+	})
+}
+
+func InitWatchlistController(parentGroup *gin.RouterGroup, prefixRootApi string, debug bool) {
+	c := &WatchlistController{
+		group:   parentGroup.Group(prefixRootApi),
+		service: service.NewWatchlistService(debug),
+	}
+	c.GetWatchlist()
+	c.AddToWatchlist()
+	c.RemoveFromWatchlist()
+	c.ListSavedSearches()
+	c.CreateSavedSearch()
+	c.DeleteSavedSearch()
+}
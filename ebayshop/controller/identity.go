@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"ebayclone/dto/cart_dto"
+	"ebayclone/repo"
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderCartSession identifies the cart of an anonymous buyer
+const HeaderCartSession = "X-Cart-Session"
+
+// userIdOf returns the user verified by middleware.Identity, 0 for anonymous requests
+func userIdOf(context *gin.Context) uint32 {
+	return repo.ActorOf(context.Request.Context())
+}
+
+func cartIdentityOf(context *gin.Context) *cart_dto.CartIdentity {
+	return &cart_dto.CartIdentity{
+		UserId:     userIdOf(context),
+		SessionKey: context.GetHeader(HeaderCartSession),
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"ebayclone/repo"
+	"time"
+)
+
+// AuditLog records one write to a row of an audited schema, the repo saves it in the
+// transaction of the write once it is registered with repo.RegisterAuditLog
+type AuditLog struct {
+	Id        uint32
+	Entity    string // type name of the schema like ProductType
+	EntityId  uint32
+	ActorId   uint32 // user carried by repo.WithActor, 0 when nobody was
+	Action    string
+	Changes   *repo.AuditChanges
+	CreatedAt time.Time
+}
+
+func (a *AuditLog) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":        changeset.NewBox().Ops(changeset.AI),
+		"Entity":    changeset.NewBox().Ops(changeset.NotNullable).Size(64),
+		"EntityId":  changeset.NewBox().Ops(changeset.NotNullable),
+		"ActorId":   changeset.NewBox().Ops(changeset.Nullable),
+		"Action":    changeset.NewBox().Ops(changeset.NotNullable).OneOf(repo.AuditInsert, repo.AuditUpdate, repo.AuditDelete),
+		"Changes":   changeset.NewBox().Ops(changeset.NotNullable).JSONField(),
+		"CreatedAt": changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"time"
+)
+
+// Cart belongs to a user, or to an anonymous session when UserId is 0
+type Cart struct {
+	Id         uint32
+	UserId     uint32
+	SessionKey string
+	UpdatedAt  time.Time
+}
+
+func (c *Cart) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":         changeset.NewBox().Ops(changeset.AI),
+		"UserId":     changeset.NewBox().Ops(changeset.Nullable),
+		"SessionKey": changeset.NewBox().Ops(changeset.Nullable).Size(64),
+		"UpdatedAt":  changeset.NewBox().Ops(changeset.NotNullable, changeset.UpdatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
+
+// CartItem keeps the unit price seen when the item was added, to tell the buyer when it changed
+type CartItem struct {
+	Id               uint32
+	ProductVariantId uint32
+	Quantity         uint32
+	PriceAmount      int64
+	PriceCurrency    string
+	CartRel          *Cart
+	ProductRel       *Product
+}
+
+func (c *CartItem) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":               changeset.NewBox().Ops(changeset.AI),
+		"ProductVariantId": changeset.NewBox().Ops(changeset.Nullable),
+		"Quantity":         changeset.NewBox().Ops(changeset.NotNullable).Range(1, MaxCartItemQuantity),
+		"PriceAmount":      changeset.NewBox().Ops(changeset.NotNullable),
+		"PriceCurrency":    changeset.NewBox().Ops(changeset.NotNullable).Size(3),
+		"CartRel":          changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Cart{}, "Id"),
+		"ProductRel":       changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+	}
+}
+
+const MaxCartItemQuantity = 999
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"time"
+)
+
+// IdempotencyKey stores the first response of a mutating request sent with an
+// Idempotency-Key header. StatusCode 0 means the first request is still running
+type IdempotencyKey struct {
+	Id           uint32
+	IdemKey      string
+	Route        string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody string
+	ExpiresAt    time.Time
+}
+
+func (k *IdempotencyKey) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":           changeset.NewBox().Ops(changeset.AI),
+		"IdemKey":      changeset.NewBox().Ops(changeset.NotNullable).Size(128),
+		"Route":        changeset.NewBox().Ops(changeset.NotNullable).Size(128),
+		"RequestHash":  changeset.NewBox().Ops(changeset.NotNullable).Size(64),
+		"StatusCode":   changeset.NewBox().Ops(changeset.NotNullable),
+		"ResponseBody": changeset.NewBox().Ops(changeset.Nullable),
+		"ExpiresAt":    changeset.NewBox().Ops(changeset.NotNullable).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"time"
+)
+
+type NotificationKind string
+
+const (
+	NotificationSavedSearchMatch NotificationKind = "saved_search_match"
+)
+
+// Notification is queued for a user in the local table and read through the api,
+// SavedSearchId is 0 when the notification does not come from a saved search
+type Notification struct {
+	Id            uint32
+	UserId        uint32
+	Kind          string
+	Message       string
+	SavedSearchId uint32
+	IsRead        bool
+	CreatedAt     time.Time
+	ProductRel    *Product
+}
+
+func (n *Notification) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":            changeset.NewBox().Ops(changeset.AI),
+		"UserId":        changeset.NewBox().Ops(changeset.NotNullable),
+		"Kind":          changeset.NewBox().Ops(changeset.NotNullable).Size(32),
+		"Message":       changeset.NewBox().Ops(changeset.NotNullable).Size(255),
+		"SavedSearchId": changeset.NewBox().Ops(changeset.Nullable),
+		"IsRead":        changeset.NewBox().Ops(changeset.Nullable),
+		"CreatedAt":     changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"ProductRel":    changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+	}
+}
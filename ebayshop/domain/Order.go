@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"ebayclone/valueobject"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	OrderPendingPayment OrderStatus = "pending_payment"
+	// OrderPaying is a pending order claimed by one payment while the provider is called
+	OrderPaying    OrderStatus = "paying"
+	OrderPaid      OrderStatus = "paid"
+	OrderCompleted OrderStatus = "completed"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderRefunded  OrderStatus = "refunded"
+)
+
+type Order struct {
+	Id          uint32
+	UserId      uint32
+	Status      string
+	TotalAmount int64
+	Currency    string
+	CreatedAt   time.Time
+
+	// loaded by preload only
+	OrderLineRel []*OrderLine
+}
+
+func (o *Order) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":          changeset.NewBox().Ops(changeset.AI),
+		"UserId":      changeset.NewBox().Ops(changeset.NotNullable),
+		"Status":      changeset.NewBox().Ops(changeset.NotNullable).Size(16),
+		"TotalAmount": changeset.NewBox().Ops(changeset.NotNullable).Range(0, valueobject.MaxMoneyAmount),
+		"Currency":    changeset.NewBox().Ops(changeset.NotNullable).Size(3).OneOf(valueobject.SupportedCurrencyCodes()...),
+		"CreatedAt":   changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
+
+// AwaitsPayment tells if money captured for the order can still pay it
+func (o *Order) AwaitsPayment() bool {
+	return o.Status == string(OrderPendingPayment) || o.Status == string(OrderPaying)
+}
+
+func (o *Order) Total() valueobject.Money {
+	return valueobject.Money{Amount: o.TotalAmount, Currency: o.Currency}
+}
+
+// OrderLine is one product (or variant) of an order, the stock is held by ReservationId until payment
+type OrderLine struct {
+	Id               uint32
+	ProductVariantId uint32
+	Quantity         uint32
+	UnitPriceAmount  int64
+	LineTotalAmount  int64
+	Currency         string
+	ReservationId    uint32
+	OrderRel         *Order
+	ProductRel       *Product
+}
+
+func (l *OrderLine) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":               changeset.NewBox().Ops(changeset.AI),
+		"ProductVariantId": changeset.NewBox().Ops(changeset.Nullable),
+		"Quantity":         changeset.NewBox().Ops(changeset.NotNullable),
+		"UnitPriceAmount":  changeset.NewBox().Ops(changeset.NotNullable),
+		"LineTotalAmount":  changeset.NewBox().Ops(changeset.NotNullable),
+		"Currency":         changeset.NewBox().Ops(changeset.NotNullable).Size(3),
+		"ReservationId":    changeset.NewBox().Ops(changeset.NotNullable),
+		"OrderRel":         changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Order{}, "Id"),
+		"ProductRel":       changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+	}
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"time"
+)
+
+// PaymentRefundPending is a captured payment the shop decided to give back, the refund is
+// sent to the provider after the decision is committed
+const PaymentRefundPending = "refund_pending"
+
+// Payment is one attempt to pay an order through a provider, Status mirrors payment.Status
+type Payment struct {
+	Id          uint32
+	Provider    string
+	ProviderRef string
+	Amount      int64
+	Currency    string
+	Status      string
+	UpdatedAt   time.Time
+	OrderRel    *Order
+}
+
+func (p *Payment) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":          changeset.NewBox().Ops(changeset.AI),
+		"Provider":    changeset.NewBox().Ops(changeset.NotNullable).Size(32),
+		"ProviderRef": changeset.NewBox().Ops(changeset.NotNullable).Size(128),
+		"Amount":      changeset.NewBox().Ops(changeset.NotNullable),
+		"Currency":    changeset.NewBox().Ops(changeset.NotNullable).Size(3),
+		"Status":      changeset.NewBox().Ops(changeset.NotNullable).Size(16),
+		"UpdatedAt":   changeset.NewBox().Ops(changeset.NotNullable, changeset.UpdatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"OrderRel":    changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Order{}, "Id"),
+	}
+}
+
+// PaymentEvent records each provider event once, (Provider, EventId) is unique
+// so a retried webhook is detected by the duplicate insert
+type PaymentEvent struct {
+	Id          uint32
+	Provider    string
+	EventId     string
+	Type        string
+	ProcessedAt time.Time
+}
+
+func (e *PaymentEvent) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":          changeset.NewBox().Ops(changeset.AI),
+		"Provider":    changeset.NewBox().Ops(changeset.NotNullable).Size(32),
+		"EventId":     changeset.NewBox().Ops(changeset.NotNullable).Size(128),
+		"Type":        changeset.NewBox().Ops(changeset.NotNullable).Size(32),
+		"ProcessedAt": changeset.NewBox().Ops(changeset.NotNullable).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
@@ -2,18 +2,69 @@ package domain
 
 import (
 	"ebayclone/changeset"
+	"ebayclone/valueobject"
+	"time"
 )
 
 type Product struct {
 	Id             uint32
 	Name           string
+	SellerId       uint32 // user id of the seller, 0 for products listed before sellers were recorded
+	PriceAmount    int64  // lowest price when product has variants
+	PriceCurrency  string
+	Fields         *valueobject.FieldsJSON
+	Rating         *valueobject.RatingAggregateJSON
+	Stock          uint32       // available stock of product or sum of variants, filled from inventory on read
 	ProductTypeRel *ProductType // when have Rel keyword mean relation
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time // set when the seller deletes the product, orders keep pointing to it
+
+	// loaded by preload only, variants are saved through their own changeset
+	ProductVariantRel []*ProductVariant
 }
 
 func (p *Product) Validators() map[string]*changeset.Box {
 	return map[string]*changeset.Box{
 		"Id":             changeset.NewBox().Ops(changeset.AI),
 		"Name":           changeset.NewBox().Ops(changeset.NotNullable),
+		"SellerId":       changeset.NewBox().Ops(changeset.Nullable),
+		"PriceAmount":    changeset.NewBox().Ops(changeset.NotNullable).Range(0, valueobject.MaxMoneyAmount),
+		"PriceCurrency":  changeset.NewBox().Ops(changeset.NotNullable).Size(3).OneOf(valueobject.SupportedCurrencyCodes()...),
+		"Fields":         changeset.NewBox().Ops(changeset.Nullable).JSONField(),
+		"Rating":         changeset.NewBox().Ops(changeset.Nullable).JSONField(),
 		"ProductTypeRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&ProductType{}, "Id"),
+		"CreatedAt":      changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"UpdatedAt":      changeset.NewBox().Ops(changeset.NotNullable, changeset.UpdatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"DeletedAt":      changeset.NewBox().Ops(changeset.Nullable, changeset.DeletedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
+
+func (p *Product) Price() valueobject.Money {
+	return valueobject.Money{Amount: p.PriceAmount, Currency: p.PriceCurrency}
+}
+
+func (p *Product) FindVariant(variantId uint32) *ProductVariant {
+	for _, variant := range p.ProductVariantRel {
+		if variant.Id == variantId {
+			return variant
+		}
+	}
+	return nil
+}
+
+// Offer gives price and available stock of what a buyer picks: the product itself
+// when variantId is 0, else one of its variants. ok is false for a wrong variant id
+func (p *Product) Offer(variantId uint32) (price valueobject.Money, stock uint32, ok bool) {
+	if variantId == 0 {
+		if len(p.ProductVariantRel) > 0 {
+			return valueobject.Money{}, 0, false
+		}
+		return p.Price(), p.Stock, true
+	}
+	variant := p.FindVariant(variantId)
+	if variant == nil {
+		return valueobject.Money{}, 0, false
 	}
+	return variant.Price(), variant.Stock, true
 }
@@ -3,6 +3,15 @@ package domain
 import (
 	"ebayclone/changeset"
 	"ebayclone/valueobject"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotFoundAttributeId   = errors.New("ProductType Not Found Attribute Id")
+	ErrNotFoundOptionValueId = errors.New("ProductType Not Found OptionValue Id")
 )
 
 type ProductType struct {
@@ -10,6 +19,12 @@ type ProductType struct {
 	Name            string
 	Attributes      *valueobject.AttributesObjectRes
 	AggregateFields *valueobject.AggregateFieldJSON
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	CreatedBy       uint32 // user id of the admin who created the type, 0 before it was recorded
+
+	// loaded by preload only
+	ProductRel []*Product
 }
 
 func (p *ProductType) Validators() map[string]*changeset.Box {
@@ -17,7 +32,10 @@ func (p *ProductType) Validators() map[string]*changeset.Box {
 		"Id":              changeset.NewBox().Ops(changeset.AI),
 		"Name":            changeset.NewBox().Ops(changeset.NotNullable),
 		"Attributes":      changeset.NewBox().Ops(changeset.NotNullable).JSONField(),
-		"AggregateFields": changeset.NewBox().Ops(changeset.NotNullable).JSONField(),
+		"AggregateFields": changeset.NewBox().Ops(changeset.NotNullable, changeset.NotAuditedOp).JSONField(),
+		"CreatedAt":       changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"UpdatedAt":       changeset.NewBox().Ops(changeset.NotNullable, changeset.UpdatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"CreatedBy":       changeset.NewBox().Ops(changeset.Nullable, changeset.CreatedByOp),
 	}
 }
 
@@ -26,6 +44,127 @@ func (p *ProductType) CloneProductType() *ProductType {
 		Id:              p.Id,
 		Name:            p.Name,
 		Attributes:      p.Attributes,
-		AggregateFields: p.AggregateFields,
+		AggregateFields: p.AggregateFields.Clone(),
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+		CreatedBy:       p.CreatedBy,
+	}
+}
+
+func (p *ProductType) FindAttribute(attributeId valueobject.AttributeId) *valueobject.OneAttributeObjectRes {
+	if p.Attributes == nil {
+		return nil
+	}
+	for _, attribute := range p.Attributes.Attributes {
+		if attribute.Id == attributeId {
+			return attribute
+		}
+	}
+	return nil
+}
+
+// ValidateFields checks the options a product selected against the attributes of this type:
+// ids must exist, single select attributes take one option and required attributes must be set,
+// either on the product or on every variant
+func (p *ProductType) ValidateFields(fields valueobject.FieldsJSON, variants ...valueobject.VariantOptionsJSON) error {
+	for attributeId, optionValueIds := range fields {
+		attribute := p.FindAttribute(attributeId)
+		if attribute == nil {
+			return fmt.Errorf("%w [%v]", ErrNotFoundAttributeId, attributeId)
+		}
+		if len(optionValueIds) > 1 && !attribute.MultiSelect {
+			return fmt.Errorf("attribute [%v] accepts only one option", attribute.Name)
+		}
+		selected := map[valueobject.OptionValueId]bool{}
+		for _, optionValueId := range optionValueIds {
+			if selected[optionValueId] {
+				return fmt.Errorf("option [%v] selected twice for attribute [%v]", optionValueId, attribute.Name)
+			}
+			selected[optionValueId] = true
+			if !attributeHasOption(attribute, optionValueId) {
+				return fmt.Errorf("%w [%v] of attribute [%v]", ErrNotFoundOptionValueId, optionValueId, attribute.Name)
+			}
+		}
+	}
+	if err := p.validateVariants(fields, variants); err != nil {
+		return err
+	}
+	if p.Attributes == nil {
+		return nil
+	}
+	for _, attribute := range p.Attributes.Attributes {
+		if !attribute.Required || len(fields[attribute.Id]) > 0 {
+			continue
+		}
+		if len(variants) == 0 {
+			return fmt.Errorf("missing required attribute [%v]", attribute.Name)
+		}
+		for _, variant := range variants {
+			if _, ok := variant[attribute.Id]; !ok {
+				return fmt.Errorf("missing required attribute [%v]", attribute.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateFilter checks the options of a search filter exist on this type,
+// a filter may pick many options of one attribute and needs no required attribute
+func (p *ProductType) ValidateFilter(filter valueobject.FieldsJSON) error {
+	for attributeId, optionValueIds := range filter {
+		attribute := p.FindAttribute(attributeId)
+		if attribute == nil {
+			return fmt.Errorf("%w [%v]", ErrNotFoundAttributeId, attributeId)
+		}
+		for _, optionValueId := range optionValueIds {
+			if !attributeHasOption(attribute, optionValueId) {
+				return fmt.Errorf("%w [%v] of attribute [%v]", ErrNotFoundOptionValueId, optionValueId, attribute.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// validateVariants checks each variant picks existing options, does not redefine
+// an attribute already fixed on the product and is not a copy of another variant
+func (p *ProductType) validateVariants(fields valueobject.FieldsJSON, variants []valueobject.VariantOptionsJSON) error {
+	seenSelections := map[string]bool{}
+	for index, variant := range variants {
+		if len(variant) == 0 {
+			return fmt.Errorf("variant at position %v has no option", index)
+		}
+		attributeIds := make([]int, 0, len(variant))
+		for attributeId, optionValueId := range variant {
+			attribute := p.FindAttribute(attributeId)
+			if attribute == nil {
+				return fmt.Errorf("%w [%v]", ErrNotFoundAttributeId, attributeId)
+			}
+			if _, fixed := fields[attributeId]; fixed {
+				return fmt.Errorf("attribute [%v] is set on product and on variant", attribute.Name)
+			}
+			if !attributeHasOption(attribute, optionValueId) {
+				return fmt.Errorf("%w [%v] of attribute [%v]", ErrNotFoundOptionValueId, optionValueId, attribute.Name)
+			}
+			attributeIds = append(attributeIds, int(attributeId))
+		}
+		sort.Ints(attributeIds)
+		selection := ""
+		for _, attributeId := range attributeIds {
+			selection += fmt.Sprintf("%v:%v;", attributeId, variant[valueobject.AttributeId(attributeId)])
+		}
+		if seenSelections[selection] {
+			return fmt.Errorf("variant at position %v repeats the options of another variant", index)
+		}
+		seenSelections[selection] = true
+	}
+	return nil
+}
+
+func attributeHasOption(attribute *valueobject.OneAttributeObjectRes, optionValueId valueobject.OptionValueId) bool {
+	for _, optionValue := range attribute.OptionValues {
+		if optionValue.Id == optionValueId {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"ebayclone/valueobject"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// shoeType has a required single select Size (1: 40, 2: 41), an optional multi select
+// Color (3: red, 4: blue) and an optional single select Material (5: leather)
+func shoeType() *ProductType {
+	return &ProductType{
+		Id:   1,
+		Name: "Shoe",
+		Attributes: &valueobject.AttributesObjectRes{Attributes: []*valueobject.OneAttributeObjectRes{
+			{Id: 10, Name: "Size", Required: true, OptionValues: []*valueobject.OptionValueRes{{Id: 1, Value: "40"}, {Id: 2, Value: "41"}}},
+			{Id: 11, Name: "Color", MultiSelect: true, OptionValues: []*valueobject.OptionValueRes{{Id: 3, Value: "red"}, {Id: 4, Value: "blue"}}},
+			{Id: 12, Name: "Material", OptionValues: []*valueobject.OptionValueRes{{Id: 5, Value: "leather"}}},
+		}},
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  valueobject.FieldsJSON
+		wantErr error
+	}{
+		{name: "empty", filter: valueobject.FieldsJSON{}},
+		{name: "required attribute not needed", filter: valueobject.FieldsJSON{11: {3}}},
+		{name: "many options of a single select", filter: valueobject.FieldsJSON{10: {1, 2}}},
+		{name: "unknown attribute", filter: valueobject.FieldsJSON{99: {1}}, wantErr: ErrNotFoundAttributeId},
+		{name: "option of another attribute", filter: valueobject.FieldsJSON{10: {3}}, wantErr: ErrNotFoundOptionValueId},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := shoeType().ValidateFilter(tt.filter); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateFilter() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   valueobject.FieldsJSON
+		variants []valueobject.VariantOptionsJSON
+		wantErr  string
+	}{
+		{name: "required only", fields: valueobject.FieldsJSON{10: {1}}},
+		{name: "multi select", fields: valueobject.FieldsJSON{10: {2}, 11: {3, 4}, 12: {5}}},
+		{name: "required on every variant", fields: valueobject.FieldsJSON{11: {3}}, variants: []valueobject.VariantOptionsJSON{{10: 1}, {10: 2}}},
+		{name: "missing required", fields: valueobject.FieldsJSON{11: {3}}, wantErr: "missing required attribute [Size]"},
+		{name: "required set as empty list", fields: valueobject.FieldsJSON{10: {}}, wantErr: "missing required attribute [Size]"},
+		{name: "required missing on one variant", variants: []valueobject.VariantOptionsJSON{{10: 1}, {12: 5}}, wantErr: "missing required attribute [Size]"},
+		{name: "single select takes one", fields: valueobject.FieldsJSON{10: {1, 2}}, wantErr: "attribute [Size] accepts only one option"},
+		{name: "option twice", fields: valueobject.FieldsJSON{10: {1}, 11: {3, 3}}, wantErr: "option [3] selected twice for attribute [Color]"},
+		{name: "unknown attribute", fields: valueobject.FieldsJSON{10: {1}, 99: {1}}, wantErr: ErrNotFoundAttributeId.Error()},
+		{name: "option of another attribute", fields: valueobject.FieldsJSON{10: {5}}, wantErr: ErrNotFoundOptionValueId.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := shoeType().ValidateFields(tt.fields, tt.variants...)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ValidateFields() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if err := (&ProductType{}).ValidateFields(valueobject.FieldsJSON{}); err != nil {
+		t.Errorf("ValidateFields() of a type without attributes = %v", err)
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   valueobject.FieldsJSON
+		variants []valueobject.VariantOptionsJSON
+		wantErr  string
+	}{
+		{name: "no variants"},
+		{name: "matrix", fields: valueobject.FieldsJSON{12: {5}}, variants: []valueobject.VariantOptionsJSON{{10: 1, 11: 3}, {10: 1, 11: 4}, {10: 2, 11: 3}}},
+		{name: "empty variant", variants: []valueobject.VariantOptionsJSON{{10: 1}, {}}, wantErr: "variant at position 1 has no option"},
+		{name: "unknown attribute", variants: []valueobject.VariantOptionsJSON{{99: 1}}, wantErr: ErrNotFoundAttributeId.Error()},
+		{name: "option of another attribute", variants: []valueobject.VariantOptionsJSON{{10: 3}}, wantErr: ErrNotFoundOptionValueId.Error()},
+		{name: "attribute fixed on product", fields: valueobject.FieldsJSON{10: {1}}, variants: []valueobject.VariantOptionsJSON{{10: 2}}, wantErr: "attribute [Size] is set on product and on variant"},
+		{name: "repeated selection", variants: []valueobject.VariantOptionsJSON{{10: 1, 11: 3}, {10: 2, 11: 3}, {11: 3, 10: 1}}, wantErr: "variant at position 2 repeats the options of another variant"},
+		{name: "subset is another selection", variants: []valueobject.VariantOptionsJSON{{10: 1}, {10: 1, 11: 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := shoeType().validateVariants(tt.fields, tt.variants)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("validateVariants() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"ebayclone/valueobject"
+	"time"
+)
+
+type ProductVariant struct {
+	Id            uint32
+	Sku           string
+	PriceAmount   int64
+	PriceCurrency string
+	Stock         uint32 // available stock, filled from inventory on read
+	Options       *valueobject.VariantOptionsJSON
+	ProductRel    *Product
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     *time.Time
+}
+
+func (v *ProductVariant) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":            changeset.NewBox().Ops(changeset.AI),
+		"Sku":           changeset.NewBox().Ops(changeset.NotNullable).Size(64),
+		"PriceAmount":   changeset.NewBox().Ops(changeset.NotNullable).Range(0, valueobject.MaxMoneyAmount),
+		"PriceCurrency": changeset.NewBox().Ops(changeset.NotNullable).Size(3).OneOf(valueobject.SupportedCurrencyCodes()...),
+		"Options":       changeset.NewBox().Ops(changeset.NotNullable).JSONField(),
+		"ProductRel":    changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+		"CreatedAt":     changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"UpdatedAt":     changeset.NewBox().Ops(changeset.NotNullable, changeset.UpdatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"DeletedAt":     changeset.NewBox().Ops(changeset.Nullable, changeset.DeletedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+	}
+}
+
+func (v *ProductVariant) Price() valueobject.Money {
+	return valueobject.Money{Amount: v.PriceAmount, Currency: v.PriceCurrency}
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"ebayclone/valueobject"
+	"time"
+)
+
+const MaxReviewCommentSize = 2000
+
+// Review is written by the buyer of a completed order, once per product of the order
+type Review struct {
+	Id         uint32
+	UserId     uint32
+	SellerId   uint32
+	Rating     uint8
+	Comment    string
+	CreatedAt  time.Time
+	OrderRel   *Order
+	ProductRel *Product
+}
+
+func (r *Review) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":         changeset.NewBox().Ops(changeset.AI),
+		"UserId":     changeset.NewBox().Ops(changeset.NotNullable),
+		"SellerId":   changeset.NewBox().Ops(changeset.Nullable),
+		"Rating":     changeset.NewBox().Ops(changeset.NotNullable).Range(valueobject.MinRating, valueobject.MaxRating),
+		"Comment":    changeset.NewBox().Ops(changeset.Nullable).Size(MaxReviewCommentSize),
+		"CreatedAt":  changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"OrderRel":   changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Order{}, "Id"),
+		"ProductRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+	}
+}
+
+// SellerRating holds the rating aggregate of one seller, Id is the user id of the seller
+type SellerRating struct {
+	Id     uint32
+	Rating *valueobject.RatingAggregateJSON
+}
+
+func (s *SellerRating) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":     changeset.NewBox().Ops(changeset.NotNullable),
+		"Rating": changeset.NewBox().Ops(changeset.NotNullable).JSONField(),
+	}
+}
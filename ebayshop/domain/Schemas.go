@@ -0,0 +1,29 @@
+package domain
+
+import "ebayclone/changeset"
+
+// Schemas lists every table of the shop, the repo only accepts column names
+// from requests when they belong to one of them
+func Schemas() []changeset.Schema {
+	return []changeset.Schema{
+		&ProductType{},
+		&Product{},
+		&ProductVariant{},
+		&StockLevel{},
+		&StockMovement{},
+		&StockReservation{},
+		&Cart{},
+		&CartItem{},
+		&Order{},
+		&OrderLine{},
+		&Payment{},
+		&PaymentEvent{},
+		&IdempotencyKey{},
+		&Review{},
+		&SellerRating{},
+		&WatchlistItem{},
+		&SavedSearch{},
+		&Notification{},
+		&AuditLog{},
+	}
+}
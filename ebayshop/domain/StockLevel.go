@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+)
+
+// StockLevel is the stock of a product, or of one variant when ProductVariantId is set
+type StockLevel struct {
+	Id               uint32
+	ProductVariantId uint32 // 0 means stock of the product itself
+	OnHand           uint32
+	Reserved         uint32
+	ProductRel       *Product
+}
+
+func (s *StockLevel) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":               changeset.NewBox().Ops(changeset.AI),
+		"ProductVariantId": changeset.NewBox().Ops(changeset.Nullable),
+		"OnHand":           changeset.NewBox().Ops(changeset.NotNullable),
+		"Reserved":         changeset.NewBox().Ops(changeset.NotNullable),
+		"ProductRel":       changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+	}
+}
+
+func (s *StockLevel) Available() uint32 {
+	if s.Reserved > s.OnHand {
+		return 0
+	}
+	return s.OnHand - s.Reserved
+}
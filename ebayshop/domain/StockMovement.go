@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"time"
+)
+
+type StockMovementKind string
+
+const (
+	StockMovementReceive StockMovementKind = "receive"
+	StockMovementReserve StockMovementKind = "reserve"
+	StockMovementRelease StockMovementKind = "release"
+	StockMovementSell    StockMovementKind = "sell"
+	StockMovementReturn  StockMovementKind = "return"
+)
+
+// StockMovement is one line of the append only inventory ledger, rows are never updated
+type StockMovement struct {
+	Id            uint32
+	Kind          string
+	Quantity      uint32
+	ReservationId uint32 // set for reserve, release and sell
+	CreatedAt     time.Time
+	StockLevelRel *StockLevel
+}
+
+func (m *StockMovement) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":            changeset.NewBox().Ops(changeset.AI),
+		"Kind":          changeset.NewBox().Ops(changeset.NotNullable).Size(16),
+		"Quantity":      changeset.NewBox().Ops(changeset.NotNullable),
+		"ReservationId": changeset.NewBox().Ops(changeset.Nullable),
+		"CreatedAt":     changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"StockLevelRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&StockLevel{}, "Id"),
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"time"
+)
+
+type StockReservationStatus string
+
+const (
+	StockReservationActive   StockReservationStatus = "active"
+	StockReservationReleased StockReservationStatus = "released"
+	StockReservationSold     StockReservationStatus = "sold"
+)
+
+// StockReservation holds stock for a checkout, an active one past ExpiresAt is released by the sweeper
+type StockReservation struct {
+	Id            uint32
+	Quantity      uint32
+	Status        string
+	ExpiresAt     time.Time
+	StockLevelRel *StockLevel
+}
+
+func (r *StockReservation) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":            changeset.NewBox().Ops(changeset.AI),
+		"Quantity":      changeset.NewBox().Ops(changeset.NotNullable),
+		"Status":        changeset.NewBox().Ops(changeset.NotNullable).Size(16),
+		"ExpiresAt":     changeset.NewBox().Ops(changeset.NotNullable).DateTimeFormat("2006-01-02 15:04:05"),
+		"StockLevelRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&StockLevel{}, "Id"),
+	}
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"ebayclone/changeset"
+	"ebayclone/valueobject"
+	"time"
+)
+
+// WatchlistItem is a product a user watches, unique on (UserId, ProductId)
+type WatchlistItem struct {
+	Id         uint32
+	UserId     uint32
+	CreatedAt  time.Time
+	ProductRel *Product
+}
+
+func (w *WatchlistItem) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":         changeset.NewBox().Ops(changeset.AI),
+		"UserId":     changeset.NewBox().Ops(changeset.NotNullable),
+		"CreatedAt":  changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"ProductRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Product{}, "Id"),
+	}
+}
+
+// SavedSearch keeps the filters of a search, new products matching them notify the user.
+// Filter holds the accepted option ids per attribute: any option of an attribute matches,
+// every attribute of the filter has to match
+type SavedSearch struct {
+	Id             uint32
+	UserId         uint32
+	Name           string
+	Filter         *valueobject.FieldsJSON
+	CreatedAt      time.Time
+	ProductTypeRel *ProductType
+}
+
+func (s *SavedSearch) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":             changeset.NewBox().Ops(changeset.AI),
+		"UserId":         changeset.NewBox().Ops(changeset.NotNullable),
+		"Name":           changeset.NewBox().Ops(changeset.NotNullable).Size(80),
+		"Filter":         changeset.NewBox().Ops(changeset.Nullable).JSONField(),
+		"CreatedAt":      changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp).DateTimeFormat("2006-01-02 15:04:05"),
+		"ProductTypeRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&ProductType{}, "Id"),
+	}
+}
+
+// Matches tells if the product fits the search, options of the product and of all its variants count
+func (s *SavedSearch) Matches(product *Product) bool {
+	if s.ProductTypeRel == nil || product.ProductTypeRel == nil || s.ProductTypeRel.Id != product.ProductTypeRel.Id {
+		return false
+	}
+	if s.Filter == nil {
+		return true
+	}
+	picked := map[valueobject.AttributeId]map[valueobject.OptionValueId]bool{}
+	pick := func(attributeId valueobject.AttributeId, optionValueId valueobject.OptionValueId) {
+		if _, ok := picked[attributeId]; !ok {
+			picked[attributeId] = map[valueobject.OptionValueId]bool{}
+		}
+		picked[attributeId][optionValueId] = true
+	}
+	if product.Fields != nil {
+		for attributeId, optionValueIds := range *product.Fields {
+			for _, optionValueId := range optionValueIds {
+				pick(attributeId, optionValueId)
+			}
+		}
+	}
+	for _, variant := range product.ProductVariantRel {
+		if variant.Options == nil {
+			continue
+		}
+		for attributeId, optionValueId := range *variant.Options {
+			pick(attributeId, optionValueId)
+		}
+	}
+	for attributeId, accepted := range *s.Filter {
+		if len(accepted) == 0 {
+			continue
+		}
+		found := false
+		for _, optionValueId := range accepted {
+			if picked[attributeId][optionValueId] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
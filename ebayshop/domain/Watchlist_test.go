@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"ebayclone/valueobject"
+	"testing"
+)
+
+func TestSavedSearchMatches(t *testing.T) {
+	// a red leather shoe with variants in size 40 and 41
+	product := &Product{
+		Id:             7,
+		ProductTypeRel: &ProductType{Id: 1},
+		Fields:         &valueobject.FieldsJSON{11: {3}, 12: {5}},
+		ProductVariantRel: []*ProductVariant{
+			{Id: 1, Options: &valueobject.VariantOptionsJSON{10: 1}},
+			{Id: 2, Options: &valueobject.VariantOptionsJSON{10: 2}},
+			{Id: 3},
+		},
+	}
+	search := func(productTypeId uint32, filter *valueobject.FieldsJSON) *SavedSearch {
+		return &SavedSearch{ProductTypeRel: &ProductType{Id: productTypeId}, Filter: filter}
+	}
+	tests := []struct {
+		name   string
+		search *SavedSearch
+		want   bool
+	}{
+		{name: "no filter", search: search(1, nil), want: true},
+		{name: "other product type", search: search(2, nil), want: false},
+		{name: "search without type", search: &SavedSearch{}, want: false},
+		{name: "option of product", search: search(1, &valueobject.FieldsJSON{11: {3}}), want: true},
+		{name: "option of a variant", search: search(1, &valueobject.FieldsJSON{10: {2}}), want: true},
+		{name: "any accepted option", search: search(1, &valueobject.FieldsJSON{11: {4, 3}}), want: true},
+		{name: "no accepted option", search: search(1, &valueobject.FieldsJSON{11: {4}}), want: false},
+		{name: "every attribute has to match", search: search(1, &valueobject.FieldsJSON{11: {3}, 10: {9}}), want: false},
+		{name: "empty option list accepts all", search: search(1, &valueobject.FieldsJSON{11: {}}), want: true},
+		{name: "attribute the product lacks", search: search(1, &valueobject.FieldsJSON{13: {6}}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.search.Matches(product); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	bare := &Product{Id: 8, ProductTypeRel: &ProductType{Id: 1}}
+	if search(1, &valueobject.FieldsJSON{11: {3}}).Matches(bare) {
+		t.Error("a product without options matched a filter")
+	}
+}
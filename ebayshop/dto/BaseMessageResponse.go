@@ -1,10 +1,15 @@
 package dto
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
+// ErrCodeQueryTimeout is the ErrCodeString of a request whose query ran out of time
+const ErrCodeQueryTimeout = "query timeout"
+
 type BaseMessageResponse struct {
 	StatusCode    int    `json:"status_code"`
 	ErrCodeString string `json:"err_code_string"`
@@ -22,3 +27,19 @@ func (b *BaseMessageResponse) TransformToNotFoundEntity(entityName string) {
 	b.ErrCodeString = fmt.Sprintf("Not Found Any Entity Type=[%v]", entityName)
 	b.ReponseObject = nil
 }
+
+func (b *BaseMessageResponse) TransformToBadRequest(reason string) {
+	b.StatusCode = http.StatusBadRequest
+	b.ErrCodeString = reason
+	b.ReponseObject = nil
+}
+
+// TransformToError reports err keeping the status code set so far,
+// an error caused by a deadline answers 504 with ErrCodeQueryTimeout
+func (b *BaseMessageResponse) TransformToError(err error) {
+	b.ErrCodeString = err.Error()
+	if errors.Is(err, context.DeadlineExceeded) {
+		b.StatusCode = http.StatusGatewayTimeout
+		b.ErrCodeString = ErrCodeQueryTimeout
+	}
+}
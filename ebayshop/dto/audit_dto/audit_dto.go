@@ -0,0 +1,20 @@
+package audit_dto
+
+import "ebayclone/domain"
+
+// MaxAuditRecordsListed is how many of the newest audit records one list returns
+const MaxAuditRecordsListed = 100
+
+// AuditListReq lists the records of an audited entity like ProductType, of one row
+// when Id is set, and pages with the next_cursor of the previous response
+type AuditListReq struct {
+	Entity string `form:"entity"`
+	Id     uint32 `form:"id"`
+	Cursor string `form:"cursor"`
+}
+
+// AuditListRes has an empty next_cursor on the last page
+type AuditListRes struct {
+	Records    []*domain.AuditLog `json:"records"`
+	NextCursor string             `json:"next_cursor"`
+}
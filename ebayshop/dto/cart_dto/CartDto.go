@@ -0,0 +1,53 @@
+package cart_dto
+
+import "ebayclone/valueobject"
+
+// CartIdentity is who owns the cart: a logged in user, an anonymous session, or both
+// right after login when the anonymous cart has to be merged
+type CartIdentity struct {
+	UserId     uint32
+	SessionKey string
+}
+
+type CartAddItemReq struct {
+	ProductId uint32 `json:"product_id"`
+	VariantId uint32 `json:"variant_id"`
+	Quantity  uint32 `json:"quantity"`
+}
+
+type CartUpdateQuantityReq struct {
+	ItemId   uint32 `json:"item_id"`
+	Quantity uint32 `json:"quantity"` // 0 removes the item
+}
+
+type CartRemoveItemReq struct {
+	ItemId uint32 `json:"item_id"`
+}
+
+type CartItemRes struct {
+	ItemId            uint32             `json:"item_id"`
+	ProductId         uint32             `json:"product_id"`
+	VariantId         uint32             `json:"variant_id"`
+	Name              string             `json:"name"`
+	Quantity          uint32             `json:"quantity"`
+	UnitPrice         valueobject.Money  `json:"unit_price"`
+	PreviousUnitPrice *valueobject.Money `json:"previous_unit_price,omitempty"`
+	PriceChanged      bool               `json:"price_changed"`
+	AvailableStock    uint32             `json:"available_stock"`
+	InsufficientStock bool               `json:"insufficient_stock"`
+	Unavailable       bool               `json:"unavailable"` // product or variant no longer exists
+	LineTotal         valueobject.Money  `json:"line_total"`
+}
+
+type CartRes struct {
+	CartId     uint32              `json:"cart_id"`
+	SessionKey string              `json:"session_key,omitempty"`
+	Items      []*CartItemRes      `json:"items"`
+	Totals     []valueobject.Money `json:"totals"` // one total per currency
+}
+
+type CartCheckoutRes struct {
+	OrderId              uint32            `json:"order_id"`
+	Total                valueobject.Money `json:"total"`
+	ReservationExpiresAt string            `json:"reservation_expires_at"`
+}
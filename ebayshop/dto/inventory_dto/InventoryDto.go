@@ -0,0 +1,24 @@
+package inventory_dto
+
+import "ebayclone/domain"
+
+type StockChangeReq struct {
+	ProductId uint32 `json:"product_id"`
+	VariantId uint32 `json:"variant_id"` // 0 for product without variants
+	Quantity  uint32 `json:"quantity"`
+}
+
+type StockReserveReq struct {
+	ProductId  uint32 `json:"product_id"`
+	VariantId  uint32 `json:"variant_id"`
+	Quantity   uint32 `json:"quantity"`
+	TtlSeconds uint32 `json:"ttl_seconds"`
+}
+
+type ReservationReq struct {
+	ReservationId uint32 `json:"reservation_id"`
+}
+
+type StockMovementsRes struct {
+	Movements []*domain.StockMovement `json:"movements"`
+}
@@ -0,0 +1,22 @@
+package notification_dto
+
+import "ebayclone/domain"
+
+// MaxNotificationsListed is how many of the newest notifications one list returns
+const MaxNotificationsListed = 100
+
+// NotificationListReq pages with the next_cursor of the previous response, empty for the newest
+type NotificationListReq struct {
+	UnreadOnly bool   `form:"unread_only"`
+	Cursor     string `form:"cursor"`
+}
+
+// NotificationListRes has an empty next_cursor on the last page
+type NotificationListRes struct {
+	Notifications []*domain.Notification `json:"notifications"`
+	NextCursor    string                 `json:"next_cursor"`
+}
+
+type NotificationReadReq struct {
+	Id uint32 `json:"id"`
+}
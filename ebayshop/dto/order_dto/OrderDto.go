@@ -0,0 +1,7 @@
+package order_dto
+
+import "ebayclone/domain"
+
+type OrderGetRes struct {
+	Order *domain.Order `json:"order"`
+}
@@ -0,0 +1,18 @@
+package payment_dto
+
+type PaymentPayReq struct {
+	OrderId  uint32 `json:"order_id"`
+	Provider string `json:"provider"`
+}
+
+type PaymentRes struct {
+	PaymentId   uint32 `json:"payment_id"`
+	OrderId     uint32 `json:"order_id"`
+	ProviderRef string `json:"provider_ref"`
+	Status      string `json:"status"`
+}
+
+type PaymentWebhookRes struct {
+	EventId   string `json:"event_id"`
+	Duplicate bool   `json:"duplicate"` // event was already handled, nothing changed
+}
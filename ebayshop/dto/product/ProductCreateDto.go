@@ -4,12 +4,24 @@ import (
 	"ebayclone/valueobject"
 )
 
+type ProductVariantCreateReq struct {
+	Sku     string                         `json:"sku"`
+	Price   valueobject.Money              `json:"price"`
+	Stock   uint32                         `json:"stock"`
+	Options valueobject.VariantOptionsJSON `json:"options"`
+}
+
 type ProductCreateReq struct {
-	ProductTypeId uint32                  `json:"product_type_id"`
-	Fields        *valueobject.FieldsJSON `json:"fields"`
-	Name          string                  `json:"name"`
+	ProductTypeId uint32                     `json:"product_type_id"`
+	Fields        *valueobject.FieldsJSON    `json:"fields"`
+	Name          string                     `json:"name"`
+	Price         *valueobject.Money         `json:"price"` // required when product has no variants
+	Stock         uint32                     `json:"stock"` // initial stock when product has no variants
+	Variants      []*ProductVariantCreateReq `json:"variants"`
+	SellerId      uint32                     `json:"-"` // the logged in user, never taken from the body
 }
 
 type ProductCreateRes struct {
-	Id uint32 `json:"id"`
+	Id         uint32   `json:"id"`
+	VariantIds []uint32 `json:"variant_ids"`
 }
@@ -0,0 +1,20 @@
+package product
+
+import (
+	"ebayclone/domain"
+	"ebayclone/valueobject"
+)
+
+type ProductGetRes struct {
+	Product *domain.Product `json:"product"`
+}
+
+// ProductUpdateReq changes only what is set, variants and prices are not changed here
+type ProductUpdateReq struct {
+	Name   *string                 `json:"name"`
+	Fields *valueobject.FieldsJSON `json:"fields"`
+}
+
+type ProductDeleteRes struct {
+	Id uint32 `json:"id"`
+}
@@ -0,0 +1,24 @@
+package product
+
+import "ebayclone/domain"
+
+// MaxProductsListed is how many products one page of the list returns
+const MaxProductsListed = 100
+
+// ProductListReq pages with the next_cursor of the previous response, a cursor is
+// only valid for the sort it was made with
+type ProductListReq struct {
+	ProductTypeId uint32 `form:"product_type_id"`
+	MinPrice      *int64 `form:"min_price"`
+	MaxPrice      *int64 `form:"max_price"`
+	Currency      string `form:"currency"`
+	// price_asc, price_desc, newest
+	Sort   string `form:"sort"`
+	Cursor string `form:"cursor"`
+}
+
+// ProductListRes has an empty next_cursor on the last page
+type ProductListRes struct {
+	Products   []*domain.Product `json:"products"`
+	NextCursor string            `json:"next_cursor"`
+}
@@ -0,0 +1,23 @@
+package product
+
+import "ebayclone/domain"
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+type ProductSearchReq struct {
+	Q     string `form:"q"`
+	Limit int    `form:"limit"`
+}
+
+type ProductSearchHit struct {
+	Product *domain.Product `json:"product"`
+	Score   float64         `json:"score"`
+}
+
+type ProductSearchRes struct {
+	Source  string              `json:"source"` // index, or mysql_fulltext while the index loads
+	Results []*ProductSearchHit `json:"results"`
+}
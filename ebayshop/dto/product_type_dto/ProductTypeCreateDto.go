@@ -3,12 +3,28 @@ package product_type_dto
 import "ebayclone/valueobject"
 
 // Request ....
+type AttributeCreateReq struct {
+	Name         string `json:"name"`
+	OptionValues []any  `json:"option_values"`
+	Required     bool   `json:"required"`
+	MultiSelect  bool   `json:"multi_select"`
+}
+
 type ProductTypeCreateReq struct {
-	Name       string           `json:"name"`
-	Attributes map[string][]any `json:"attributes"`
+	Name string `json:"name"`
+	// Attributes keep the order sent by client, ids are assigned by position
+	Attributes []*AttributeCreateReq `json:"attributes"`
+}
+
+// AttributeMappingRes is the id of one attribute and of its option values by printed value
+type AttributeMappingRes struct {
+	Id             valueobject.AttributeId              `json:"id"`
+	OptionValueIds map[string]valueobject.OptionValueId `json:"option_value_ids"`
 }
 
 type ProductTypeCreateRes struct {
 	Id         uint32                           `json:"id"`
 	Attributes *valueobject.AttributesObjectRes `json:"attributesObjectRes"`
+	// Mapping is by attribute name, clients cache these ids across sessions
+	Mapping map[string]*AttributeMappingRes `json:"mapping"`
 }
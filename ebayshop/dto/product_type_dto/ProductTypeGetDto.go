@@ -0,0 +1,18 @@
+package product_type_dto
+
+import "ebayclone/domain"
+
+// MaxProductTypeProductsListed is how many products of a type one page returns
+const MaxProductTypeProductsListed = 100
+
+// ProductTypeProductsReq pages with the next_cursor of the previous response, empty for the first page
+type ProductTypeProductsReq struct {
+	Cursor string `form:"cursor"`
+}
+
+// ProductTypeProductsRes holds the product type with one page of its products and their
+// variants in ProductRel, next_cursor is empty on the last page
+type ProductTypeProductsRes struct {
+	ProductType *domain.ProductType `json:"product_type"`
+	NextCursor  string              `json:"next_cursor"`
+}
@@ -0,0 +1,34 @@
+package review_dto
+
+import (
+	"ebayclone/domain"
+	"ebayclone/valueobject"
+)
+
+const (
+	DefaultReviewPageSize = 20
+	MaxReviewPageSize     = 100
+)
+
+type ReviewCreateReq struct {
+	ProductId uint32 `json:"product_id"`
+	Rating    uint8  `json:"rating"` // 1 to 5 stars
+	Comment   string `json:"comment"`
+}
+
+type ReviewCreateRes struct {
+	Id uint32 `json:"id"`
+}
+
+type ReviewListReq struct {
+	Page     uint32 `form:"page"` // starts at 1
+	PageSize uint32 `form:"page_size"`
+}
+
+type ReviewListRes struct {
+	Reviews  []*domain.Review                 `json:"reviews"`
+	Page     uint32                           `json:"page"`
+	PageSize uint32                           `json:"page_size"`
+	Total    int                              `json:"total"`
+	Rating   *valueobject.RatingAggregateJSON `json:"rating"`
+}
@@ -0,0 +1,29 @@
+package seller_dto
+
+// MaxSalesReportDays bounds the range of one report, rows grow with days * product types * currencies
+const MaxSalesReportDays = 366
+
+// DefaultSalesReportDays is the range when from is not given
+const DefaultSalesReportDays = 30
+
+// SalesReportReq takes days as 2006-01-02 in UTC, both ends are included
+type SalesReportReq struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+// SalesReportRow is one product type on one day, amounts are never summed across currencies
+type SalesReportRow struct {
+	ProductTypeId uint32 `json:"product_type_id"`
+	Day           string `json:"day"`
+	Currency      string `json:"currency"`
+	Orders        int64  `json:"orders"`
+	Quantity      int64  `json:"quantity"`
+	Revenue       int64  `json:"revenue"`
+}
+
+type SalesReportRes struct {
+	From string            `json:"from"`
+	To   string            `json:"to"`
+	Rows []*SalesReportRow `json:"rows"`
+}
@@ -0,0 +1,36 @@
+package watchlist_dto
+
+import (
+	"ebayclone/domain"
+	"ebayclone/valueobject"
+)
+
+type WatchlistAddReq struct {
+	ProductId uint32 `json:"product_id"`
+}
+
+type WatchlistRemoveReq struct {
+	ProductId uint32 `json:"product_id"`
+}
+
+type WatchlistRes struct {
+	Items []*domain.WatchlistItem `json:"items"`
+}
+
+type SavedSearchCreateReq struct {
+	Name          string                  `json:"name"`
+	ProductTypeId uint32                  `json:"product_type_id"`
+	Filter        *valueobject.FieldsJSON `json:"filter"` // attribute id to accepted option ids
+}
+
+type SavedSearchCreateRes struct {
+	Id uint32 `json:"id"`
+}
+
+type SavedSearchDeleteReq struct {
+	Id uint32 `json:"id"`
+}
+
+type SavedSearchListRes struct {
+	SavedSearches []*domain.SavedSearch `json:"saved_searches"`
+}
@@ -0,0 +1,4 @@
+package infrastructure
+
+// AdminUserIds may browse the admin endpoints like the audit log, nobody by default
+var AdminUserIds = map[uint32]bool{}
@@ -0,0 +1,7 @@
+package infrastructure
+
+import "os"
+
+// AuthTokenSecret verifies the bearer tokens of logged in users, it is shared with the login
+// flow that signs them. Without it every request is anonymous
+var AuthTokenSecret = []byte(os.Getenv("EBAYCLONE_AUTH_SECRET"))
@@ -0,0 +1,13 @@
+package infrastructure
+
+import "time"
+
+// IdempotencyKeyWindow is how long a stored response is replayed for the same Idempotency-Key
+var IdempotencyKeyWindow = 24 * time.Hour
+
+// IdempotencyInFlightTimeout frees a key whose first request never finished, like when the
+// process died while running it, so retries are not refused with 409 for the whole window
+var IdempotencyInFlightTimeout = 5 * time.Minute
+
+// IdempotencySweepInterval is how often expired and stale keys are deleted
+var IdempotencySweepInterval = time.Hour
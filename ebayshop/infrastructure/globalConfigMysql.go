@@ -1,6 +1,11 @@
 package infrastructure
 
-import "github.com/go-sql-driver/mysql"
+import (
+	"time"
+
+	"ebayclone/repo"
+	"github.com/go-sql-driver/mysql"
+)
 
 var MysqlConfig *mysql.Config = &mysql.Config{
 	Addr:                 "localhost:3306",
@@ -10,4 +15,21 @@ var MysqlConfig *mysql.Config = &mysql.Config{
 	DBName:               "ebayclonedb",
 	AllowNativePasswords: true,
 	MultiStatements:      true,
+	ParseTime:            true,
+	ClientFoundRows:      true, // rows affected counts matched rows, updates with same values are not "not found"
+}
+
+// MysqlPoolConfig sizes the connection pool and the prepared statement cache shared by the services
+var MysqlPoolConfig *repo.PoolConfig = &repo.PoolConfig{
+	MaxOpenConns:    50,
+	MaxIdleConns:    10,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+	StmtCacheSize:   256,
+	QueryTimeout:    5 * time.Second,
+	ExecTimeout:     5 * time.Second,
+	// statements slower than this are logged as slow queries
+	SlowQueryThreshold: 500 * time.Millisecond,
+	// session keys and cached responses never reach the query logs
+	RedactColumns: []string{"SessionKey", "ResponseBody"},
 }
@@ -0,0 +1,10 @@
+package infrastructure
+
+import "os"
+
+// FakePaymentEnabled registers the in-process fake payment provider, only for development
+// and tests: it approves any payment. Set EBAYCLONE_FAKE_PAYMENT=true to enable it
+var FakePaymentEnabled = os.Getenv("EBAYCLONE_FAKE_PAYMENT") == "true"
+
+// FakePaymentSecret signs webhooks of the fake payment provider, from EBAYCLONE_FAKE_PAYMENT_SECRET
+var FakePaymentSecret = os.Getenv("EBAYCLONE_FAKE_PAYMENT_SECRET")
@@ -0,0 +1,5 @@
+package infrastructure
+
+// SearchMysqlFullTextFallback answers searches with MySQL FULLTEXT while the in-memory index
+// is still loading, it needs the FULLTEXT index on products.Name from backup/schema_updates.sql
+var SearchMysqlFullTextFallback = false
@@ -7,3 +7,8 @@ func PrintFlag(serviceName string, debug bool, message string) {
 		fmt.Printf("[ServiceName=%v], Log: %v\n", serviceName, message)
 	}
 }
+
+// Print logs what needs attention whatever the debug flag, like money left to refund
+func Print(serviceName string, message string) {
+	fmt.Printf("[ServiceName=%v], Log: %v\n", serviceName, message)
+}
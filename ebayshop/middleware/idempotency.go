@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/sha256"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/log_util"
+	"ebayclone/repo"
+	"ebayclone/service"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// bodyCaptureWriter keeps a copy of the response body to store it for replays
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func requestHash(context *gin.Context, body []byte) string {
+	h := sha256.New()
+	// the caller is part of the request, a key reused by another user is a different request
+	h.Write([]byte(context.Request.Method + " " + context.FullPath() + " " + strconv.FormatUint(uint64(repo.ActorOf(context.Request.Context())), 10) + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func abortWith(context *gin.Context, statusCode int, reason string) {
+	context.AbortWithStatusJSON(statusCode, &dto.BaseMessageResponse{
+		StatusCode:    statusCode,
+		ErrCodeString: reason,
+	})
+}
+
+// releaseKey frees the key so the client can retry, a key that can not be released
+// stays in flight until infrastructure.IdempotencyInFlightTimeout
+func releaseKey(ctx gocontext.Context, store *service.IdempotencyService, record *domain.IdempotencyKey) {
+	if err := store.Release(ctx, record); err != nil {
+		log_util.Print("Idempotency", fmt.Sprintf("release key [%v]: %v", record.IdemKey, err))
+	}
+}
+
+// Idempotency makes a route safe to retry: the first response for an Idempotency-Key is
+// stored and replayed for retries with the same body, a different body gets 422.
+// Requests without the header run as usual
+func Idempotency(store *service.IdempotencyService) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		key := context.GetHeader(HeaderIdempotencyKey)
+		if key == "" {
+			context.Next()
+			return
+		}
+		if len(key) > 128 {
+			abortWith(context, http.StatusBadRequest, "Idempotency-Key longer than 128")
+			return
+		}
+		body, err := context.GetRawData()
+		if err != nil {
+			abortWith(context, http.StatusBadRequest, "wrong format")
+			return
+		}
+		context.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(context, body)
+
+		record, created, err := store.Begin(context, key, context.FullPath(), hash)
+		if err != nil {
+			abortWith(context, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !created {
+			if record.RequestHash != hash {
+				abortWith(context, http.StatusUnprocessableEntity, "Idempotency-Key reused with another request body")
+				return
+			}
+			if record.StatusCode == 0 {
+				abortWith(context, http.StatusConflict, "request with this Idempotency-Key is still running")
+				return
+			}
+			context.Header("Idempotent-Replayed", "true")
+			context.Data(record.StatusCode, "application/json; charset=utf-8", []byte(record.ResponseBody))
+			context.Abort()
+			return
+		}
+
+		// the response is stored even when the client went away before it was written
+		storeCtx := gocontext.WithoutCancel(context.Request.Context())
+		defer func() {
+			// a panicking handler must not leave the key in flight, the recovery
+			// middleware still answers 500
+			if recovered := recover(); recovered != nil {
+				releaseKey(storeCtx, store, record)
+				panic(recovered)
+			}
+		}()
+		writer := &bodyCaptureWriter{ResponseWriter: context.Writer, body: &bytes.Buffer{}}
+		context.Writer = writer
+		context.Next()
+
+		// server errors are not stored, the client may retry them with the same key
+		if writer.Status() >= http.StatusInternalServerError {
+			releaseKey(storeCtx, store, record)
+			return
+		}
+		if err = store.Complete(storeCtx, record, writer.Status(), writer.body.String()); err != nil {
+			log_util.Print("Idempotency", fmt.Sprintf("complete key [%v]: %v", record.IdemKey, err))
+			releaseKey(storeCtx, store, record)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"ebayclone/auth"
+	"ebayclone/infrastructure"
+	"ebayclone/repo"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const HeaderAuthorization = "Authorization"
+
+// Identity verifies the bearer token of the request and carries its user in the request
+// context, services read it with repo.ActorOf and the repo stamps it into CreatedBy fields.
+// Requests without a token run anonymous, a token that does not verify gets 401
+func Identity() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		header := context.GetHeader(HeaderAuthorization)
+		if header == "" {
+			context.Next()
+			return
+		}
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			abortWith(context, http.StatusUnauthorized, "bearer token required")
+			return
+		}
+		userId, err := auth.VerifyUserToken(infrastructure.AuthTokenSecret, token, time.Now())
+		if err != nil {
+			abortWith(context, http.StatusUnauthorized, err.Error())
+			return
+		}
+		context.Request = context.Request.WithContext(repo.WithActor(context.Request.Context(), userId))
+		context.Next()
+	}
+}
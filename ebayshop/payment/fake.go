@@ -0,0 +1,105 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"ebayclone/valueobject"
+)
+
+const FakeProviderName = "fake"
+
+type fakePayment struct {
+	amount valueobject.Money
+	status Status
+}
+
+// FakeProvider keeps payments in memory and signs webhooks with HMAC-SHA256,
+// used by tests and local development instead of a real gateway
+type FakeProvider struct {
+	secret   []byte
+	lock     sync.Mutex
+	seq      uint64
+	payments map[string]*fakePayment
+
+	// DeclineAbove makes Authorize decline amounts greater than it, 0 accepts everything
+	DeclineAbove int64
+}
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:   []byte(secret),
+		payments: map[string]*fakePayment{},
+	}
+}
+
+func (f *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (f *FakeProvider) Authorize(ctx context.Context, req *AuthorizeRequest) (*Result, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.seq++
+	ref := fmt.Sprintf("fake_%v_%v", req.OrderId, f.seq)
+	if req.Amount.Amount <= 0 || (f.DeclineAbove > 0 && req.Amount.Amount > f.DeclineAbove) {
+		f.payments[ref] = &fakePayment{amount: req.Amount, status: StatusFailed}
+		return &Result{ProviderRef: ref, Status: StatusFailed}, ErrDeclined
+	}
+	f.payments[ref] = &fakePayment{amount: req.Amount, status: StatusAuthorized}
+	return &Result{ProviderRef: ref, Status: StatusAuthorized}, nil
+}
+
+func (f *FakeProvider) Capture(ctx context.Context, providerRef string, amount valueobject.Money) (*Result, error) {
+	return f.move(providerRef, amount, StatusAuthorized, StatusCaptured)
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, providerRef string, amount valueobject.Money) (*Result, error) {
+	return f.move(providerRef, amount, StatusCaptured, StatusRefunded)
+}
+
+func (f *FakeProvider) move(providerRef string, amount valueobject.Money, from Status, to Status) (*Result, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	payment, ok := f.payments[providerRef]
+	if !ok {
+		return nil, fmt.Errorf("%w [%v]", ErrUnknownPayment, providerRef)
+	}
+	if payment.status != from || amount != payment.amount {
+		return nil, fmt.Errorf("%w: [%v] is %v", ErrInvalidTransition, providerRef, payment.status)
+	}
+	payment.status = to
+	return &Result{ProviderRef: providerRef, Status: to}, nil
+}
+
+// SignWebhook builds the payload and signature the fake gateway would send for event
+func (f *FakeProvider) SignWebhook(event *WebhookEvent) ([]byte, string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, hex.EncodeToString(f.sign(payload)), nil
+}
+
+func (f *FakeProvider) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (f *FakeProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	received, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(received, f.sign(payload)) {
+		return nil, ErrInvalidSignature
+	}
+	event := &WebhookEvent{}
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
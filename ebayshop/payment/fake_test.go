@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"ebayclone/valueobject"
+)
+
+func TestFakeProviderLifecycle(t *testing.T) {
+	provider := NewFakeProvider("secret")
+	amount := valueobject.Money{Amount: 1250, Currency: "USD"}
+	ctx := context.Background()
+
+	authorized, err := provider.Authorize(ctx, &AuthorizeRequest{OrderId: 7, Amount: amount})
+	if err != nil || authorized.Status != StatusAuthorized {
+		t.Fatalf("authorize: %v %v", authorized, err)
+	}
+	if _, err = provider.Refund(ctx, authorized.ProviderRef, amount); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("refund before capture must fail, got %v", err)
+	}
+	if _, err = provider.Capture(ctx, authorized.ProviderRef, valueobject.Money{Amount: 1, Currency: "USD"}); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("capture of another amount must fail, got %v", err)
+	}
+	captured, err := provider.Capture(ctx, authorized.ProviderRef, amount)
+	if err != nil || captured.Status != StatusCaptured {
+		t.Fatalf("capture: %v %v", captured, err)
+	}
+	refunded, err := provider.Refund(ctx, authorized.ProviderRef, amount)
+	if err != nil || refunded.Status != StatusRefunded {
+		t.Fatalf("refund: %v %v", refunded, err)
+	}
+
+	provider.DeclineAbove = 1000
+	if _, err = provider.Authorize(ctx, &AuthorizeRequest{OrderId: 8, Amount: amount}); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("amount above limit must be declined, got %v", err)
+	}
+}
+
+func TestFakeProviderWebhookSignature(t *testing.T) {
+	provider := NewFakeProvider("secret")
+	event := &WebhookEvent{EventId: "evt_1", Type: EventCaptured, ProviderRef: "fake_1_1"}
+	payload, signature, err := provider.SignWebhook(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := provider.VerifyWebhook(payload, signature)
+	if err != nil || *verified != *event {
+		t.Fatalf("verify: %v %v", verified, err)
+	}
+	if _, err = NewFakeProvider("other").VerifyWebhook(payload, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("signature of another secret must fail, got %v", err)
+	}
+	payload[len(payload)-2] ^= 1
+	if _, err = provider.VerifyWebhook(payload, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered payload must fail, got %v", err)
+	}
+}
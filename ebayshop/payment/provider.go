@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"ebayclone/valueobject"
+)
+
+var (
+	ErrInvalidSignature  = errors.New("payment webhook signature invalid")
+	ErrUnknownPayment    = errors.New("payment unknown to provider")
+	ErrInvalidTransition = errors.New("payment can not do this in its current status")
+	ErrDeclined          = errors.New("payment declined")
+)
+
+type Status string
+
+const (
+	StatusAuthorized Status = "authorized"
+	StatusCaptured   Status = "captured"
+	StatusRefunded   Status = "refunded"
+	StatusFailed     Status = "failed"
+)
+
+type EventType string
+
+const (
+	EventCaptured EventType = "payment.captured"
+	EventFailed   EventType = "payment.failed"
+	EventRefunded EventType = "payment.refunded"
+)
+
+type AuthorizeRequest struct {
+	OrderId uint32
+	Amount  valueobject.Money
+}
+
+type Result struct {
+	ProviderRef string
+	Status      Status
+}
+
+// WebhookEvent is what a provider tells us asynchronously, EventId is unique per provider
+// and is how handling stays idempotent when the provider retries
+type WebhookEvent struct {
+	EventId     string            `json:"event_id"`
+	Type        EventType         `json:"type"`
+	ProviderRef string            `json:"provider_ref"`
+	Amount      valueobject.Money `json:"amount"`
+}
+
+// Provider is a payment gateway. Authorize holds the money, Capture takes it,
+// Refund gives it back and VerifyWebhook authenticates calls made by the gateway
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, req *AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, providerRef string, amount valueobject.Money) (*Result, error)
+	Refund(ctx context.Context, providerRef string, amount valueobject.Money) (*Result, error)
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]Provider{}
+)
+
+func Register(provider Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[provider.Name()] = provider
+}
+
+func Get(name string) (Provider, bool) {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	provider, ok := providers[name]
+	return provider, ok
+}
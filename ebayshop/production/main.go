@@ -2,15 +2,30 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"ebayclone/changeset"
 	"ebayclone/controller"
 	"ebayclone/domain"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/middleware"
+	"ebayclone/repo"
+	"ebayclone/service"
 	"ebayclone/valueobject"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout bounds how long requests in flight may finish once a signal arrives
+const shutdownTimeout = 10 * time.Second
+
 var globalResourceServiceConfig = map[string]bool{}
 
 func load_config_service() {
@@ -37,13 +52,61 @@ func load_config_service() {
 
 func main() {
 	engine := gin.Default()
+	// services get the gin context, with the fallback its Done follows the request
+	// so a client that disconnects cancels the queries of its request
+	engine.ContextWithFallback = true
 	changeset.CastValues(&domain.ProductType{}, map[string]any{
 		"Attributes": &valueobject.AttributesObjectRes{},
 	})
+	changeset.CastValues(&domain.Product{}, map[string]any{})
+	changeset.CastValues(&domain.ProductVariant{}, map[string]any{})
+	changeset.CastValues(&domain.SellerRating{}, map[string]any{})
+	changeset.CastValues(&domain.SavedSearch{}, map[string]any{})
+	repo.RegisterSchemas(domain.Schemas()...)
+	// every write of these is recorded in auditlogs, browsed at /api/admin/audit
+	repo.RegisterAuditLog(&domain.AuditLog{})
+	repo.RegisterAudited(&domain.ProductType{}, &domain.Product{})
 	load_config_service()
 	api_group := engine.Group("/api")
-	api_group.Use()
+	api_group.Use(middleware.Identity())
 	controller.InitProductTypeController(api_group, "/product_type", globalResourceServiceConfig["ProductTypeService"])
+	controller.InitInventoryController(api_group, "/inventory", globalResourceServiceConfig["InventoryService"])
 	controller.InitProductController(api_group, "/product", globalResourceServiceConfig["ProductService"])
-	engine.Run("localhost:8080")
+	controller.InitOrderController(api_group, "/order", globalResourceServiceConfig["OrderService"])
+	controller.InitCartController(api_group, "/cart", globalResourceServiceConfig["CartService"])
+	controller.InitPaymentController(api_group, "/payment", globalResourceServiceConfig["PaymentService"])
+	controller.InitWatchlistController(api_group, "/watchlist", globalResourceServiceConfig["WatchlistService"])
+	controller.InitNotificationController(api_group, "/notifications", globalResourceServiceConfig["NotificationService"])
+	controller.InitSellerController(api_group, "/seller", globalResourceServiceConfig["SellerService"])
+	controller.InitAdminController(api_group, "/admin", globalResourceServiceConfig["AuditService"])
+	// background jobs stop with the process signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go service.NewInventoryService(globalResourceServiceConfig["InventoryService"]).SweepExpiredReservations(ctx, service.ReservationSweepInterval)
+	go service.NewIdempotencyService(globalResourceServiceConfig["IdempotencyService"]).SweepExpiredKeys(ctx, infrastructure.IdempotencySweepInterval)
+	go service.NewSearchService(globalResourceServiceConfig["SearchService"]).BuildIndexUntilReady(ctx, service.SearchIndexRetryInterval)
+	notificationService := service.NewNotificationService(globalResourceServiceConfig["NotificationService"])
+	matcherDone := make(chan struct{})
+	go func() {
+		defer close(matcherDone)
+		notificationService.Run(ctx)
+	}()
+	// the signal no longer kills the process once NotifyContext catches it, the server
+	// is shut down here and main returns
+	server := &http.Server{Addr: "localhost:8080", Handler: engine}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log_util.Print("main", fmt.Sprintf("serve: %v", err))
+			stop()
+		}
+	}()
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log_util.Print("main", fmt.Sprintf("shutdown: %v", err))
+	}
+	// products created by the last requests are still queued for the matcher
+	<-matcherDone
+	notificationService.Drain(service.MatchDrainTimeout)
 }
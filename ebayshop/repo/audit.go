@@ -0,0 +1,210 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"ebayclone/changeset"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	AuditInsert = "insert"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditChange is the value of one field before and after a write, nil for a side that did not exist
+type AuditChange struct {
+	Before interface{}
+	After  interface{}
+}
+
+// AuditChanges are the changed fields of one write by field name
+type AuditChanges map[string]*AuditChange
+
+var auditedSchemas sync.Map // entity name -> reflect.Type
+
+// auditLogType is the schema audit logs are saved as, see RegisterAuditLog
+var auditLogType atomic.Pointer[reflect.Type]
+
+// RegisterAuditLog sets the schema every audit log is saved as, call it at startup before
+// writing an audited schema. It has the fields Entity string, EntityId, ActorId uint32,
+// Action string and Changes *AuditChanges
+func RegisterAuditLog(schema changeset.Schema) {
+	t := reflect.Indirect(reflect.ValueOf(schema)).Type()
+	auditLogType.Store(&t)
+}
+
+// RegisterAudited makes every Save, Update and delete of the schemas write an audit log,
+// call it at startup with RegisterAuditLog
+func RegisterAudited(schemas ...changeset.Schema) {
+	for _, schema := range schemas {
+		t := reflect.Indirect(reflect.ValueOf(schema)).Type()
+		auditedSchemas.Store(t.Name(), t)
+	}
+}
+
+// IsAudited tells if writes of the entity, a schema type name, are recorded
+func IsAudited(entity string) bool {
+	_, ok := auditedSchemas.Load(entity)
+	return ok
+}
+
+func audited(t reflect.Type) bool {
+	registered, ok := auditedSchemas.Load(t.Name())
+	return ok && registered.(reflect.Type) == t
+}
+
+// inTx runs write in a transaction of its own so the audit log is saved with the change
+func (r *Repo) inTx(ctx context.Context, write func(tx *sql.Tx) error) error {
+	tx := r.OpenTx(ctx)
+	if tx == nil {
+		return errors.New("can not open transaction")
+	}
+	if err := write(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockRows loads every field of the rows of t matching predicate, soft deleted ones only
+// withDeleted, and locks them until tx ends
+func (r *Repo) lockRows(ctx context.Context, tx *sql.Tx, t reflect.Type, predicate *Predicate, withDeleted bool) ([]reflect.Value, error) {
+	schema := reflect.New(t).Interface().(changeset.Schema)
+	// casting registers the json fields the scan has to decode
+	changeset.CastValues(schema, map[string]any{})
+	builder := selectSchema(r.GetById(schema), schema)
+	if withDeleted {
+		builder.WithDeleted()
+	}
+	builder.Where(predicate).ForUpdate()
+	query, args := builder.Query()
+	entities, err := r.RawQueryTx(ctx, tx, query, args, schema)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]reflect.Value, 0, len(entities))
+	for _, entity := range entities {
+		rows = append(rows, reflect.ValueOf(entity).Elem())
+	}
+	return rows, nil
+}
+
+// lockRowOf loads the row the changeset writes to, an invalid value when there is none
+func (r *Repo) lockRowOf(ctx context.Context, tx *sql.Tx, cs *changeset.ChangeSet) (reflect.Value, error) {
+	t := cs.ReflectSchema.Type()
+	id := P(ColumnName(cs.ReflectSchema.Interface(), "Id"), mappingOf(t).table, Equal, cs.ReflectSchema.FieldByName("Id").Interface())
+	rows, err := r.lockRows(ctx, tx, t, id, true)
+	if err != nil || len(rows) == 0 {
+		return reflect.Value{}, err
+	}
+	return rows[0], nil
+}
+
+// fieldValue is what a field holds in the audit, the key of the related row for a relation
+func fieldValue(row reflect.Value, field string, box *changeset.Box) interface{} {
+	value := row.FieldByName(field)
+	if box.UpdatedCol == "" {
+		return value.Interface()
+	}
+	if value.IsNil() {
+		return nil
+	}
+	return value.Elem().FieldByName(box.UpdatedCol).Interface()
+}
+
+// castedValue is the value a changeset writes to a field
+func castedValue(cs *changeset.ChangeSet, field string) interface{} {
+	box := cs.Boxes[field]
+	if box.UpdatedCol != "" {
+		// SetRelValues sets the box only, not the field
+		return box.GetVal()
+	}
+	return cs.ReflectSchema.FieldByName(field).Interface()
+}
+
+func sameJSON(a interface{}, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+// unaudited fields are left out of every audit log, UpdatedAt changes with every update
+func unaudited(box *changeset.Box) bool {
+	return box.HasOp(changeset.UpdatedAtOp) || box.HasOp(changeset.NotAuditedOp)
+}
+
+// updateChanges are the casted fields whose value differs from before
+func updateChanges(cs *changeset.ChangeSet, before reflect.Value) AuditChanges {
+	changes := AuditChanges{}
+	for _, field := range cs.CastedBoxes {
+		if unaudited(cs.Boxes[field]) {
+			continue
+		}
+		change := &AuditChange{After: castedValue(cs, field)}
+		if before.IsValid() {
+			change.Before = fieldValue(before, field, cs.Boxes[field])
+		}
+		if !sameJSON(change.Before, change.After) {
+			changes[field] = change
+		}
+	}
+	return changes
+}
+
+func insertChanges(cs *changeset.ChangeSet) AuditChanges {
+	changes := AuditChanges{}
+	for _, field := range cs.CastedBoxes {
+		if unaudited(cs.Boxes[field]) {
+			continue
+		}
+		changes[field] = &AuditChange{After: castedValue(cs, field)}
+	}
+	return changes
+}
+
+// deleteChanges keep every field of the deleted row
+func deleteChanges(boxes map[string]*changeset.Box, before reflect.Value) AuditChanges {
+	changes := AuditChanges{}
+	for field, box := range boxes {
+		if field == "Id" || unaudited(box) {
+			continue
+		}
+		changes[field] = &AuditChange{Before: fieldValue(before, field, box)}
+	}
+	return changes
+}
+
+// auditLogOf is the changeset of the audit log of one write to a row of t
+func auditLogOf(ctx context.Context, t reflect.Type, entityId interface{}, action string, changes AuditChanges) (*changeset.ChangeSet, error) {
+	logType := auditLogType.Load()
+	if logType == nil {
+		return nil, fmt.Errorf("repo: audited %v written before RegisterAuditLog", t.Name())
+	}
+	id, ok := entityId.(uint32)
+	if !ok {
+		return nil, fmt.Errorf("repo: audited %v needs an uint32 Id", t.Name())
+	}
+	return changeset.CastValues(reflect.New(*logType).Interface().(changeset.Schema), map[string]any{
+		"Entity":   t.Name(),
+		"EntityId": id,
+		"ActorId":  ActorOf(ctx),
+		"Action":   action,
+		"Changes":  &changes,
+	}), nil
+}
+
+// saveAudit writes the audit log of one write in tx
+func (r *Repo) saveAudit(ctx context.Context, tx *sql.Tx, t reflect.Type, entityId interface{}, action string, changes AuditChanges) error {
+	cs, err := auditLogOf(ctx, t, entityId, action, changes)
+	if err != nil {
+		return err
+	}
+	return r.SaveTx(ctx, cs, tx)
+}
@@ -0,0 +1,177 @@
+package repo
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type auditedItem struct {
+	Id    uint32
+	Name  string
+	Price uint32
+	Views uint32
+}
+
+func (a *auditedItem) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":    changeset.NewBox().Ops(changeset.AI),
+		"Name":  changeset.NewBox().Ops(changeset.NotNullable),
+		"Price": changeset.NewBox().Ops(changeset.NotNullable),
+		"Views": changeset.NewBox().Ops(changeset.Nullable, changeset.NotAuditedOp),
+	}
+}
+
+// auditLogRow is the audit log schema of the tests, the real one is domain.AuditLog
+type auditLogRow struct {
+	Id        uint32
+	Entity    string
+	EntityId  uint32
+	ActorId   uint32
+	Action    string
+	Changes   *AuditChanges
+	CreatedAt time.Time
+}
+
+func (a *auditLogRow) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":        changeset.NewBox().Ops(changeset.AI),
+		"Entity":    changeset.NewBox().Ops(changeset.NotNullable),
+		"EntityId":  changeset.NewBox().Ops(changeset.NotNullable),
+		"ActorId":   changeset.NewBox().Ops(changeset.Nullable),
+		"Action":    changeset.NewBox().Ops(changeset.NotNullable),
+		"Changes":   changeset.NewBox().Ops(changeset.NotNullable).JSONField(),
+		"CreatedAt": changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp),
+	}
+}
+
+func TestRegisterAudited(t *testing.T) {
+	RegisterAudited(&auditedItem{})
+	tests := []struct {
+		name   string
+		entity string
+		t      reflect.Type
+		want   bool
+	}{
+		{name: "registered", entity: "auditedItem", t: reflect.TypeOf(auditedItem{}), want: true},
+		{name: "not registered", entity: "listing", t: reflect.TypeOf(listing{}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAudited(tt.entity); got != tt.want {
+				t.Errorf("IsAudited(%q) = %v, want %v", tt.entity, got, tt.want)
+			}
+			if got := audited(tt.t); got != tt.want {
+				t.Errorf("audited(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before *auditedItem
+		values map[string]any
+		want   AuditChanges
+	}{
+		{
+			name:   "changed field",
+			before: &auditedItem{Id: 5, Name: "old", Price: 10},
+			values: map[string]any{"Name": "new"},
+			want:   AuditChanges{"Name": {Before: "old", After: "new"}},
+		},
+		{
+			name:   "same value is not a change",
+			before: &auditedItem{Id: 5, Name: "old", Price: 10},
+			values: map[string]any{"Name": "old", "Price": uint32(12)},
+			want:   AuditChanges{"Price": {Before: uint32(10), After: uint32(12)}},
+		},
+		{
+			name:   "not audited field",
+			before: &auditedItem{Id: 5, Name: "old", Views: 1},
+			values: map[string]any{"Views": uint32(2)},
+			want:   AuditChanges{},
+		},
+		{
+			name:   "missing row",
+			values: map[string]any{"Name": "new"},
+			want:   AuditChanges{"Name": {After: "new"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := changeset.CastValues(&auditedItem{Id: 5}, tt.values)
+			var before reflect.Value
+			if tt.before != nil {
+				before = reflect.ValueOf(tt.before).Elem()
+			}
+			if got := updateChanges(cs, before); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("updateChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateChangesSkipsUpdatedAt(t *testing.T) {
+	fixedStampNow(t)
+	cs := changeset.CastValues(&listing{Id: 1}, map[string]any{"Name": "b"})
+	stampUpdate(cs)
+	before := reflect.ValueOf(&listing{Id: 1, Name: "a", UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}).Elem()
+	want := AuditChanges{"Name": {Before: "a", After: "b"}}
+	if got := updateChanges(cs, before); !reflect.DeepEqual(got, want) {
+		t.Errorf("updateChanges() = %v, want %v", got, want)
+	}
+}
+
+func TestInsertAndDeleteChanges(t *testing.T) {
+	cs := changeset.CastValues(&auditedItem{}, map[string]any{"Name": "a", "Price": uint32(3), "Views": uint32(1)})
+	want := AuditChanges{"Name": {After: "a"}, "Price": {After: uint32(3)}}
+	if got := insertChanges(cs); !reflect.DeepEqual(got, want) {
+		t.Errorf("insertChanges() = %v, want %v", got, want)
+	}
+	row := reflect.ValueOf(&auditedItem{Id: 7, Name: "a", Price: 3, Views: 9}).Elem()
+	want = AuditChanges{"Name": {Before: "a"}, "Price": {Before: uint32(3)}}
+	if got := deleteChanges((&auditedItem{}).Validators(), row); !reflect.DeepEqual(got, want) {
+		t.Errorf("deleteChanges() = %v, want %v", got, want)
+	}
+}
+
+func TestAuditLogInsertQuery(t *testing.T) {
+	changes := AuditChanges{"Name": {Before: "a", After: "b"}}
+	itemType := reflect.TypeOf(auditedItem{})
+	auditLogType.Store(nil)
+	if _, err := auditLogOf(context.Background(), itemType, uint32(7), AuditUpdate, changes); err == nil {
+		t.Fatal("audit log saved before RegisterAuditLog")
+	}
+	RegisterAuditLog(&auditLogRow{})
+	t.Cleanup(func() { auditLogType.Store(nil) })
+	if _, err := auditLogOf(context.Background(), itemType, "7", AuditUpdate, changes); err == nil {
+		t.Fatal("audit log of a string id accepted")
+	}
+	cs, err := auditLogOf(WithActor(context.Background(), 2), itemType, uint32(7), AuditUpdate, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := cs.ReflectSchema.Addr().Interface().(*auditLogRow)
+	if log.Entity != "auditedItem" || log.EntityId != 7 || log.ActorId != 2 || log.Action != AuditUpdate {
+		t.Errorf("auditLogOf() = %+v", log)
+	}
+	stampInsert(context.Background(), cs)
+	query, args := (&Repo{}).insertQuery(cs)
+	if !strings.HasPrefix(query, "INSERT INTO `auditlogrows`") {
+		t.Fatalf("insertQuery() = %q", query)
+	}
+	found := false
+	for _, arg := range args {
+		if raw, ok := arg.([]byte); ok && string(raw) == `{"Name":{"Before":"a","After":"b"}}` {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("insertQuery() args = %v, want the changes as json", args)
+	}
+}
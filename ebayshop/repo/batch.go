@@ -0,0 +1,301 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"ebayclone/changeset"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// saveAllChunkSize is how many rows one INSERT of SaveAll writes at most
+const saveAllChunkSize = 500
+
+// maxPlaceholders is the most ? one prepared statement of MySQL can take
+const maxPlaceholders = 65535
+
+// upsertUpdatedId is added to the id an upsert reports for an updated row. Ids are
+// int unsigned, so an inserted row always reports less and the two can not be confused
+const upsertUpdatedId int64 = 1 << 32
+
+var ErrMixedChangeSets = errors.New("changesets of SaveAll must have the same schema and casted fields")
+
+// SaveAll inserts the changesets with multi-row INSERTs in one transaction,
+// see SaveAllTx
+func (r *Repo) SaveAll(ctx context.Context, css []*changeset.ChangeSet) error {
+	if len(css) == 0 {
+		return nil
+	}
+	tx := r.OpenTx(ctx)
+	if tx == nil {
+		return errors.New("can not open transaction")
+	}
+	if err := r.SaveAllTx(ctx, css, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveAllTx inserts the changesets in chunks of saveAllChunkSize rows, every changeset
+// must be of one schema with the same casted fields. Auto increment ids are set back
+// from the first id of each chunk, MySQL gives the rows of one INSERT with a known row
+// count consecutive ids as long as auto_increment_increment is 1
+func (r *Repo) SaveAllTx(ctx context.Context, css []*changeset.ChangeSet, tx *sql.Tx) error {
+	if len(css) == 0 {
+		return nil
+	}
+	for _, cs := range css {
+		stampInsert(ctx, cs)
+	}
+	if err := sameShape(css); err != nil {
+		return err
+	}
+	for _, cs := range css {
+		if err := validInsert(cs); err != nil {
+			return err
+		}
+	}
+	chunkSize := saveAllChunkSize
+	if columns := len(css[0].CastedBoxes); columns > 0 && chunkSize*columns > maxPlaceholders {
+		chunkSize = maxPlaceholders / columns
+	}
+	for start := 0; start < len(css); start += chunkSize {
+		end := start + chunkSize
+		if end > len(css) {
+			end = len(css)
+		}
+		chunk := css[start:end]
+		query, args := insertAllQuery(chunk)
+		result, err := r.exec(ctx, tx, query, args)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected != int64(len(chunk)) {
+			return fmt.Errorf("repo: SaveAll inserted %v of %v rows", affected, len(chunk))
+		}
+		firstId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for i, cs := range chunk {
+			if box, ok := cs.Boxes["Id"]; ok && box.GetOps()&(1<<changeset.AI) != 0 {
+				cs.ReflectSchema.FieldByName("Id").Set(reflect.ValueOf(uint32(firstId) + uint32(i)))
+			}
+			cs.ActionRepo = changeset.ActionInsert
+			if audited(cs.ReflectSchema.Type()) {
+				if err = r.saveAudit(ctx, tx, cs.ReflectSchema.Type(), cs.ReflectSchema.FieldByName("Id").Interface(), AuditInsert, insertChanges(cs)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sameShape checks every changeset writes the same columns of the same table,
+// casted fields may come in any order
+func sameShape(css []*changeset.ChangeSet) error {
+	first := css[0]
+	fields := map[string]bool{}
+	for _, col := range first.CastedBoxes {
+		fields[col] = true
+	}
+	for _, cs := range css[1:] {
+		if cs.ReflectSchema.Type() != first.ReflectSchema.Type() || len(cs.CastedBoxes) != len(fields) {
+			return ErrMixedChangeSets
+		}
+		for _, col := range cs.CastedBoxes {
+			if !fields[col] {
+				return ErrMixedChangeSets
+			}
+		}
+	}
+	return nil
+}
+
+// insertAllQuery is one INSERT with a row of values per changeset, columns are sorted by
+// field so the same shape always gives the same statement
+func insertAllQuery(css []*changeset.ChangeSet) (string, []interface{}) {
+	first := css[0]
+	schemaType := first.ReflectSchema.Type()
+	fields := append([]string{}, first.CastedBoxes...)
+	sort.Strings(fields)
+	columns := make([]string, 0, len(fields))
+	for _, col := range fields {
+		columns = append(columns, QuoteIdent(boxColumn(schemaType, col, first.Boxes[col])))
+	}
+	row := "(" + placeholders(len(columns)) + ")"
+	rows := make([]string, 0, len(css))
+	args := make([]interface{}, 0, len(css)*len(columns))
+	for _, cs := range css {
+		rows = append(rows, row)
+		for _, col := range fields {
+			args = append(args, cs.Boxes[col].GetVal())
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %v (%v) VALUES %v", QuoteIdent(mappingOf(schemaType).table), strings.Join(columns, ", "), strings.Join(rows, ", "))
+	return query, args
+}
+
+// UpsertOptions picks what an upsert changes when the row exists
+type UpsertOptions struct {
+	// ConflictFields are the fields of the unique key the row is matched by, they are never
+	// updated. MySQL matches on any unique key of the table, so the fields must form one
+	ConflictFields []string
+	// UpdateFields are overwritten with the inserted values. When both UpdateFields and
+	// IncrementFields are empty every casted field that is not a conflict field, CreatedAt
+	// or CreatedBy is overwritten
+	UpdateFields []string
+	// IncrementFields get the inserted value added to the value of the row, a counter
+	// is moved by concurrent upserts without reading it first
+	IncrementFields []string
+}
+
+// Upsert inserts the changeset or updates the row with the same unique key, see UpsertTx
+func (r *Repo) Upsert(ctx context.Context, cs *changeset.ChangeSet, options *UpsertOptions) error {
+	if audited(cs.ReflectSchema.Type()) {
+		return r.inTx(ctx, func(tx *sql.Tx) error {
+			return r.UpsertTx(ctx, cs, options, tx)
+		})
+	}
+	stampInsert(ctx, cs)
+	if err := validInsert(cs); err != nil {
+		return err
+	}
+	query, args, err := upsertQuery(cs, options)
+	if err != nil {
+		return err
+	}
+	result, err := r.exec(ctx, nil, query, args)
+	if err != nil {
+		return err
+	}
+	return upserted(result, cs)
+}
+
+// UpsertTx is INSERT ... ON DUPLICATE KEY UPDATE. The Id of the changeset is set for an
+// insert and for an update, ActionRepo tells which one happened. The audit log of an
+// audited schema has the written values only, the row before an update is not read
+func (r *Repo) UpsertTx(ctx context.Context, cs *changeset.ChangeSet, options *UpsertOptions, tx *sql.Tx) error {
+	stampInsert(ctx, cs)
+	if err := validInsert(cs); err != nil {
+		return err
+	}
+	query, args, err := upsertQuery(cs, options)
+	if err != nil {
+		return err
+	}
+	result, err := r.exec(ctx, tx, query, args)
+	if err != nil {
+		return err
+	}
+	if err = upserted(result, cs); err != nil || !audited(cs.ReflectSchema.Type()) {
+		return err
+	}
+	action := AuditInsert
+	if cs.ActionRepo == changeset.ActionUpdate {
+		action = AuditUpdate
+	}
+	return r.saveAudit(ctx, tx, cs.ReflectSchema.Type(), cs.ReflectSchema.FieldByName("Id").Interface(), action, insertChanges(cs))
+}
+
+func upsertQuery(cs *changeset.ChangeSet, options *UpsertOptions) (string, []interface{}, error) {
+	if options == nil {
+		options = &UpsertOptions{}
+	}
+	casted := map[string]bool{}
+	for _, col := range cs.CastedBoxes {
+		casted[col] = true
+	}
+	conflict := map[string]bool{}
+	for _, field := range options.ConflictFields {
+		if !casted[field] {
+			return "", nil, fmt.Errorf("repo: conflict field %v is not casted", field)
+		}
+		conflict[field] = true
+	}
+	updateFields := options.UpdateFields
+	if len(updateFields) == 0 && len(options.IncrementFields) == 0 {
+		for _, col := range cs.CastedBoxes {
+			// an updated row keeps who created it and when
+			if box := cs.Boxes[col]; box.HasOp(changeset.CreatedAtOp) || box.HasOp(changeset.CreatedByOp) {
+				continue
+			}
+			if !conflict[col] {
+				updateFields = append(updateFields, col)
+			}
+		}
+		sort.Strings(updateFields)
+	}
+	schemaType := cs.ReflectSchema.Type()
+	updates := []string{}
+	for _, field := range updateFields {
+		if !casted[field] || conflict[field] {
+			return "", nil, fmt.Errorf("repo: update field %v is not casted or is a conflict field", field)
+		}
+		column := QuoteIdent(boxColumn(schemaType, field, cs.Boxes[field]))
+		updates = append(updates, fmt.Sprintf("%v = VALUES(%v)", column, column))
+	}
+	updated := map[string]bool{}
+	for _, field := range updateFields {
+		updated[field] = true
+	}
+	for _, field := range options.IncrementFields {
+		if !casted[field] || conflict[field] || updated[field] {
+			return "", nil, fmt.Errorf("repo: increment field %v is not casted, is a conflict field or is updated", field)
+		}
+		column := QuoteIdent(boxColumn(schemaType, field, cs.Boxes[field]))
+		updates = append(updates, fmt.Sprintf("%v = %v + VALUES(%v)", column, column, column))
+	}
+	if box, ok := cs.Boxes["Id"]; ok && box.GetOps()&(1<<changeset.AI) != 0 {
+		// LAST_INSERT_ID(expr) makes the driver return the id of the updated row, shifted by
+		// upsertUpdatedId so upserted tells it from an insert. The id itself is unchanged
+		id := QuoteIdent(ColumnName(cs.ReflectSchema.Interface(), "Id"))
+		updates = append(updates, fmt.Sprintf("%v = LAST_INSERT_ID(%v + %v) - %v", id, id, upsertUpdatedId, upsertUpdatedId))
+	}
+	if len(updates) == 0 {
+		return "", nil, errors.New("repo: upsert has nothing to update")
+	}
+	query, args := insertAllQuery([]*changeset.ChangeSet{cs})
+	return query + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", "), args, nil
+}
+
+// upserted sets the id and the action. With an auto increment id the action comes from
+// the id, an update reports it shifted by upsertUpdatedId, so an update to the same values
+// is an update with or without ClientFoundRows. Without one only the row count is left:
+// MySQL counts 1 for an insert and 2 or 0 for an update, ClientFoundRows makes a no-op
+// update count 1 like an insert
+func upserted(result sql.Result, cs *changeset.ChangeSet) error {
+	if box, ok := cs.Boxes["Id"]; ok && box.GetOps()&(1<<changeset.AI) != 0 {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		cs.ActionRepo = changeset.ActionInsert
+		if id >= upsertUpdatedId {
+			id -= upsertUpdatedId
+			cs.ActionRepo = changeset.ActionUpdate
+		}
+		if id != 0 {
+			cs.ReflectSchema.FieldByName("Id").Set(reflect.ValueOf(uint32(id)))
+		}
+		return nil
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	cs.ActionRepo = changeset.ActionInsert
+	if affected != 1 {
+		cs.ActionRepo = changeset.ActionUpdate
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package repo
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInsertAllQuery(t *testing.T) {
+	css := []*changeset.ChangeSet{
+		changeset.CastValues(&registeredItem{}, map[string]any{"Name": "a", "OwnerRel": &Owner{Id: 1}}),
+		changeset.CastValues(&registeredItem{}, map[string]any{"OwnerRel": &Owner{Id: 2}, "Name": "b"}),
+	}
+	for i := 0; i < 10; i++ {
+		// casted fields come in map order, the columns must not
+		query, args := insertAllQuery(css)
+		if want := "INSERT INTO `registereditems` (`Name`, `OwnerId`) VALUES (?, ?), (?, ?)"; query != want {
+			t.Fatalf("query %v", query)
+		}
+		if !reflect.DeepEqual(args, []interface{}{"a", uint32(1), "b", uint32(2)}) {
+			t.Fatalf("args %#v", args)
+		}
+	}
+}
+
+func TestSaveAllRejectsMixedChangeSets(t *testing.T) {
+	css := []*changeset.ChangeSet{
+		changeset.CastValues(&registeredItem{}, map[string]any{"Name": "a"}),
+		changeset.CastValues(&registeredItem{}, map[string]any{"Name": "b", "OwnerRel": &Owner{Id: 2}}),
+	}
+	if err := (&Repo{}).SaveAllTx(context.Background(), css, nil); !errors.Is(err, ErrMixedChangeSets) {
+		t.Errorf("got %v", err)
+	}
+	if err := (&Repo{}).SaveAllTx(context.Background(), nil, nil); err != nil {
+		t.Errorf("empty SaveAll: %v", err)
+	}
+}
+
+func TestUpsertQuery(t *testing.T) {
+	cs := changeset.CastValues(&registeredItem{}, map[string]any{"Name": "a"})
+	query, args, err := upsertQuery(cs, &UpsertOptions{ConflictFields: []string{"Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "INSERT INTO `registereditems` (`Name`) VALUES (?) ON DUPLICATE KEY UPDATE `Id` = LAST_INSERT_ID(`Id` + 4294967296) - 4294967296"
+	if query != want || !reflect.DeepEqual(args, []interface{}{"a"}) {
+		t.Errorf("query\n got: %v %#v\nwant: %v", query, args, want)
+	}
+
+	cs = changeset.CastValues(&registeredItem{}, map[string]any{"Name": "a", "OwnerRel": &Owner{Id: 3}})
+	query, _, err = upsertQuery(cs, &UpsertOptions{ConflictFields: []string{"Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(query, " ON DUPLICATE KEY UPDATE `OwnerId` = VALUES(`OwnerId`), `Id` = LAST_INSERT_ID(`Id` + 4294967296) - 4294967296") {
+		t.Errorf("query %v", query)
+	}
+
+	for _, options := range []*UpsertOptions{
+		{ConflictFields: []string{"Missing"}},
+		{ConflictFields: []string{"Name"}, UpdateFields: []string{"Name"}},
+	} {
+		if _, _, err = upsertQuery(cs, options); err == nil {
+			t.Errorf("options %+v accepted", options)
+		}
+	}
+}
+
+type stockedItem struct {
+	Id      uint32
+	Name    string
+	Stock   uint32
+	Updates uint32
+}
+
+func (i *stockedItem) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":      changeset.NewBox().Ops(changeset.AI),
+		"Name":    changeset.NewBox().Ops(changeset.NotNullable),
+		"Stock":   changeset.NewBox().Ops(changeset.NotNullable),
+		"Updates": changeset.NewBox().Ops(changeset.NotNullable),
+	}
+}
+
+func TestUpsertQueryIncrement(t *testing.T) {
+	cs := changeset.CastValues(&stockedItem{}, map[string]any{"Name": "a", "Stock": uint32(2), "Updates": uint32(0)})
+	query, args, err := upsertQuery(cs, &UpsertOptions{ConflictFields: []string{"Name"}, IncrementFields: []string{"Stock"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the incremented field changes, Updates keeps the value of the row
+	want := "INSERT INTO `stockeditems` (`Name`, `Stock`, `Updates`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `Stock` = `Stock` + VALUES(`Stock`), `Id` = LAST_INSERT_ID(`Id` + 4294967296) - 4294967296"
+	if query != want || !reflect.DeepEqual(args, []interface{}{"a", uint32(2), uint32(0)}) {
+		t.Errorf("query\n got: %v %#v\nwant: %v", query, args, want)
+	}
+
+	for _, options := range []*UpsertOptions{
+		{ConflictFields: []string{"Name"}, IncrementFields: []string{"Name"}},
+		{ConflictFields: []string{"Name"}, UpdateFields: []string{"Stock"}, IncrementFields: []string{"Stock"}},
+		{ConflictFields: []string{"Name"}, IncrementFields: []string{"Missing"}},
+	} {
+		if _, _, err = upsertQuery(cs, options); err == nil {
+			t.Errorf("options %+v accepted", options)
+		}
+	}
+}
+
+type upsertResult struct{ id, affected int64 }
+
+func (r upsertResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r upsertResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+func TestUpserted(t *testing.T) {
+	tests := []struct {
+		name   string
+		result upsertResult
+		want   changeset.ActionRepo
+		wantId uint32
+	}{
+		{name: "insert", result: upsertResult{id: 7, affected: 1}, want: changeset.ActionInsert, wantId: 7},
+		{name: "update", result: upsertResult{id: upsertUpdatedId + 7, affected: 2}, want: changeset.ActionUpdate, wantId: 7},
+		{name: "same values", result: upsertResult{id: upsertUpdatedId + 7, affected: 0}, want: changeset.ActionUpdate, wantId: 7},
+		// ClientFoundRows counts a no-op update as 1 row, like an insert
+		{name: "same values with found rows", result: upsertResult{id: upsertUpdatedId + 7, affected: 1}, want: changeset.ActionUpdate, wantId: 7},
+		{name: "max id", result: upsertResult{id: upsertUpdatedId - 1, affected: 1}, want: changeset.ActionInsert, wantId: 1<<32 - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := changeset.CastValues(&registeredItem{}, map[string]any{"Name": "a"})
+			if err := upserted(tt.result, cs); err != nil {
+				t.Fatal(err)
+			}
+			if cs.ActionRepo != tt.want {
+				t.Errorf("action = %v, want %v", cs.ActionRepo, tt.want)
+			}
+			if id := cs.ReflectSchema.FieldByName("Id").Interface(); id != tt.wantId {
+				t.Errorf("id = %v, want %v", id, tt.wantId)
+			}
+		})
+	}
+}
+
+func TestValidInsert(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   string
+	}{
+		{name: "all not null fields", values: map[string]any{"Name": "a", "Price": uint32(3)}},
+		{name: "zero values are values", values: map[string]any{"Name": "", "Price": uint32(0)}},
+		// Views is nullable, casting it must not count for another field
+		{name: "missing name", values: map[string]any{"Price": uint32(3), "Views": uint32(1)}, want: "(Name)"},
+		{name: "missing both", values: map[string]any{"Views": uint32(1)}, want: "(Name, Price)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// boxes come in map order, one cast could pass by luck
+			for i := 0; i < 20; i++ {
+				err := validInsert(changeset.CastValues(&auditedItem{}, tt.values))
+				if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.HasSuffix(err.Error(), tt.want)) {
+					t.Fatalf("validInsert() = %v, want %q", err, tt.want)
+				}
+			}
+		})
+	}
+	cs := changeset.CastValues(&auditedItem{}, map[string]any{"Price": uint32(3)})
+	if err := (&Repo{}).SaveTx(context.Background(), cs, nil); err == nil {
+		t.Error("SaveTx inserted without a not null field")
+	}
+}
@@ -0,0 +1,92 @@
+package repo
+
+import "strings"
+
+const countAllName = "*"
+
+type groupBy struct {
+	cols   []*C
+	having *Predicate
+}
+
+func (g *groupBy) query(config ...*DefaultConfigQuery) (string, []interface{}) {
+	if len(g.cols) == 0 {
+		return "", nil
+	}
+	expressions := make([]string, 0, len(g.cols))
+	for _, col := range g.cols {
+		expressions = append(expressions, col.expression(col.table))
+	}
+	q := "GROUP BY " + strings.Join(expressions, ", ")
+	if g.having == nil {
+		return q, nil
+	}
+	havingQuery, args := g.having.render(exprLeaf)
+	return q + " HAVING " + havingQuery, args
+}
+
+func (g *groupBy) Append(querier Querier) Querier {
+	if other, ok := querier.(*groupBy); ok {
+		g.cols = append(g.cols, other.cols...)
+		g.having = And(g.having, other.having)
+	}
+	return g
+}
+
+func (q *QueryBuilder) grouping() *groupBy {
+	if q.groupBy == nil {
+		q.groupBy = &groupBy{}
+	}
+	return q.groupBy.(*groupBy)
+}
+
+// GroupBy groups rows by the columns, a date column groups by its converted value
+func (q *QueryBuilder) GroupBy(cols ...*C) *QueryBuilder {
+	group := q.grouping()
+	q.groupBy = &groupBy{cols: append(append([]*C{}, group.cols...), cols...), having: group.having}
+	return q
+}
+
+// Having filters groups, several calls are joined with AND. Predicates may name an
+// aggregate by its alias with an empty table: P("Revenue", "", repo.Greater, 100)
+func (q *QueryBuilder) Having(predicate *Predicate) *QueryBuilder {
+	group := q.grouping()
+	q.groupBy = &groupBy{cols: group.cols, having: And(group.having, predicate)}
+	return q
+}
+
+func aggregate(name string, c *C) *C {
+	cloned := *c
+	cloned.aggregate = name
+	return &cloned
+}
+
+// Count counts rows where the column is not null, Count(nil) is COUNT(*)
+func Count(c *C) *C {
+	if c == nil {
+		return &C{name: countAllName, aggregate: "COUNT"}
+	}
+	return aggregate("COUNT", c)
+}
+
+func CountDistinct(c *C) *C {
+	counted := aggregate("COUNT", c)
+	counted.distinct = true
+	return counted
+}
+
+func Sum(c *C) *C {
+	return aggregate("SUM", c)
+}
+
+func Avg(c *C) *C {
+	return aggregate("AVG", c)
+}
+
+func Min(c *C) *C {
+	return aggregate("MIN", c)
+}
+
+func Max(c *C) *C {
+	return aggregate("MAX", c)
+}
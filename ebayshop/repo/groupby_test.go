@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupByQuery(t *testing.T) {
+	cases := []struct {
+		name      string
+		build     func(builder *QueryBuilder)
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name: "aggregates",
+			build: func(builder *QueryBuilder) {
+				builder.
+					Select(Count(nil).As("Lines")).
+					Select(CountDistinct(Col("OrderId", "orderlines")).As("Orders")).
+					Select(Sum(Col("Quantity", "orderlines", IFNULLINT)).As("Quantity")).
+					Select(Avg(Col("UnitPriceAmount", "orderlines"))).
+					Select(Min(Col("UnitPriceAmount", "orderlines"))).
+					Select(Max(Col("UnitPriceAmount", "orderlines")))
+			},
+			wantQuery: " SELECT COUNT(*) AS `Lines`, COUNT(DISTINCT `orderlines`.`OrderId`) AS `Orders`, SUM(IFNULL(`orderlines`.`Quantity`, 0)) AS `Quantity`, AVG(`orderlines`.`UnitPriceAmount`), MIN(`orderlines`.`UnitPriceAmount`), MAX(`orderlines`.`UnitPriceAmount`) FROM `orderlines`",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name: "group by date with where having and order",
+			build: func(builder *QueryBuilder) {
+				day := Col("CreatedAt", "orderlines").As("Day")
+				builder.
+					SelectDate(day, NewDateTimeConverter("", "%Y-%m-%d")).
+					Select(Sum(Col("LineTotalAmount", "orderlines")).As("Revenue")).
+					Where(P("Currency", "orderlines", Equal, "EUR")).
+					GroupBy(day).
+					Having(P("Revenue", "", Greater, 100)).
+					OrderBy(Col("Revenue", ""), DESC)
+			},
+			wantQuery: " SELECT DATE_FORMAT(`orderlines`.`CreatedAt`, '%Y-%m-%d') AS `Day`, SUM(`orderlines`.`LineTotalAmount`) AS `Revenue` FROM `orderlines` WHERE `orderlines`.`Currency` = ? GROUP BY DATE_FORMAT(`orderlines`.`CreatedAt`, '%Y-%m-%d') HAVING `Revenue` > ? ORDER BY `Revenue` DESC",
+			wantArgs:  []interface{}{"EUR", 100},
+		},
+		{
+			name: "havings are joined with and",
+			build: func(builder *QueryBuilder) {
+				builder.
+					Select(Col("ProductId", "orderlines")).
+					GroupBy(Col("ProductId", "orderlines")).
+					Having(P("Lines", "", Greater, 1)).
+					Having(P("Lines", "", Less, 10))
+			},
+			wantQuery: " SELECT `orderlines`.`ProductId` FROM `orderlines` GROUP BY `orderlines`.`ProductId` HAVING `Lines` > ? AND `Lines` < ?",
+			wantArgs:  []interface{}{1, 10},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			builder := (&Repo{}).GetById(&orderLine{})
+			c.build(builder)
+			query, args := builder.Query()
+			if query != c.wantQuery {
+				t.Errorf("query\n got: %v\nwant: %v", query, c.wantQuery)
+			}
+			if !reflect.DeepEqual(args, c.wantArgs) {
+				t.Errorf("args\n got: %#v\nwant: %#v", args, c.wantArgs)
+			}
+		})
+	}
+}
+
+func TestAggregateDoesNotChangeColumn(t *testing.T) {
+	quantity := Col("Quantity", "orderlines")
+	Sum(quantity)
+	if quantity.expression("orderlines") != "`orderlines`.`Quantity`" {
+		t.Errorf("column changed by aggregate: %v", quantity.expression("orderlines"))
+	}
+}
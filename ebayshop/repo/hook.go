@@ -0,0 +1,302 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedactedArg replaces the args of redacted columns in query events
+const RedactedArg = "[REDACTED]"
+
+// QueryEvent describes one statement run by a Repo
+type QueryEvent struct {
+	Query string
+	// Args are the statement args with the redacted columns replaced by RedactedArg
+	Args     []interface{}
+	Start    time.Time
+	Duration time.Duration
+	// RowsAffected counts the rows written by a write or read by a select, -1 when unknown
+	RowsAffected int64
+	Err          error
+}
+
+// Hook observes every statement of a Repo. The context returned by BeforeQuery runs
+// the statement and is given to AfterQuery, a tracer can carry its span in it
+type Hook interface {
+	BeforeQuery(ctx context.Context, event *QueryEvent) context.Context
+	AfterQuery(ctx context.Context, event *QueryEvent)
+}
+
+// WithHooks returns a repo sharing the pool and the statements of r that also runs hooks
+func (r *Repo) WithHooks(hooks ...Hook) *Repo {
+	scoped := *r
+	scoped.hooks = append(append([]Hook{}, r.hooks...), hooks...)
+	return &scoped
+}
+
+// WithRedactedColumns returns a repo sharing the pool and the statements of r that also
+// hides the args of columns from its hooks
+func (r *Repo) WithRedactedColumns(columns ...string) *Repo {
+	scoped := *r
+	scoped.redacted = map[string]bool{}
+	for column := range r.redacted {
+		scoped.redacted[column] = true
+	}
+	for _, column := range columns {
+		scoped.redacted[strings.ToLower(column)] = true
+	}
+	return &scoped
+}
+
+// beforeQuery starts the event of a statement, nil when the repo has no hook
+func (r *Repo) beforeQuery(ctx context.Context, query string, args []interface{}) (context.Context, *QueryEvent) {
+	if len(r.hooks) == 0 {
+		return ctx, nil
+	}
+	event := &QueryEvent{
+		Query:        query,
+		Args:         r.redactArgs(query, args),
+		Start:        time.Now(),
+		RowsAffected: -1,
+	}
+	for _, hook := range r.hooks {
+		ctx = hook.BeforeQuery(ctx, event)
+	}
+	return ctx, event
+}
+
+// afterQuery ends the event, hooks are called in reverse order like deferred calls
+func (r *Repo) afterQuery(ctx context.Context, event *QueryEvent, rowsAffected int64, err error) {
+	if event == nil {
+		return
+	}
+	event.Duration = time.Since(event.Start)
+	event.RowsAffected = rowsAffected
+	event.Err = err
+	for i := len(r.hooks) - 1; i >= 0; i-- {
+		r.hooks[i].AfterQuery(ctx, event)
+	}
+}
+
+func (r *Repo) redactArgs(query string, args []interface{}) []interface{} {
+	redactedArgs := append([]interface{}{}, args...)
+	if len(r.redacted) == 0 {
+		return redactedArgs
+	}
+	for i, column := range argColumns(query) {
+		if i < len(redactedArgs) && r.redacted[strings.ToLower(column)] {
+			redactedArgs[i] = RedactedArg
+		}
+	}
+	return redactedArgs
+}
+
+// argColumns names the column each placeholder of query is compared with or written to,
+// "" when a placeholder has no column like LIMIT ?. Placeholders of a row comparison like
+// (`a`, `b`) < (?, ?) take the columns of the row in order
+func argColumns(query string) []string {
+	var insertColumns []string
+	if strings.HasPrefix(query, "INSERT INTO ") {
+		open, close := strings.Index(query, "("), strings.Index(query, ")")
+		if open > 0 && close > open {
+			for _, column := range strings.Split(query[open+1:close], ",") {
+				insertColumns = append(insertColumns, unquoteIdent(strings.TrimSpace(column)))
+			}
+		}
+	}
+	columns := []string{}
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '`', '\'':
+			// placeholders inside identifiers and string literals are not args
+			i = closingQuote(query, i)
+		case '(':
+			if len(insertColumns) > 0 {
+				continue
+			}
+			if row, end := rowColumns(query, i); row != nil {
+				for j := i; j < end; j++ {
+					if query[j] == '?' {
+						columns = append(columns, row[0])
+						row = row[1:]
+					}
+				}
+				i = end
+			}
+		case '?':
+			if len(insertColumns) > 0 {
+				columns = append(columns, insertColumns[len(columns)%len(insertColumns)])
+				continue
+			}
+			columns = append(columns, columnBefore(query[:i]))
+		}
+	}
+	return columns
+}
+
+// rowColumns gives the columns of the row compared with the placeholder row opening at open,
+// and the index of its closing parenthesis. nil when it is not a row of placeholders after a
+// row of columns, like IN (?, ?), or the two rows do not have the same length
+func rowColumns(query string, open int) ([]string, int) {
+	end := strings.IndexByte(query[open:], ')')
+	if end < 0 {
+		return nil, 0
+	}
+	end += open
+	placeholders := strings.Count(query[open:end], "?")
+	if placeholders == 0 || strings.Trim(query[open+1:end], "?, ") != "" {
+		return nil, 0
+	}
+	prefix := strings.TrimRight(query[:open], " ")
+	compared := strings.TrimRight(prefix, "=<>!")
+	if compared == prefix || !strings.HasSuffix(strings.TrimRight(compared, " "), ")") {
+		return nil, 0
+	}
+	compared = strings.TrimRight(compared, " ")
+	rowOpen := strings.LastIndex(compared, "(")
+	if rowOpen < 0 {
+		return nil, 0
+	}
+	row := strings.Split(compared[rowOpen+1:len(compared)-1], ",")
+	if len(row) != placeholders {
+		return nil, 0
+	}
+	for i, column := range row {
+		row[i] = columnBefore(column)
+	}
+	return row, end
+}
+
+// closingQuote is the index of the quote closing the one at open, doubled quotes are escapes
+func closingQuote(query string, open int) int {
+	quote := query[open]
+	for i := open + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i
+	}
+	return len(query)
+}
+
+// placeholderWords may stand between a column and its placeholder
+var placeholderWords = map[string]bool{"IN": true, "NOT": true, "LIKE": true, "BETWEEN": true, "AND": true, "IS": true}
+
+func columnBefore(prefix string) string {
+	for {
+		prefix = strings.TrimRight(prefix, " \t\n?,(=<>!")
+		if strings.HasSuffix(prefix, "`") {
+			open := strings.LastIndex(prefix[:len(prefix)-1], "`")
+			if open < 0 {
+				return ""
+			}
+			return unquoteIdent(prefix[open:])
+		}
+		word := strings.LastIndexFunc(prefix, func(c rune) bool {
+			return !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z')
+		})
+		if !placeholderWords[strings.ToUpper(prefix[word+1:])] {
+			return ""
+		}
+		prefix = prefix[:word+1]
+	}
+}
+
+func unquoteIdent(ident string) string {
+	if len(ident) >= 2 && ident[0] == '`' && ident[len(ident)-1] == '`' {
+		ident = strings.ReplaceAll(ident[1:len(ident)-1], "``", "`")
+	}
+	return ident
+}
+
+func (e *QueryEvent) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("sql", e.Query),
+		slog.Any("args", e.Args),
+		slog.Duration("duration", e.Duration),
+		slog.Int64("rows", e.RowsAffected),
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	return attrs
+}
+
+// LogHook logs every statement at debug level and failed ones at error level,
+// a nil Logger logs with slog.Default
+type LogHook struct {
+	Logger *slog.Logger
+}
+
+func (h *LogHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *LogHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if event.Err != nil {
+		logger.LogAttrs(ctx, slog.LevelError, "query failed", event.attrs()...)
+		return
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "query", event.attrs()...)
+}
+
+// SlowQueryHook warns about statements running for Threshold or longer
+type SlowQueryHook struct {
+	Threshold time.Duration
+	Logger    *slog.Logger
+}
+
+func (h *SlowQueryHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *SlowQueryHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	if event.Duration < h.Threshold {
+		return
+	}
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, "slow query", append(event.attrs(), slog.Duration("threshold", h.Threshold))...)
+}
+
+// Recorder keeps the events of every statement, tests read them with Events
+type Recorder struct {
+	mu     sync.Mutex
+	events []QueryEvent
+}
+
+func (h *Recorder) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *Recorder) AfterQuery(ctx context.Context, event *QueryEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, *event)
+}
+
+// Events returns the events recorded so far in the order the statements ended
+func (h *Recorder) Events() []QueryEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]QueryEvent{}, h.events...)
+}
+
+func (h *Recorder) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = nil
+}
@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArgColumns(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"INSERT INTO `users` (`Name`, `Password`) VALUES (?, ?), (?, ?)", []string{"Name", "Password", "Name", "Password"}},
+		{"UPDATE `users` SET `Password` = ?, `Name` = ? WHERE `Id` = ?", []string{"Password", "Name", "Id"}},
+		{"SELECT `users`.`Id` FROM `users` WHERE `users`.`Name` IN (?, ?) AND `users`.`Age` BETWEEN ? AND ? LIMIT ? OFFSET ?", []string{"Name", "Name", "Age", "Age", "", ""}},
+		{"SELECT `a?` FROM `t` WHERE `t`.`Note` = '?' AND `t`.`Id` != ?", []string{"Id"}},
+		{"SELECT `t`.`Id` FROM `t` WHERE (`t`.`CreatedAt`, `t`.`Id`) < (?, ?)", []string{"CreatedAt", "Id"}},
+		{"SELECT `t`.`Id` FROM `t` WHERE `t`.`Kind` = ? AND (`t`.`Token`, `t`.`Id`) >= (?, ?) LIMIT ?", []string{"Kind", "Token", "Id", ""}},
+		{"SELECT `t`.`Id` FROM `t` WHERE (`t`.`Token`, `t`.`Id`) IN ((?, ?))", []string{"", ""}},
+		{"SELECT `t`.`Id` FROM `t` WHERE (`t`.`A`, `t`.`B`) < (?, ?, ?)", []string{"", "", ""}},
+		{"DELETE FROM `t` WHERE `Id` = ?", []string{"Id"}},
+	}
+	for _, tt := range tests {
+		if got := argColumns(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v\ngot %q want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRedactArgsOfRowComparison(t *testing.T) {
+	r := (&Repo{}).WithRedactedColumns("sessionkey")
+	query := "SELECT `s`.`Id` FROM `s` WHERE (`s`.`SessionKey`, `s`.`Id`) > (?, ?) LIMIT ?"
+	got := r.redactArgs(query, []interface{}{"key", 7, 10})
+	if want := []interface{}{RedactedArg, 7, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestHooksSeeRedactedEvents(t *testing.T) {
+	db, _ := countingDB(t)
+	recorder := &Recorder{}
+	r := (&Repo{db: db, stmts: newStmtCache(2)}).WithRedactedColumns("password").WithHooks(recorder)
+	ctx := context.Background()
+	args := []interface{}{"ann", "secret"}
+	if _, err := r.exec(ctx, nil, "INSERT INTO `users` (`Name`, `Password`) VALUES (?, ?)", args); err != nil {
+		t.Fatal(err)
+	}
+	r.RawQuery(ctx, "SELECT `users`.`Id` FROM `users`", nil, &registeredItem{})
+
+	events := recorder.Events()
+	if len(events) != 2 {
+		t.Fatalf("events %v", events)
+	}
+	if !reflect.DeepEqual(events[0].Args, []interface{}{"ann", RedactedArg}) || events[0].RowsAffected != 1 || events[0].Err != nil {
+		t.Errorf("insert event %+v", events[0])
+	}
+	if args[1] != "secret" {
+		t.Errorf("redaction changed the statement args")
+	}
+	if events[1].Err == nil || events[1].RowsAffected != 0 {
+		t.Errorf("failed select event %+v", events[1])
+	}
+	recorder.Reset()
+	if len(recorder.Events()) != 0 {
+		t.Errorf("reset kept events")
+	}
+}
+
+// contextHook hands a value from BeforeQuery to AfterQuery through the context like a tracer
+type contextHook struct{ seen bool }
+
+type spanKey struct{}
+
+func (h *contextHook) BeforeQuery(ctx context.Context, event *QueryEvent) context.Context {
+	return context.WithValue(ctx, spanKey{}, event.Query)
+}
+
+func (h *contextHook) AfterQuery(ctx context.Context, event *QueryEvent) {
+	h.seen = ctx.Value(spanKey{}) == event.Query
+}
+
+func TestHookContext(t *testing.T) {
+	db, _ := countingDB(t)
+	hook := &contextHook{}
+	r := (&Repo{db: db, stmts: newStmtCache(2)}).WithHooks(hook)
+	if _, err := r.exec(context.Background(), nil, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !hook.seen {
+		t.Errorf("AfterQuery did not get the context of BeforeQuery")
+	}
+}
+
+func TestLogHooks(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	event := &QueryEvent{Query: "SELECT 1", Args: []interface{}{RedactedArg}, Duration: time.Second, RowsAffected: 1}
+	ctx := context.Background()
+	tests := []struct {
+		hook Hook
+		want string
+	}{
+		{&LogHook{Logger: logger}, "query"},
+		{&SlowQueryHook{Threshold: time.Second, Logger: logger}, "slow query"},
+		{&SlowQueryHook{Threshold: 2 * time.Second, Logger: logger}, ""},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		tt.hook.AfterQuery(tt.hook.BeforeQuery(ctx, event), event)
+		if tt.want == "" {
+			if out.Len() != 0 {
+				t.Errorf("logged %v", out.String())
+			}
+			continue
+		}
+		line := map[string]any{}
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("%v: %v", out.String(), err)
+		}
+		if line["msg"] != tt.want || line["sql"] != "SELECT 1" || line["rows"] != float64(1) ||
+			!strings.Contains(out.String(), RedactedArg) {
+			t.Errorf("logged %v", out.String())
+		}
+	}
+}
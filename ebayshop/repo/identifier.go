@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"ebayclone/changeset"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownIdentifier = errors.New("unknown identifier")
+
+// maxIdentifierLength is the longest table, column or alias name MySQL accepts
+const maxIdentifierLength = 64
+
+// schemaColumns holds the tables of the registered schemas and their columns,
+// ValidCol only accepts identifiers found here
+var (
+	schemaColumns   = map[string]map[string]bool{}
+	schemaColumnsMu sync.RWMutex
+)
+
+// RegisterSchemas records the tables and columns of the schemas, a relation box adds
+// its foreign key column like ProductTypeId, db tags and TableName are followed
+func RegisterSchemas(schemas ...changeset.Schema) {
+	schemaColumnsMu.Lock()
+	defer schemaColumnsMu.Unlock()
+	for _, schema := range schemas {
+		columns := map[string]bool{}
+		for field, box := range schema.Validators() {
+			columns[boxColumn(reflect.TypeOf(schema), field, box)] = true
+		}
+		schemaColumns[TableName(schema)] = columns
+	}
+}
+
+// ValidCol is Col for names that come from a request, like a sort or a field
+// selection: the table and the column must belong to a registered schema
+func ValidCol(name string, tb string, IfNullReplaceZero ...IFNULLTYPE) (*C, error) {
+	schemaColumnsMu.RLock()
+	columns, ok := schemaColumns[tb]
+	if ok {
+		ok = columns[name]
+	}
+	schemaColumnsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q.%q", ErrUnknownIdentifier, tb, name)
+	}
+	return Col(name, tb, IfNullReplaceZero...), nil
+}
+
+// ValidIdentifier accepts names of letters, digits, _ and $ up to 64 characters,
+// the $ is kept for aliases of relations like ProductRel$Id
+func ValidIdentifier(name string) error {
+	if name == "" || len(name) > maxIdentifierLength {
+		return fmt.Errorf("%w: %q", ErrUnknownIdentifier, name)
+	}
+	for _, r := range name {
+		if !(r == '_' || r == '$' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return fmt.Errorf("%w: %q", ErrUnknownIdentifier, name)
+		}
+	}
+	return nil
+}
+
+// QuoteIdent quotes a table, column or alias name, a backtick inside is doubled
+// so the name can never end the quoting
+func QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteColumn is `table`.`column`, or `column` for an alias of the select
+func quoteColumn(table string, column string) string {
+	if table == "" {
+		return QuoteIdent(column)
+	}
+	return QuoteIdent(table) + "." + QuoteIdent(column)
+}
+
+// quoteString quotes a literal written by the code into the query, like a date format
+func quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
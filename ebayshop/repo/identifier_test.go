@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"ebayclone/changeset"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type registeredItem struct {
+	Id       uint32
+	Name     string
+	OwnerRel *Owner
+}
+
+func (i *registeredItem) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":       changeset.NewBox().Ops(changeset.AI),
+		"Name":     changeset.NewBox().Ops(changeset.NotNullable),
+		"OwnerRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&Owner{}, "Id"),
+	}
+}
+
+type Owner struct {
+	Id uint32
+}
+
+func (o *Owner) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id": changeset.NewBox().Ops(changeset.AI),
+	}
+}
+
+// skeleton replaces every quoted identifier of the query with I, what is left
+// is the sql written by the builder itself
+func skeleton(t *testing.T, query string) string {
+	var out strings.Builder
+	for i := 0; i < len(query); i++ {
+		if query[i] != '`' {
+			out.WriteByte(query[i])
+			continue
+		}
+		i++
+		for ; ; i++ {
+			if i >= len(query) {
+				t.Fatalf("unterminated identifier in %q", query)
+			}
+			if query[i] == '`' {
+				if i+1 < len(query) && query[i+1] == '`' {
+					i++
+					continue
+				}
+				break
+			}
+		}
+		out.WriteString("I")
+	}
+	return out.String()
+}
+
+func TestValidCol(t *testing.T) {
+	RegisterSchemas(&registeredItem{})
+	for _, name := range []string{"Id", "Name", "OwnerId"} {
+		if _, err := ValidCol(name, "registereditems"); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+	for _, c := range [][2]string{{"OwnerRel", "registereditems"}, {"Id", "owners"}, {"Name`; DROP TABLE x; --", "registereditems"}} {
+		if _, err := ValidCol(c[0], c[1]); !errors.Is(err, ErrUnknownIdentifier) {
+			t.Errorf("%q.%q accepted", c[1], c[0])
+		}
+	}
+}
+
+func TestValidIdentifier(t *testing.T) {
+	for _, name := range []string{"Id", "ProductRel$Id", "r_1"} {
+		if err := ValidIdentifier(name); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a b", "a`b", "a.b", strings.Repeat("a", 65)} {
+		if err := ValidIdentifier(name); err == nil {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}
+
+func FuzzQuoteIdent(f *testing.F) {
+	for _, seed := range []string{"Id", "", "`", "a``b", "x` OR 1=1 --", "ProductRel$Id"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		quoted := QuoteIdent(name)
+		if skeleton(t, quoted) != "I" {
+			t.Fatalf("%q quoted as more than one identifier: %q", name, quoted)
+		}
+		if unquoted := strings.ReplaceAll(quoted[1:len(quoted)-1], "``", "`"); unquoted != name {
+			t.Fatalf("%q round trips to %q", name, unquoted)
+		}
+	})
+}
+
+func FuzzBuilderIdentifiers(f *testing.F) {
+	f.Add("Id", "products", "ProductRel$Id")
+	f.Add("a`b", "t` WHERE 1=1 --", "x` FROM y")
+	f.Add("", "", "")
+	f.Fuzz(func(t *testing.T, col string, table string, alias string) {
+		if table == "" {
+			// an empty table is an alias of the select, its skeleton has no table
+			table = "t"
+		}
+		builder := (&Repo{}).GetById(&product{})
+		builder.
+			Select(Col(col, table).As(alias)).
+			Where(P(col, table, Equal, 1)).
+			OrderBy(Col(col, table), DESC)
+		query, args := builder.Query()
+		want := " SELECT I.I AS I FROM I WHERE I.I = ? ORDER BY I.I DESC"
+		if alias == "" {
+			want = " SELECT I.I FROM I WHERE I.I = ? ORDER BY I.I DESC"
+		}
+		if got := skeleton(t, query); got != want {
+			t.Fatalf("identifiers escaped the quoting\nquery: %q\n  got: %q\n want: %q", query, got, want)
+		}
+		if !reflect.DeepEqual(args, []interface{}{1}) {
+			t.Fatalf("args %#v", args)
+		}
+	})
+}
+
+func FuzzBuilderValues(f *testing.F) {
+	f.Add("x")
+	f.Add("' OR '1'='1")
+	f.Add("`; DROP TABLE products; --")
+	f.Fuzz(func(t *testing.T, value string) {
+		build := func(value string) (string, []interface{}) {
+			builder := (&Repo{}).GetById(&product{})
+			builder.
+				Select(Col("Id", "products")).
+				Where(And(
+					P("Name", "products", Equal, value),
+					P("Name", "products", In, []string{value, value}),
+					P("Name", "products", Between, value, value),
+					P("Name", "products", ILike, value),
+				))
+			return builder.Query()
+		}
+		query, args := build(value)
+		// values only travel as args: the sql does not depend on them
+		if reference, _ := build("x"); query != reference {
+			t.Fatalf("value changed the sql\n got: %q\nwant: %q", query, reference)
+		}
+		if !reflect.DeepEqual(args, []interface{}{value, value, value, value, value, value}) {
+			t.Fatalf("args %#v", args)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorTimeFormat keeps the microseconds of a DATETIME(6) key, MySQL compares it as a datetime
+const cursorTimeFormat = "2006-01-02 15:04:05.999999"
+
+// cursor is the position after a row: the sort keys it was made for and their values
+type cursor struct {
+	Keys   string        `json:"k"`
+	Values []interface{} `json:"v"`
+}
+
+func (q *QueryBuilder) orderKeys() []*orderKey {
+	if o, ok := q.orderBy.(*orderBy); ok {
+		return o.keys
+	}
+	return nil
+}
+
+// keysSignature names the sort, a cursor of another sort is refused
+func keysSignature(keys []*orderKey) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, fmt.Sprintf("%v.%v:%v", key.table, key.name, key.orderType))
+	}
+	return strings.Join(names, ",")
+}
+
+// seekOp is > when every key is ascending and < when every key is descending,
+// a row comparison can not mix both
+func seekOp(keys []*orderKey) (PredicateOp, error) {
+	if len(keys) == 0 {
+		return 0, errors.New("keyset pagination needs OrderBy")
+	}
+	for _, key := range keys[1:] {
+		if key.orderType != keys[0].orderType {
+			return 0, errors.New("keyset pagination needs every sort key in the same direction")
+		}
+	}
+	if keys[0].orderType == DESC {
+		return Less, nil
+	}
+	return Greater, nil
+}
+
+// SeekAfter continues the sort after the row the token was made from with
+// WHERE (a, b) > (?, ?), an empty token starts at the first row.
+// Call it after every OrderBy, the last key should be unique like Id
+func (q *QueryBuilder) SeekAfter(token string) (*QueryBuilder, error) {
+	keys := q.orderKeys()
+	op, err := seekOp(keys)
+	if err != nil {
+		return q, err
+	}
+	if token == "" {
+		return q, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return q, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var c cursor
+	if err = decoder.Decode(&c); err != nil || c.Keys != keysSignature(keys) || len(c.Values) != len(keys) {
+		return q, ErrInvalidCursor
+	}
+	values := make([]interface{}, 0, len(c.Values))
+	for _, value := range c.Values {
+		if number, ok := value.(json.Number); ok {
+			if integer, err := number.Int64(); err == nil {
+				value = integer
+			} else if float, err := number.Float64(); err == nil {
+				value = float
+			}
+		}
+		values = append(values, value)
+	}
+	return q.Where(&Predicate{
+		kind:     exprLeaf,
+		op:       op.toString(),
+		opCode:   op,
+		val:      keys,
+		vals:     values,
+		prefixOp: PrefixAnd,
+	}), nil
+}
+
+// CursorOf is the token of a row scanned with this builder, pass it to SeekAfter
+// for the next page. Keys are read from the field of their alias or column name,
+// an alias like ProductRel$Id reads the field of the relation
+func (q *QueryBuilder) CursorOf(row interface{}) (string, error) {
+	keys := q.orderKeys()
+	if _, err := seekOp(keys); err != nil {
+		return "", err
+	}
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		name := key.name
+		if key.as != "" {
+			name = key.as
+		}
+		field := reflect.Indirect(reflect.ValueOf(row))
+		for _, part := range strings.Split(name, "$") {
+			if field.Kind() != reflect.Struct {
+				return "", fmt.Errorf("sort key %v not found in %T", name, row)
+			}
+			field = reflect.Indirect(field.FieldByName(fieldOfColumn(field.Type(), part)))
+			if !field.IsValid() {
+				return "", fmt.Errorf("sort key %v not found in %T", name, row)
+			}
+		}
+		value := field.Interface()
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC().Format(cursorTimeFormat)
+		}
+		values = append(values, value)
+	}
+	raw, err := json.Marshal(&cursor{Keys: keysSignature(keys), Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type pagedProduct struct {
+	Id        uint32
+	CreatedAt time.Time
+}
+
+func TestOrderByLimitOffset(t *testing.T) {
+	builder := (&Repo{}).GetById(&product{})
+	builder.
+		Select(Col("Id", "products")).
+		Where(P("Id", "products", Greater, 1)).
+		OrderBy(Col("CreatedAt", "products"), DESC).
+		OrderBy(Col("Id", "products"), ASC).
+		Limit(20).
+		Offset(40).
+		ForUpdate()
+	query, args := builder.Query()
+	want := " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` > ? ORDER BY `products`.`CreatedAt` DESC, `products`.`Id` ASC LIMIT ? OFFSET ? FOR UPDATE"
+	if query != want {
+		t.Errorf("query\n got: %v\nwant: %v", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1, uint64(20), uint64(40)}) {
+		t.Errorf("args %#v", args)
+	}
+}
+
+func pagedQuery() *QueryBuilder {
+	builder := (&Repo{}).GetById(&product{})
+	builder.
+		Select(Col("Id", "products")).
+		Select(Col("CreatedAt", "products")).
+		OrderBy(Col("CreatedAt", "products"), DESC).
+		OrderBy(Col("Id", "products"), DESC).
+		Limit(2)
+	return builder
+}
+
+func TestSeekAfterCursor(t *testing.T) {
+	last := &pagedProduct{Id: 9, CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	token, err := pagedQuery().CursorOf(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := pagedQuery()
+	if _, err = first.SeekAfter(""); err != nil {
+		t.Fatal(err)
+	}
+	if query, _ := first.Query(); query != " SELECT `products`.`Id`, `products`.`CreatedAt` FROM `products` ORDER BY `products`.`CreatedAt` DESC, `products`.`Id` DESC LIMIT ?" {
+		t.Errorf("first page %v", query)
+	}
+
+	next := pagedQuery()
+	if _, err = next.SeekAfter(token); err != nil {
+		t.Fatal(err)
+	}
+	query, args := next.Query()
+	want := " SELECT `products`.`Id`, `products`.`CreatedAt` FROM `products` WHERE (`products`.`CreatedAt`, `products`.`Id`) < (?, ?) ORDER BY `products`.`CreatedAt` DESC, `products`.`Id` DESC LIMIT ?"
+	if query != want {
+		t.Errorf("query\n got: %v\nwant: %v", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"2026-01-02 03:04:05", int64(9), uint64(2)}) {
+		t.Errorf("args %#v", args)
+	}
+}
+
+func TestSeekAfterRefusesCursor(t *testing.T) {
+	token, _ := pagedQuery().CursorOf(&pagedProduct{Id: 9})
+	other := (&Repo{}).GetById(&product{})
+	other.OrderBy(Col("Id", "products"), DESC)
+	for _, bad := range []string{"%%%", "e30", token} {
+		if _, err := other.SeekAfter(bad); err != ErrInvalidCursor {
+			t.Errorf("cursor %q: got %v", bad, err)
+		}
+	}
+	mixed := (&Repo{}).GetById(&product{})
+	mixed.OrderBy(Col("CreatedAt", "products"), DESC).OrderBy(Col("Id", "products"), ASC)
+	if _, err := mixed.SeekAfter(""); err == nil {
+		t.Error("mixed directions accepted")
+	}
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"ebayclone/changeset"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// TableNamer is implemented by schemas whose table is not the lowercased type name with an s
+type TableNamer interface {
+	TableName() string
+}
+
+// columnTag names the column of a field, on a relation it names the foreign key column:
+//
+//	SellerId       uint32       `db:"seller_id"`
+//	ProductTypeRel *ProductType `db:"product_type_id"`
+const columnTag = "db"
+
+// schemaMapping holds the names of one schema type in the database
+type schemaMapping struct {
+	table   string
+	columns map[string]string            // field -> column, only fields with a tag
+	fields  map[string]string            // column -> field, only fields with a tag
+	audit   map[changeset.FieldOp]string // audit op -> field of a schema
+}
+
+var mappings sync.Map // reflect.Type -> *schemaMapping
+
+func mappingOf(t reflect.Type) *schemaMapping {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if m, ok := mappings.Load(t); ok {
+		return m.(*schemaMapping)
+	}
+	m := &schemaMapping{
+		table:   strings.ToLower(t.Name()) + "s",
+		columns: map[string]string{},
+		fields:  map[string]string{},
+		audit:   map[changeset.FieldOp]string{},
+	}
+	if schema, ok := reflect.New(t).Interface().(changeset.Schema); ok {
+		for field, box := range schema.Validators() {
+			for _, op := range changeset.AuditOps {
+				if box.HasOp(op) {
+					m.audit[op] = field
+				}
+			}
+		}
+	}
+	if namer, ok := reflect.New(t).Interface().(TableNamer); ok {
+		m.table = namer.TableName()
+	}
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			column := field.Tag.Get(columnTag)
+			if column == "" || column == "-" {
+				continue
+			}
+			m.columns[field.Name] = column
+			m.fields[column] = field.Name
+		}
+	}
+	mappings.Store(t, m)
+	return m
+}
+
+// TableName is the table of a schema, from its TableName method or the lowercased type name with an s
+func TableName(schema interface{}) string {
+	return mappingOf(reflect.TypeOf(schema)).table
+}
+
+// ColumnName is the column of a field of the schema, from its db tag or the field name
+func ColumnName(schema interface{}, field string) string {
+	if column, ok := mappingOf(reflect.TypeOf(schema)).columns[field]; ok {
+		return column
+	}
+	return field
+}
+
+// boxColumn is the column a box is saved to, a relation saves the key of the related
+// schema into its foreign key column, by default the related type name with the key like ProductTypeId
+func boxColumn(t reflect.Type, field string, box *changeset.Box) string {
+	if column, ok := mappingOf(t).columns[field]; ok {
+		return column
+	}
+	if box != nil && box.UpdatedCol != "" {
+		return box.RelTbName + box.UpdatedCol
+	}
+	return field
+}
+
+// fieldOfColumn maps a scanned column back to its field, columns without a tag are field names
+func fieldOfColumn(t reflect.Type, column string) string {
+	if field, ok := mappingOf(t).fields[column]; ok {
+		return field
+	}
+	return column
+}
+
+// relSchemaType is the schema type behind a relation field, *T or []*T
+func relSchemaType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
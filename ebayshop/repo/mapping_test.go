@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"ebayclone/changeset"
+	"reflect"
+	"testing"
+)
+
+type legacyCategory struct {
+	Id uint32 `db:"category_id"`
+}
+
+func (c *legacyCategory) TableName() string { return "shop_category" }
+
+func (c *legacyCategory) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id": changeset.NewBox().Ops(changeset.AI),
+	}
+}
+
+type legacyListing struct {
+	Id          uint32          `db:"listing_id"`
+	Title       string          `db:"listing_title"`
+	Price       int64           // no tag, the column is the field name
+	CategoryRel *legacyCategory `db:"category_ref"`
+}
+
+func (l *legacyListing) TableName() string { return "shop_listing" }
+
+func (l *legacyListing) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":          changeset.NewBox().Ops(changeset.AI),
+		"Title":       changeset.NewBox().Ops(changeset.NotNullable),
+		"Price":       changeset.NewBox().Ops(changeset.NotNullable),
+		"CategoryRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&legacyCategory{}, "Id"),
+	}
+}
+
+func TestMappingNames(t *testing.T) {
+	if got := TableName(&legacyListing{}); got != "shop_listing" {
+		t.Errorf("table %v", got)
+	}
+	if got := TableName(&product{}); got != "products" {
+		t.Errorf("default table %v", got)
+	}
+	if got := ColumnName(&legacyListing{}, "Title"); got != "listing_title" {
+		t.Errorf("column %v", got)
+	}
+	if got := ColumnName(&legacyListing{}, "Price"); got != "Price" {
+		t.Errorf("default column %v", got)
+	}
+}
+
+func TestMappingWrites(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     func() (string, []interface{})
+		wantQuery string
+	}{
+		{
+			name: "insert column",
+			query: func() (string, []interface{}) {
+				return (&Repo{}).insertQuery(changeset.CastValues(&legacyListing{}, map[string]any{"Title": "lamp"}))
+			},
+			wantQuery: "INSERT INTO `shop_listing` (`listing_title`) VALUES (?)",
+		},
+		{
+			name: "insert foreign key",
+			query: func() (string, []interface{}) {
+				return (&Repo{}).insertQuery(changeset.CastValues(&legacyListing{}, map[string]any{"CategoryRel": &legacyCategory{Id: 2}}))
+			},
+			wantQuery: "INSERT INTO `shop_listing` (`category_ref`) VALUES (?)",
+		},
+		{
+			name: "update",
+			query: func() (string, []interface{}) {
+				return UpdateQuery(changeset.CastValues(&legacyListing{Id: 4}, map[string]any{"Price": int64(10)}))
+			},
+			wantQuery: "UPDATE `shop_listing` SET `Price` = ? WHERE `listing_id` = ?",
+		},
+		{
+			name: "delete",
+			query: func() (string, []interface{}) {
+				return DeleteQuery(changeset.CastValues(&legacyListing{Id: 4}, map[string]any{}))
+			},
+			wantQuery: "DELETE FROM `shop_listing` WHERE `listing_id` = ?",
+		},
+		{
+			name: "delete where",
+			query: func() (string, []interface{}) {
+				return deleteWhereQuery(&legacyListing{}, P("listing_title", "shop_listing", Equal, "lamp"))
+			},
+			wantQuery: "DELETE FROM `shop_listing` WHERE `shop_listing`.`listing_title` = ?",
+		},
+		{
+			name: "select with join",
+			query: func() (string, []interface{}) {
+				listing := &legacyListing{}
+				builder := selectSchema((&Repo{}).GetById(listing, JoinOne(listing, "CategoryRel", INNERJOIN)), listing)
+				return builder.Query()
+			},
+			wantQuery: " SELECT `shop_listing`.`category_ref` AS `CategoryRel$Id`, `shop_listing`.`listing_id`, `shop_listing`.`Price`, `shop_listing`.`listing_title` FROM `shop_listing` INNER JOIN `shop_category` ON `shop_listing`.`category_ref` = `shop_category`.`category_id`",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if query, _ := c.query(); query != c.wantQuery {
+				t.Errorf("query\n got: %v\nwant: %v", query, c.wantQuery)
+			}
+		})
+	}
+}
+
+func TestMappingScanNames(t *testing.T) {
+	listingType := reflect.TypeOf(legacyListing{})
+	if got := fieldOfColumn(listingType, "listing_title"); got != "Title" {
+		t.Errorf("field %v", got)
+	}
+	if got := fieldOfColumn(listingType, "Price"); got != "Price" {
+		t.Errorf("default field %v", got)
+	}
+	if got := fieldOfColumn(relSchemaType(reflect.TypeOf([]*legacyCategory{})), "category_id"); got != "Id" {
+		t.Errorf("relation field %v", got)
+	}
+}
@@ -1,34 +1,107 @@
 package repo
 
-import "fmt"
+import "strings"
 
 type OrderType uint8
+
 const (
 	DESC OrderType = iota + 1
 	ASC
 )
 
-type orderBy struct {
-	name string
-	table string
+type orderKey struct {
+	name      string
+	table     string
+	as        string
 	orderType OrderType
 }
 
+// column is an alias of the select when table is empty, like an aggregate of a group by
+func (k *orderKey) column(table string) string {
+	return quoteColumn(table, k.name)
+}
 
-func (o *orderBy) query(config ...*DefaultConfigQuery) (string, []interface{}){
-	if len(config) > 0 {
-		o.table = config[0].RenameTableAs
-	}
-	q := fmt.Sprintf("ORDER BY `%v`.`%v`", o.table, o.name)
-	if o.orderType == DESC {
-		q += " DESC"
+// orderBy holds the sort keys in the order they were added, the first key sorts first
+type orderBy struct {
+	keys []*orderKey
+}
+
+func (o *orderBy) query(config ...*DefaultConfigQuery) (string, []interface{}) {
+	if len(o.keys) == 0 {
+		return "", nil
 	}
-	if o.orderType == ASC {
-		q += " ASC"
+	keys := make([]string, 0, len(o.keys))
+	for _, key := range o.keys {
+		table := key.table
+		if len(config) > 0 {
+			table = config[0].RenameTableAs
+		}
+		q := key.column(table)
+		if key.orderType == DESC {
+			q += " DESC"
+		}
+		if key.orderType == ASC {
+			q += " ASC"
+		}
+		keys = append(keys, q)
 	}
-	return q, nil
+	return "ORDER BY " + strings.Join(keys, ", "), nil
 }
 
 func (o *orderBy) Append(querier Querier) Querier {
+	if other, ok := querier.(*orderBy); ok {
+		o.keys = append(o.keys, other.keys...)
+	}
 	return o
 }
+
+// limit renders LIMIT and OFFSET as bind args
+type limit struct {
+	count  *uint64
+	offset *uint64
+}
+
+// maxRows is the row count MySQL documents for an OFFSET without LIMIT
+const maxRows = uint64(18446744073709551615)
+
+func (l *limit) query(config ...*DefaultConfigQuery) (string, []interface{}) {
+	if l.count == nil && l.offset == nil {
+		return "", nil
+	}
+	count := maxRows
+	if l.count != nil {
+		count = *l.count
+	}
+	if l.offset == nil {
+		return "LIMIT ?", []interface{}{count}
+	}
+	return "LIMIT ? OFFSET ?", []interface{}{count, *l.offset}
+}
+
+func (l *limit) Append(querier Querier) Querier {
+	return l
+}
+
+func (q *QueryBuilder) limits() *limit {
+	if q.limit == nil {
+		return &limit{}
+	}
+	current := *q.limit.(*limit)
+	return &current
+}
+
+// Limit returns at most count rows
+func (q *QueryBuilder) Limit(count uint64) *QueryBuilder {
+	l := q.limits()
+	l.count = &count
+	q.limit = l
+	return q
+}
+
+// Offset skips rows, prefer SeekAfter for deep pages since skipped rows are still read
+func (q *QueryBuilder) Offset(offset uint64) *QueryBuilder {
+	l := q.limits()
+	l.offset = &offset
+	q.limit = l
+	return q
+}
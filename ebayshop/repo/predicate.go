@@ -0,0 +1,341 @@
+package repo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type PredicateOp uint
+
+func (p PredicateOp) toString() string {
+	if p == LessEqual {
+		return "<="
+	}
+	if p == Less {
+		return "<"
+	}
+	if p == GreaterEqual {
+		return ">="
+	}
+
+	if p == Greater {
+		return ">"
+	}
+
+	if p == Like {
+		return "LIKE"
+	}
+
+	if p == NotEqual {
+		return "!="
+	}
+	if p == ISNULL {
+		return "IS NULL"
+	}
+	if p == ISNOTNULL {
+		return "IS NOT NULL"
+	}
+	if p == In {
+		return "IN"
+	}
+	if p == NotIn {
+		return "NOT IN"
+	}
+	if p == Between {
+		return "BETWEEN"
+	}
+	if p == NotBetween {
+		return "NOT BETWEEN"
+	}
+	if p == ILike {
+		return "LIKE"
+	}
+	if p == EXISTS {
+		return "EXISTS"
+	}
+	if p == NOTEXISTS {
+		return "NOT EXISTS"
+	}
+	return "="
+}
+
+const (
+	LessEqual PredicateOp = iota
+	Less
+	GreaterEqual
+	Greater
+	Equal
+	Like
+	NotEqual
+	In
+	ISNULL
+	ISNOTNULL
+	NotIn
+	Between
+	NotBetween
+	ILike
+	EXISTS
+	NOTEXISTS
+)
+
+type PrefixOp uint8
+
+func (o *PrefixOp) ToOpString() string {
+	if (*o) == PrefixOr {
+		return "OR"
+	}
+	return "AND"
+}
+
+const (
+	PrefixAnd PrefixOp = iota
+	PrefixOr
+)
+
+type exprKind uint8
+
+const (
+	exprLeaf exprKind = iota
+	exprAnd
+	exprOr
+	exprNot
+)
+
+// Predicate is a node of a boolean expression tree: a comparison on a column (leaf),
+// or And, Or, Not of other predicates. Nodes are never changed once built,
+// so one predicate can be reused in many expressions and builders
+type Predicate struct {
+	kind     exprKind
+	children []*Predicate
+
+	// leaf only
+	col    string
+	table  string
+	op     string
+	opCode PredicateOp
+	val    interface{}
+	vals   []interface{}
+
+	// how QueryBuilder.Where joins this predicate to the wheres before it
+	prefixOp PrefixOp
+}
+
+// OrP is P joined with OR when passed to QueryBuilder.Where
+func OrP(col string, table string, op PredicateOp, val ...interface{}) *Predicate {
+	p := newPredicate(col, table, op, val)
+	p.prefixOp = PrefixOr
+	return p
+}
+
+// P is a predicate on a column. Most ops take one value, In and NotIn take a slice,
+// several values or a *QueryBuilder as subquery, Between and NotBetween take two values
+func P(col string, table string, op PredicateOp, val ...interface{}) *Predicate {
+	p := newPredicate(col, table, op, val)
+	p.prefixOp = PrefixAnd
+	return p
+}
+
+func newPredicate(col string, table string, op PredicateOp, val []interface{}) *Predicate {
+	if (op == Between || op == NotBetween) && len(val) != 2 {
+		panic(fmt.Sprintf("repo: %v on `%v`.`%v` needs 2 values, got %v", op.toString(), table, col, len(val)))
+	}
+	p := &Predicate{
+		kind:   exprLeaf,
+		col:    col,
+		table:  table,
+		op:     op.toString(),
+		opCode: op,
+		vals:   val,
+	}
+	if len(val) == 1 {
+		p.val = val[0]
+	}
+	return p
+}
+
+// Exists is true when the subquery returns a row, the subquery may use columns of the outer query
+func Exists(subquery *QueryBuilder) *Predicate {
+	return &Predicate{kind: exprLeaf, op: EXISTS.toString(), opCode: EXISTS, val: subquery}
+}
+
+func NotExists(subquery *QueryBuilder) *Predicate {
+	return &Predicate{kind: exprLeaf, op: NOTEXISTS.toString(), opCode: NOTEXISTS, val: subquery}
+}
+
+func combine(kind exprKind, predicates []*Predicate) *Predicate {
+	children := make([]*Predicate, 0, len(predicates))
+	for _, predicate := range predicates {
+		if predicate != nil {
+			children = append(children, predicate)
+		}
+	}
+	if len(children) == 1 {
+		return children[0]
+	}
+	return &Predicate{kind: kind, children: children}
+}
+
+// And is true when every predicate is true, nil predicates are skipped
+func And(predicates ...*Predicate) *Predicate {
+	return combine(exprAnd, predicates)
+}
+
+// Or is true when one of the predicates is true, nil predicates are skipped
+func Or(predicates ...*Predicate) *Predicate {
+	return combine(exprOr, predicates)
+}
+
+func Not(predicate *Predicate) *Predicate {
+	return &Predicate{kind: exprNot, children: []*Predicate{predicate}}
+}
+
+// render writes the expression with parentheses around every nested And or Or
+// of another kind than its parent, args follow the placeholders left to right
+func (p *Predicate) render(parent exprKind) (string, []interface{}) {
+	switch p.kind {
+	case exprNot:
+		clause, args := p.children[0].render(exprNot)
+		return "NOT " + clause, args
+	case exprAnd, exprOr:
+		if len(p.children) == 0 {
+			// an empty And holds, an empty Or does not
+			if p.kind == exprAnd {
+				return "1 = 1", nil
+			}
+			return "1 = 0", nil
+		}
+		joiner := " AND "
+		if p.kind == exprOr {
+			joiner = " OR "
+		}
+		clauses := make([]string, 0, len(p.children))
+		args := []interface{}{}
+		for _, child := range p.children {
+			clause, childArgs := child.render(p.kind)
+			clauses = append(clauses, clause)
+			args = append(args, childArgs...)
+		}
+		query := strings.Join(clauses, joiner)
+		if parent != exprLeaf && parent != p.kind {
+			query = "(" + query + ")"
+		}
+		return query, args
+	}
+	clause, args := p.renderLeaf()
+	if parent == exprNot {
+		return "(" + clause + ")", args
+	}
+	return clause, args
+}
+
+// Where is the WHERE clause of a builder, it holds the root of the expression
+type Where struct {
+	expr *Predicate
+}
+
+// Append joins the other where with AND
+func (w *Where) Append(querier Querier) Querier {
+	if other, check := querier.(*Where); check {
+		w.expr = And(w.expr, other.expr)
+	}
+	return w
+}
+
+func (w *Where) query(config ...*DefaultConfigQuery) (string, []interface{}) {
+	if w.expr == nil {
+		return "", nil
+	}
+	clause, args := w.expr.render(exprLeaf)
+	return "WHERE " + clause, args
+}
+
+// Where adds predicate to the wheres of q with AND, or with OR for a predicate made by OrP.
+// The wheres before are grouped, Where(a).Where(b).Where(OrP(c)) is (a AND b) OR c
+func (q *QueryBuilder) Where(predicate *Predicate) *QueryBuilder {
+	where, ok := q.Predicate.(*Where)
+	if !ok || where.expr == nil {
+		q.Predicate = &Where{expr: predicate}
+		return q
+	}
+	if predicate.prefixOp == PrefixOr {
+		q.Predicate = &Where{expr: Or(where.expr, predicate)}
+		return q
+	}
+	q.Predicate = &Where{expr: And(where.expr, predicate)}
+	return q
+}
+
+// Wheres is Where, kept for builders written when blocks of predicates needed their own call
+func (q *QueryBuilder) Wheres(predicate *Predicate) *QueryBuilder {
+	return q.Where(predicate)
+}
+
+// renderLeaf gives the sql of one comparison with its args in placeholder order
+func (p *Predicate) renderLeaf() (string, []interface{}) {
+	// an empty table names an alias of the select, used by Having on aggregates
+	column := quoteColumn(p.table, p.col)
+	if keys, ok := p.val.([]*orderKey); ok {
+		// a row comparison made by SeekAfter
+		columns := make([]string, 0, len(keys))
+		for _, key := range keys {
+			columns = append(columns, key.column(key.table))
+		}
+		return fmt.Sprintf("(%v) %v (%v)", strings.Join(columns, ", "), p.op, placeholders(len(p.vals))), p.vals
+	}
+	switch p.opCode {
+	case ISNULL, ISNOTNULL:
+		return fmt.Sprintf("%v %v", column, p.op), nil
+	case EXISTS, NOTEXISTS:
+		subquery, args := renderSubquery(p.val.(*QueryBuilder))
+		return fmt.Sprintf("%v (%v)", p.op, subquery), args
+	case In, NotIn:
+		if sub, ok := p.val.(*QueryBuilder); ok {
+			subquery, args := renderSubquery(sub)
+			return fmt.Sprintf("%v %v (%v)", column, p.op, subquery), args
+		}
+		values := expandValues(p.vals)
+		// IN () is not valid sql: nothing is in an empty list, everything is not in it
+		if len(values) == 0 {
+			if p.opCode == In {
+				return "1 = 0", nil
+			}
+			return "1 = 1", nil
+		}
+		return fmt.Sprintf("%v %v (%v)", column, p.op, placeholders(len(values))), values
+	case Between, NotBetween:
+		return fmt.Sprintf("%v %v ? AND ?", column, p.op), []interface{}{p.vals[0], p.vals[1]}
+	case ILike:
+		return fmt.Sprintf("LOWER(%v) LIKE LOWER(?)", column), []interface{}{p.val}
+	}
+	return fmt.Sprintf("%v %v ?", column, p.op), []interface{}{p.val}
+}
+
+// renderSubquery renders a copy, Query of a builder can only run once
+func renderSubquery(sub *QueryBuilder) (string, []interface{}) {
+	query, args := sub.CloneBuilder().Query()
+	return strings.TrimSpace(query), args
+}
+
+// expandValues flattens one slice or array argument into its elements, []byte stays one value
+func expandValues(vals []interface{}) []interface{} {
+	if len(vals) != 1 {
+		return vals
+	}
+	rv := reflect.ValueOf(vals[0])
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return vals
+	}
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return vals
+	}
+	values := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"reflect"
+	"testing"
+)
+
+type product struct{}
+type orderLine struct{}
+
+func productQuery(predicate *Predicate) *QueryBuilder {
+	builder := (&Repo{}).GetById(&product{})
+	builder.Select(Col("Id", "products")).Where(predicate)
+	return builder
+}
+
+func orderedProducts() *QueryBuilder {
+	sub := (&Repo{}).GetById(&orderLine{})
+	sub.Select(Col("ProductId", "orderlines")).Where(P("Quantity", "orderlines", Greater, 1))
+	return sub
+}
+
+func TestPredicateQuery(t *testing.T) {
+	cases := []struct {
+		name      string
+		predicate *Predicate
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "equal",
+			predicate: P("Id", "products", Equal, 7),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` = ?",
+			wantArgs:  []interface{}{7},
+		},
+		{
+			name:      "in expands a slice",
+			predicate: P("Id", "products", In, []uint32{1, 2, 3}),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` IN (?, ?, ?)",
+			wantArgs:  []interface{}{uint32(1), uint32(2), uint32(3)},
+		},
+		{
+			name:      "in takes variadic values",
+			predicate: P("Name", "products", In, "a", "b"),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Name` IN (?, ?)",
+			wantArgs:  []interface{}{"a", "b"},
+		},
+		{
+			name:      "in of empty slice matches nothing",
+			predicate: P("Id", "products", In, []uint32{}),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE 1 = 0",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:      "not in",
+			predicate: P("Id", "products", NotIn, []int{4, 5}),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` NOT IN (?, ?)",
+			wantArgs:  []interface{}{4, 5},
+		},
+		{
+			name:      "not in of empty slice matches everything",
+			predicate: P("Id", "products", NotIn, []int{}),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE 1 = 1",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:      "byte slice is one value",
+			predicate: P("Name", "products", In, []byte("ab")),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Name` IN (?)",
+			wantArgs:  []interface{}{[]byte("ab")},
+		},
+		{
+			name:      "between",
+			predicate: P("PriceAmount", "products", Between, 100, 200),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`PriceAmount` BETWEEN ? AND ?",
+			wantArgs:  []interface{}{100, 200},
+		},
+		{
+			name:      "not between",
+			predicate: P("PriceAmount", "products", NotBetween, 100, 200),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`PriceAmount` NOT BETWEEN ? AND ?",
+			wantArgs:  []interface{}{100, 200},
+		},
+		{
+			name:      "case insensitive like",
+			predicate: P("Name", "products", ILike, "%Phone%"),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE LOWER(`products`.`Name`) LIKE LOWER(?)",
+			wantArgs:  []interface{}{"%Phone%"},
+		},
+		{
+			name:      "is null has no arg",
+			predicate: P("Fields", "products", ISNULL),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Fields` IS NULL",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:      "in subquery",
+			predicate: P("Id", "products", In, orderedProducts()),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` IN (SELECT `orderlines`.`ProductId` FROM `orderlines` WHERE `orderlines`.`Quantity` > ?)",
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name:      "not exists",
+			predicate: NotExists(orderedProducts()),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE NOT EXISTS (SELECT `orderlines`.`ProductId` FROM `orderlines` WHERE `orderlines`.`Quantity` > ?)",
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name: "or groups inside and",
+			predicate: And(
+				Or(P("Id", "products", Equal, 1), P("Id", "products", Equal, 2)),
+				Or(P("Name", "products", Equal, "a"), P("Name", "products", Equal, "b")),
+			),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE (`products`.`Id` = ? OR `products`.`Id` = ?) AND (`products`.`Name` = ? OR `products`.`Name` = ?)",
+			wantArgs:  []interface{}{1, 2, "a", "b"},
+		},
+		{
+			name: "and groups inside or",
+			predicate: Or(
+				And(P("Id", "products", Greater, 1), P("Id", "products", Less, 5)),
+				P("Name", "products", Equal, "a"),
+			),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE (`products`.`Id` > ? AND `products`.`Id` < ?) OR `products`.`Name` = ?",
+			wantArgs:  []interface{}{1, 5, "a"},
+		},
+		{
+			name:      "nested and of the same kind is flattened",
+			predicate: And(P("Id", "products", Greater, 1), And(P("Id", "products", Less, 5), P("Name", "products", Equal, "a"))),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` > ? AND `products`.`Id` < ? AND `products`.`Name` = ?",
+			wantArgs:  []interface{}{1, 5, "a"},
+		},
+		{
+			name:      "not of a group",
+			predicate: Not(Or(P("Id", "products", Equal, 1), P("Id", "products", Equal, 2))),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE NOT (`products`.`Id` = ? OR `products`.`Id` = ?)",
+			wantArgs:  []interface{}{1, 2},
+		},
+		{
+			name:      "not of a leaf",
+			predicate: Not(P("Name", "products", Like, "a%")),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE NOT (`products`.`Name` LIKE ?)",
+			wantArgs:  []interface{}{"a%"},
+		},
+		{
+			name: "args follow placeholders across subquery",
+			predicate: And(
+				P("PriceCurrency", "products", Equal, "USD"),
+				P("Id", "products", In, orderedProducts()),
+				P("PriceAmount", "products", Between, 1, 9),
+			),
+			wantQuery: " SELECT `products`.`Id` FROM `products` WHERE `products`.`PriceCurrency` = ? AND `products`.`Id` IN (SELECT `orderlines`.`ProductId` FROM `orderlines` WHERE `orderlines`.`Quantity` > ?) AND `products`.`PriceAmount` BETWEEN ? AND ?",
+			wantArgs:  []interface{}{"USD", 1, 1, 9},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query, args := productQuery(c.predicate).Query()
+			if query != c.wantQuery {
+				t.Errorf("query\n got: %v\nwant: %v", query, c.wantQuery)
+			}
+			if !reflect.DeepEqual(args, c.wantArgs) {
+				t.Errorf("args\n got: %#v\nwant: %#v", args, c.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSubqueryRendersTwice(t *testing.T) {
+	sub := orderedProducts()
+	first, _ := productQuery(P("Id", "products", In, sub)).Query()
+	second, _ := productQuery(P("Id", "products", In, sub)).Query()
+	if first != second {
+		t.Errorf("subquery changed after rendering\nfirst: %v\nsecond: %v", first, second)
+	}
+}
+
+func TestBetweenNeedsTwoValues(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Between with one value did not panic")
+		}
+	}()
+	P("PriceAmount", "products", Between, 1)
+}
+
+func TestWhereChain(t *testing.T) {
+	builder := (&Repo{}).GetById(&product{})
+	builder.Select(Col("Id", "products")).
+		Where(P("Id", "products", Greater, 1)).
+		Where(P("Id", "products", Less, 5)).
+		Where(OrP("Name", "products", Equal, "a"))
+	query, args := builder.Query()
+	want := " SELECT `products`.`Id` FROM `products` WHERE (`products`.`Id` > ? AND `products`.`Id` < ?) OR `products`.`Name` = ?"
+	if query != want {
+		t.Errorf("query\n got: %v\nwant: %v", query, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1, 5, "a"}) {
+		t.Errorf("args got: %#v", args)
+	}
+}
+
+func TestPredicateReuse(t *testing.T) {
+	a := P("Id", "products", Equal, 1)
+	b := P("Id", "products", Equal, 2)
+	c := P("Name", "products", Equal, "c")
+	first, _ := productQuery(Or(a, b)).Query()
+	productQuery(And(a, c)).Query()
+	productQuery(And(Or(a, c), b)).Query()
+	again, _ := productQuery(Or(a, b)).Query()
+	if first != again {
+		t.Errorf("reused predicates changed the query\nfirst: %v\nagain: %v", first, again)
+	}
+}
+
+func TestCloneBuilderIsIndependent(t *testing.T) {
+	base := (&Repo{}).GetById(&product{})
+	base.Select(Col("Id", "products")).Where(P("Id", "products", Greater, 1))
+	clone := base.CloneBuilder()
+	clone.Select(Col("Name", "products")).Where(P("Name", "products", Equal, "a"))
+
+	query, args := base.Query()
+	want := " SELECT `products`.`Id` FROM `products` WHERE `products`.`Id` > ?"
+	if query != want || !reflect.DeepEqual(args, []interface{}{1}) {
+		t.Errorf("base changed by its clone\n got: %v %#v\nwant: %v", query, args, want)
+	}
+	query, args = clone.Query()
+	want = " SELECT `products`.`Id`, `products`.`Name` FROM `products` WHERE `products`.`Id` > ? AND `products`.`Name` = ?"
+	if query != want || !reflect.DeepEqual(args, []interface{}{1, "a"}) {
+		t.Errorf("clone\n got: %v %#v\nwant: %v", query, args, want)
+	}
+}
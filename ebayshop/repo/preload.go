@@ -0,0 +1,208 @@
+package repo
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// preloadChunkSize bounds the ids of one WHERE ... IN (...) query
+const preloadChunkSize = 1000
+
+// JoinOne is a GetById preload joining the to-one relation field of need, like
+// JoinOne(&domain.Product{}, "ProductTypeRel", LEFTJOIN). Select the columns of the
+// relation with As("ProductTypeRel$Name"). Joins suit to-one relations, use Preload
+// for to-many relations so the rows of the parent are not repeated per child
+func JoinOne(need changeset.Schema, field string, type_join TYPEJOIN) func() (to interface{}, fk string, pk string, inverse bool, type_join TYPEJOIN) {
+	box, ok := need.Validators()[field]
+	if !ok || box.UpdatedCol == "" {
+		panic(fmt.Sprintf("repo: %T has no to-one relation %v", need, field))
+	}
+	to := reflect.New(reflect.Indirect(reflect.ValueOf(need)).FieldByName(field).Type().Elem()).Interface()
+	fk := boxColumn(reflect.TypeOf(need), field, box)
+	pk := ColumnName(to, box.UpdatedCol)
+	return func() (interface{}, string, string, bool, TYPEJOIN) {
+		return to, fk, pk, true, type_join
+	}
+}
+
+// Preload fills relations of rows already scanned, rows hold pointers to one schema.
+// A path walks relations from the rows, "OrderLineRel.ProductRel" loads the lines of
+// orders and then the product of every line. Each hop is one WHERE ... IN (...) query:
+// a to-one relation (pointer field) by the ids its Rel holds, a to-many relation
+// (slice field) by the foreign key of the children to the parent table
+func (r *Repo) Preload(ctx context.Context, rows []interface{}, paths ...string) error {
+	for _, path := range paths {
+		if err := r.preloadPath(ctx, rows, strings.Split(path, ".")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repo) preloadPath(ctx context.Context, rows []interface{}, fields []string) error {
+	if len(rows) == 0 || len(fields) == 0 {
+		return nil
+	}
+	parentType := reflect.Indirect(reflect.ValueOf(rows[0])).Type()
+	field, ok := parentType.FieldByName(fields[0])
+	if !ok || !strings.HasSuffix(field.Name, "Rel") {
+		return fmt.Errorf("repo: %v has no relation %v", parentType.Name(), fields[0])
+	}
+	var loaded []interface{}
+	var err error
+	switch {
+	case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+		loaded, err = r.preloadToOne(ctx, rows, field)
+	case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Ptr:
+		loaded, err = r.preloadToMany(ctx, rows, parentType, field)
+	default:
+		err = fmt.Errorf("repo: relation %v.%v is neither a pointer nor a slice of pointers", parentType.Name(), field.Name)
+	}
+	if err != nil {
+		return err
+	}
+	return r.preloadPath(ctx, loaded, fields[1:])
+}
+
+func (r *Repo) preloadToOne(ctx context.Context, rows []interface{}, field reflect.StructField) ([]interface{}, error) {
+	ids := []interface{}{}
+	seen := map[interface{}]bool{}
+	for _, row := range rows {
+		rel := reflect.Indirect(reflect.ValueOf(row)).FieldByIndex(field.Index)
+		if rel.IsNil() {
+			continue
+		}
+		id := rel.Elem().FieldByName("Id")
+		if !id.IsValid() || id.IsZero() || seen[id.Interface()] {
+			continue
+		}
+		seen[id.Interface()] = true
+		ids = append(ids, id.Interface())
+	}
+	// a row keeps pointing to a soft deleted parent, like an order line to a deleted product
+	children, err := r.loadWhereIn(ctx, field.Type.Elem(), ColumnName(reflect.New(field.Type.Elem()).Interface(), "Id"), ids, true)
+	if err != nil {
+		return nil, err
+	}
+	byId := map[interface{}]reflect.Value{}
+	for _, child := range children {
+		value := reflect.ValueOf(child)
+		byId[value.Elem().FieldByName("Id").Interface()] = value
+	}
+	for _, row := range rows {
+		rel := reflect.Indirect(reflect.ValueOf(row)).FieldByIndex(field.Index)
+		if rel.IsNil() {
+			continue
+		}
+		if child, ok := byId[rel.Elem().FieldByName("Id").Interface()]; ok {
+			rel.Set(child)
+		}
+	}
+	return children, nil
+}
+
+func (r *Repo) preloadToMany(ctx context.Context, rows []interface{}, parentType reflect.Type, field reflect.StructField) ([]interface{}, error) {
+	childType := field.Type.Elem().Elem()
+	child, ok := reflect.New(childType).Interface().(changeset.Schema)
+	if !ok {
+		return nil, fmt.Errorf("repo: %v is not a schema", childType.Name())
+	}
+	// the relation of the child back to the parent names the foreign key column
+	backRel, fk := "", ""
+	for name, box := range child.Validators() {
+		if box.UpdatedCol != "" && box.RelTbName == parentType.Name() {
+			backRel, fk = name, boxColumn(childType, name, box)
+			break
+		}
+	}
+	if fk == "" {
+		return nil, fmt.Errorf("repo: %v has no relation to %v", childType.Name(), parentType.Name())
+	}
+	ids := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, reflect.Indirect(reflect.ValueOf(row)).FieldByName("Id").Interface())
+	}
+	children, err := r.loadWhereIn(ctx, childType, fk, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	byParent := map[interface{}]reflect.Value{}
+	for _, child := range children {
+		rel := reflect.ValueOf(child).Elem().FieldByName(backRel)
+		if rel.IsNil() {
+			continue
+		}
+		parentId := rel.Elem().FieldByName("Id").Interface()
+		list, ok := byParent[parentId]
+		if !ok {
+			list = reflect.MakeSlice(field.Type, 0, 1)
+		}
+		byParent[parentId] = reflect.Append(list, reflect.ValueOf(child))
+	}
+	for _, row := range rows {
+		parent := reflect.Indirect(reflect.ValueOf(row))
+		list, ok := byParent[parent.FieldByName("Id").Interface()]
+		if !ok {
+			list = reflect.MakeSlice(field.Type, 0, 0)
+		}
+		parent.FieldByIndex(field.Index).Set(list)
+	}
+	return children, nil
+}
+
+// loadWhereIn selects every column of the schema for rows where column is one of ids,
+// ordered by Id, in chunks of preloadChunkSize ids. Soft deleted rows are loaded only withDeleted
+func (r *Repo) loadWhereIn(ctx context.Context, schemaType reflect.Type, column string, ids []interface{}, withDeleted bool) ([]interface{}, error) {
+	schema, ok := reflect.New(schemaType).Interface().(changeset.Schema)
+	if !ok {
+		return nil, fmt.Errorf("repo: %v is not a schema", schemaType.Name())
+	}
+	// casting registers the json fields the scan has to decode
+	changeset.CastValues(schema, map[string]any{})
+	loaded := []interface{}{}
+	for start := 0; start < len(ids); start += preloadChunkSize {
+		end := start + preloadChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		builder := selectSchema(r.GetById(schema), schema)
+		if withDeleted {
+			builder.WithDeleted()
+		}
+		builder.
+			Where(P(column, builder.table, In, ids[start:end])).
+			OrderBy(Col(ColumnName(schema, "Id"), builder.table), ASC)
+		query, args := builder.Query()
+		entities, err := r.RawQuery(ctx, query, args, schema)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, entities...)
+	}
+	return loaded, nil
+}
+
+// selectSchema selects the columns of every box of the schema, a relation selects
+// its foreign key into the Rel like ProductRel$Id
+func selectSchema(builder *QueryBuilder, schema changeset.Schema) *QueryBuilder {
+	validators := schema.Validators()
+	fields := make([]string, 0, len(validators))
+	for field := range validators {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		box := validators[field]
+		column := boxColumn(reflect.TypeOf(schema), field, box)
+		if box.UpdatedCol != "" {
+			builder.Select(Col(column, builder.table).As(field + "$" + box.UpdatedCol))
+			continue
+		}
+		builder.Select(Col(column, builder.table))
+	}
+	return builder
+}
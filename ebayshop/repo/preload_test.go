@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"database/sql/driver"
+	"ebayclone/changeset"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJoinOneAndSelectSchema(t *testing.T) {
+	item := &registeredItem{}
+	builder := selectSchema((&Repo{}).GetById(item, JoinOne(item, "OwnerRel", LEFTJOIN)), item)
+	builder.Where(P("Id", "registereditems", In, []uint32{1, 2}))
+	query, _ := builder.Query()
+	want := " SELECT `registereditems`.`Id`, `registereditems`.`Name`, `registereditems`.`OwnerId` AS `OwnerRel$Id` FROM `registereditems` LEFT JOIN `owners` ON `registereditems`.`OwnerId` = `owners`.`Id` WHERE `registereditems`.`Id` IN (?, ?)"
+	if query != want {
+		t.Errorf("query\n got: %v\nwant: %v", query, want)
+	}
+}
+
+func TestJoinOneNeedsRelation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("JoinOne on a plain column did not panic")
+		}
+	}()
+	JoinOne(&registeredItem{}, "Name", LEFTJOIN)
+}
+
+type shelf struct {
+	Id      uint32
+	BookRel []*book
+}
+
+func (s *shelf) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id": changeset.NewBox().Ops(changeset.AI),
+	}
+}
+
+type book struct {
+	Id       uint32
+	Title    string
+	ShelfRel *shelf
+}
+
+func (b *book) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":       changeset.NewBox().Ops(changeset.AI),
+		"Title":    changeset.NewBox().Ops(changeset.NotNullable),
+		"ShelfRel": changeset.NewBox().Ops(changeset.NotNullable).SetEmbeddedClass(&shelf{}, "Id"),
+	}
+}
+
+// preloadDB answers the queries of books and shelves from fixed rows, args records
+// the ids every query asked for
+func preloadDB(t *testing.T, args *[][]driver.Value) *Repo {
+	db, d := countingDB(t)
+	d.results = func(query string, queryArgs []driver.Value) *cannedRows {
+		*args = append(*args, queryArgs)
+		if strings.Contains(query, "FROM `books`") {
+			return &cannedRows{
+				columns: []string{"Id", "ShelfRel$Id", "Title"},
+				values: [][]driver.Value{
+					{int64(10), int64(1), "a"},
+					{int64(11), int64(2), "b"},
+					{int64(12), int64(1), "c"},
+				},
+			}
+		}
+		return &cannedRows{
+			columns: []string{"Id"},
+			values:  [][]driver.Value{{int64(1)}, {int64(2)}},
+		}
+	}
+	return &Repo{db: db, stmts: newStmtCache(4)}
+}
+
+func TestPreloadToMany(t *testing.T) {
+	var args [][]driver.Value
+	r := preloadDB(t, &args)
+	shelves := []*shelf{{Id: 1}, {Id: 2}, {Id: 3}}
+	rows := []interface{}{shelves[0], shelves[1], shelves[2]}
+	if err := r.Preload(context.Background(), rows, "BookRel"); err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 1 {
+		t.Fatalf("queries = %v, want one for the relation", len(args))
+	}
+	want := map[uint32][]uint32{1: {10, 12}, 2: {11}, 3: {}}
+	for _, s := range shelves {
+		if s.BookRel == nil {
+			t.Errorf("shelf %v has a nil BookRel, a parent without children gets an empty slice", s.Id)
+			continue
+		}
+		got := []uint32{}
+		for _, b := range s.BookRel {
+			got = append(got, b.Id)
+			if b.ShelfRel == nil || b.ShelfRel.Id != s.Id {
+				t.Errorf("book %v of shelf %v points to %v", b.Id, s.Id, b.ShelfRel)
+			}
+		}
+		if !reflect.DeepEqual(got, want[s.Id]) {
+			t.Errorf("books of shelf %v = %v, want %v", s.Id, got, want[s.Id])
+		}
+	}
+}
+
+func TestPreloadToOne(t *testing.T) {
+	var args [][]driver.Value
+	r := preloadDB(t, &args)
+	books := []*book{
+		{Id: 10, ShelfRel: &shelf{Id: 1}},
+		{Id: 11, ShelfRel: &shelf{Id: 2}},
+		{Id: 12, ShelfRel: &shelf{Id: 1}},
+		{Id: 13},
+	}
+	rows := []interface{}{books[0], books[1], books[2], books[3]}
+	if err := r.Preload(context.Background(), rows, "ShelfRel"); err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 1 || !reflect.DeepEqual(args[0], []driver.Value{int64(1), int64(2)}) {
+		t.Fatalf("query args = %v, want one query for the distinct ids [1 2]", args)
+	}
+	if books[0].ShelfRel != books[2].ShelfRel {
+		t.Error("books of one shelf do not share the loaded shelf")
+	}
+	if books[1].ShelfRel.Id != 2 {
+		t.Errorf("book 11 points to shelf %v, want 2", books[1].ShelfRel.Id)
+	}
+	if books[3].ShelfRel != nil {
+		t.Errorf("book without shelf got %v", books[3].ShelfRel)
+	}
+}
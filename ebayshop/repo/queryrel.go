@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rel is one hop of a QueryRel: the rows of from joined to the rows of to on
+// from.fromKey = to.toKey. Selects and wheres of a hop may use any table joined so far
+type Rel struct {
+	from     string
+	to       string
+	as       string
+	fromKey  string
+	toKey    string
+	typeJoin TYPEJOIN
+	builder  *QueryBuilder
+}
+
+// NewRel joins to on from.fromKey = to.toKey with an INNER JOIN
+func NewRel(from string, fromKey string, to string, toKey string) *Rel {
+	return &Rel{
+		from:     from,
+		to:       to,
+		fromKey:  fromKey,
+		toKey:    toKey,
+		typeJoin: INNERJOIN,
+		builder:  &QueryBuilder{args: []interface{}{}},
+	}
+}
+
+// As names the joined table, needed when a table is joined twice.
+// Later hops and columns use the alias as table
+func (r *Rel) As(alias string) *Rel {
+	r.as = alias
+	return r
+}
+
+// Join changes the join of the hop, LEFTJOIN keeps rows of from without a match
+func (r *Rel) Join(type_join TYPEJOIN) *Rel {
+	r.typeJoin = type_join
+	return r
+}
+
+func (r *Rel) Select(col *C) *Rel {
+	r.builder.Select(col)
+	return r
+}
+
+func (r *Rel) Where(predicate *Predicate) *Rel {
+	r.builder.Where(predicate)
+	return r
+}
+
+// table is how columns of the hop name the joined table
+func (r *Rel) table() string {
+	if r.as != "" {
+		return r.as
+	}
+	return r.to
+}
+
+// QueryRel joins tables hop by hop, like order -> order line -> product -> product type.
+// The first hop starts from its from table, every later hop must start from a table
+// joined before. Selects of the hops are kept in hop order and their wheres are joined with AND
+type QueryRel struct {
+	rels []*Rel
+}
+
+func NewQueryRel(rels ...*Rel) *QueryRel {
+	return &QueryRel{rels: rels}
+}
+
+func (q *QueryRel) OpenRel(rel *Rel) *QueryRel {
+	q.rels = append(q.rels, rel)
+	return q
+}
+
+// Builder gives one QueryBuilder of the joins, so GroupBy, OrderBy and Limit can follow
+func (q *QueryRel) Builder() (*QueryBuilder, error) {
+	if len(q.rels) == 0 {
+		return nil, fmt.Errorf("repo: QueryRel without rels")
+	}
+	root := q.rels[0].from
+	joined := map[string]bool{root: true}
+	var query strings.Builder
+	query.WriteString("FROM " + QuoteIdent(root))
+	builder := &QueryBuilder{table: root, args: []interface{}{}}
+	predicates := []*Predicate{}
+	for i, rel := range q.rels {
+		if !joined[rel.from] {
+			return nil, fmt.Errorf("repo: hop %v starts from %v which is not joined before", i, rel.from)
+		}
+		if joined[rel.table()] {
+			return nil, fmt.Errorf("repo: hop %v joins %v twice, name it with As", i, rel.table())
+		}
+		joined[rel.table()] = true
+		query.WriteString(" " + rel.typeJoin.ToQueryString() + " " + QuoteIdent(rel.to))
+		if rel.as != "" {
+			query.WriteString(" AS " + QuoteIdent(rel.as))
+		}
+		query.WriteString(fmt.Sprintf(" ON %v = %v", quoteColumn(rel.from, rel.fromKey), quoteColumn(rel.table(), rel.toKey)))
+
+		if selector, ok := rel.builder.Projection.(*Selector); ok {
+			for _, col := range selector.cols {
+				builder.Select(col)
+			}
+		}
+		if where, ok := rel.builder.Predicate.(*Where); ok && where.expr != nil {
+			predicates = append(predicates, where.expr)
+		}
+	}
+	builder.query = query.String()
+	if len(predicates) > 0 {
+		// hops are always joined with AND, an OrP only groups inside its own hop
+		builder.Predicate = &Where{expr: And(predicates...)}
+	}
+	return builder, nil
+}
+
+// ParseToQuery renders the joins, use Builder to add group by, order or limit.
+// A chain Builder rejects is returned as its error
+func (q *QueryRel) ParseToQuery() (string, []interface{}, error) {
+	builder, err := q.Builder()
+	if err != nil {
+		return "", nil, err
+	}
+	query, args := builder.Query()
+	return query, args, nil
+}
@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden sql files of the tests")
+
+// checkGolden compares the sql and args with testdata/queryrel/name.golden
+func checkGolden(t *testing.T, name string, query string, args []interface{}) {
+	t.Helper()
+	got := fmt.Sprintf("%v\n%#v\n", query, args)
+	path := filepath.Join("testdata", "queryrel", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%v\n got: %v\nwant: %v", path, got, want)
+	}
+}
+
+func TestQueryRelGolden(t *testing.T) {
+	cases := []struct {
+		name  string
+		build func() *QueryBuilder
+	}{
+		{
+			name: "one_hop",
+			build: func() *QueryBuilder {
+				builder, _ := NewQueryRel(
+					NewRel("orders", "Id", "orderlines", "OrderId").
+						Select(Col("Id", "orders")).
+						Select(Col("Quantity", "orderlines")).
+						Where(P("UserId", "orders", Equal, 5)),
+				).Builder()
+				return builder
+			},
+		},
+		{
+			name: "order_to_product_type",
+			build: func() *QueryBuilder {
+				builder, _ := NewQueryRel(
+					NewRel("orders", "Id", "orderlines", "OrderId").
+						Select(Col("Id", "orders").As("OrderId")).
+						Where(P("Status", "orders", In, []string{"paid", "completed"})),
+				).
+					OpenRel(NewRel("orderlines", "ProductId", "products", "Id").
+						Select(Col("Name", "products").As("ProductName")).
+						Where(P("SellerId", "products", Equal, 7))).
+					OpenRel(NewRel("products", "ProductTypeId", "producttypes", "Id").
+						Select(Col("Name", "producttypes").As("ProductTypeName")).
+						Where(P("Name", "producttypes", Like, "phone%")).
+						Where(OrP("Name", "producttypes", Like, "tablet%"))).
+					Builder()
+				return builder
+			},
+		},
+		{
+			name: "report_with_group_by",
+			build: func() *QueryBuilder {
+				builder, _ := NewQueryRel(
+					NewRel("orderlines", "OrderId", "orders", "Id").
+						Where(P("Status", "orders", Equal, "completed")),
+					NewRel("orderlines", "ProductId", "products", "Id"),
+					NewRel("products", "ProductTypeId", "producttypes", "Id").Join(LEFTJOIN).
+						Select(Col("Name", "producttypes").As("ProductType")).
+						Select(Sum(Col("LineTotalAmount", "orderlines")).As("Revenue")),
+				).Builder()
+				builder.
+					GroupBy(Col("Name", "producttypes")).
+					Having(P("Revenue", "", Greater, 0)).
+					OrderBy(Col("Revenue", ""), DESC).
+					Limit(10)
+				return builder
+			},
+		},
+		{
+			name: "table_joined_twice",
+			build: func() *QueryBuilder {
+				builder, _ := NewQueryRel(
+					NewRel("reviews", "UserId", "watchlistitems", "UserId").As("watched").
+						Select(Col("Id", "reviews")),
+					NewRel("watched", "ProductId", "products", "Id").As("watched_products").
+						Select(Col("Name", "watched_products")).
+						Where(P("Id", "watched_products", NotEqual, 3)),
+				).Builder()
+				return builder
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			query, args := c.build().Query()
+			checkGolden(t, c.name, query, args)
+		})
+	}
+}
+
+func TestQueryRelRejectsBrokenChain(t *testing.T) {
+	if _, err := NewQueryRel().Builder(); err == nil {
+		t.Error("empty QueryRel accepted")
+	}
+	if _, err := NewQueryRel(
+		NewRel("orders", "Id", "orderlines", "OrderId"),
+		NewRel("products", "ProductTypeId", "producttypes", "Id"),
+	).Builder(); err == nil {
+		t.Error("hop from a table not joined accepted")
+	}
+	if _, err := NewQueryRel(
+		NewRel("orders", "Id", "orderlines", "OrderId"),
+		NewRel("orderlines", "OrderId", "orders", "Id"),
+	).Builder(); err == nil {
+		t.Error("table joined twice without alias accepted")
+	}
+	if query, args, err := NewQueryRel().ParseToQuery(); err == nil || query != "" || args != nil {
+		t.Errorf("ParseToQuery of an empty QueryRel = %q %v %v", query, args, err)
+	}
+}
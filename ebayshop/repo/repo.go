@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
 
 	"ebayclone/changeset"
 	"github.com/go-sql-driver/mysql"
@@ -17,17 +21,25 @@ type DefaultConfigQuery struct {
 	RenameTableAs string
 }
 
-var repo *Repo
+var (
+	repo       *Repo
+	repoMu     sync.Mutex
+	repoConfig sharedRepoConfig
+)
 
-func print(v string, debug bool) {
-	if debug {
-		fmt.Println(v)
-	}
+// sharedRepoConfig is what the shared repo was opened with, a later NewRepo must ask for the same
+type sharedRepoConfig struct {
+	dsn  string
+	pool PoolConfig
 }
 
 type Repo struct {
-	db    *sql.DB
-	debug bool
+	db       *sql.DB
+	debug    bool
+	stmts    *stmtCache
+	timeouts Timeouts
+	hooks    []Hook
+	redacted map[string]bool
 }
 
 type ErrCode uint32
@@ -36,12 +48,16 @@ const (
 	ErrCodeDuplicate ErrCode = iota + 1
 	ErrCodeNotFoundParentKey
 	ErrCodeNotFoundUpdateIdEntity
+	ErrCodeTimeout
 	OtherErrCode
 )
 
 var customPrefixUpdateNotFound = "Error Update Custom: Not Found Id"
 
 func GetErrCode(myErr error) ErrCode {
+	if errors.Is(myErr, context.DeadlineExceeded) {
+		return ErrCodeTimeout
+	}
 	if strings.HasPrefix(myErr.Error(), "Error 1062") {
 		return ErrCodeDuplicate
 	}
@@ -53,21 +69,65 @@ func GetErrCode(myErr error) ErrCode {
 	}
 	return OtherErrCode
 }
-func NewRepo(config *mysql.Config, debug bool) *Repo {
+
+// NewRepo gives a service the repo shared by every service, DefaultPoolConfig when no pool
+// config is given. A debug service gets a scoped copy logging its statements, see WithDebugLog.
+// Later calls must give the same dsn and pool config, it panics when they do not: the pool,
+// the cache and the hooks exist once and a service can not have its own
+func NewRepo(config *mysql.Config, debug bool, pool ...*PoolConfig) *Repo {
+	shared := openSharedRepo(config, pool...)
+	if shared == nil || !debug {
+		return shared
+	}
+	return shared.WithDebugLog()
+}
+
+// WithDebugLog returns a repo sharing the pool and the statements of r that logs every
+// statement to stdout
+func (r *Repo) WithDebugLog() *Repo {
+	scoped := r.WithHooks(&LogHook{
+		Logger: slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	scoped.debug = true
+	return scoped
+}
+
+func openSharedRepo(config *mysql.Config, pool ...*PoolConfig) *Repo {
+	poolConfig := DefaultPoolConfig
+	if len(pool) > 0 && pool[0] != nil {
+		poolConfig = pool[0]
+	}
+	wanted := sharedRepoConfig{dsn: config.FormatDSN(), pool: *poolConfig}
+	repoMu.Lock()
+	defer repoMu.Unlock()
 	if repo != nil {
+		if !reflect.DeepEqual(wanted, repoConfig) {
+			panic("repo: NewRepo called with another config than the shared repo was opened with")
+		}
 		return repo
 	}
-	db, err := sql.Open("mysql", config.FormatDSN())
+	db, err := sql.Open("mysql", wanted.dsn)
 	if err != nil {
 		//replace fmt.Println
 		return nil
 	}
-	//replace fmt.Println
-	//replace fmt.Println
+	db.SetMaxOpenConns(poolConfig.MaxOpenConns)
+	db.SetMaxIdleConns(poolConfig.MaxIdleConns)
+	db.SetConnMaxLifetime(poolConfig.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(poolConfig.ConnMaxIdleTime)
 	repo = &Repo{
 		db:    db,
-		debug: debug,
+		stmts: newStmtCache(poolConfig.StmtCacheSize),
+		timeouts: Timeouts{
+			Query: poolConfig.QueryTimeout,
+			Exec:  poolConfig.ExecTimeout,
+		},
+	}
+	if poolConfig.SlowQueryThreshold > 0 {
+		repo.hooks = append(repo.hooks, &SlowQueryHook{Threshold: poolConfig.SlowQueryThreshold})
 	}
+	repo = repo.WithRedactedColumns(poolConfig.RedactColumns...)
+	repoConfig = wanted
 	return repo
 }
 
@@ -90,6 +150,8 @@ type C struct {
 	isDateType        bool
 	dateTimeConverter *DateTimeConverter
 	ifNullType        IFNULLTYPE
+	aggregate         string
+	distinct          bool
 }
 
 func Col(name string, tb string, IfNullReplaceZero ...IFNULLTYPE) *C {
@@ -133,43 +195,14 @@ func (s *Selector) query(config ...*DefaultConfigQuery) (string, []interface{})
 				col.as = ""
 			}
 		}
-
-		if col.dateTimeConverter != nil {
-			col_converted := fmt.Sprintf("`%v`.`%v`", tempRename, col.name)
-			date_select_query := ""
-			if col.dateTimeConverter.TimeZoneConvertFormat != "" {
-				date_select_query = fmt.Sprintf("CONVERT_TZ(%v, '+00:00', '%v')", col_converted, col.dateTimeConverter.TimeZoneConvertFormat)
-			}
-			if col.dateTimeConverter.DateFormat != "" {
-				if date_select_query != "" {
-					date_select_query = fmt.Sprintf("DATE_FORMAT(%v, '%v')", date_select_query, col.dateTimeConverter.DateFormat)
-				} else {
-					date_select_query = fmt.Sprintf("DATE_FORMAT(%v, '%v')", col_converted, col.dateTimeConverter.DateFormat)
-				}
-			}
-
-			// reset col_converted
-			col_converted = col.name
-			if col.as != "" {
-
-				col_converted = col.as
-			}
-			q += fmt.Sprintf("%v AS %v", date_select_query, col_converted)
-		} else {
-			if col.ifNullType > 0 {
-				if col.ifNullType == IFNULLINT {
-					q += fmt.Sprintf("IFNULL(`%v`.`%v`, 0)", tempRename, col.name)
-				}
-				if col.ifNullType == IFNULLSTR {
-					q += fmt.Sprintf("IFNULL(`%v`.`%v`, '')", tempRename, col.name)
-				}
-			} else {
-				q += fmt.Sprintf("`%v`.`%v`", tempRename, col.name)
-			}
-		}
-		if col.as != "" && col.dateTimeConverter == nil {
+		q += col.expression(tempRename)
+		if col.as != "" {
+			q += " AS "
+			q += QuoteIdent(col.as)
+		} else if col.dateTimeConverter != nil && col.aggregate == "" {
+			// a converted date keeps the name of its column
 			q += " AS "
-			q += col.as
+			q += QuoteIdent(col.name)
 		}
 		if i < len(s.cols)-1 {
 			q += ", "
@@ -179,6 +212,37 @@ func (s *Selector) query(config ...*DefaultConfigQuery) (string, []interface{})
 	return q, nil
 }
 
+// expression renders the column for select and group by: date conversion,
+// IFNULL and aggregate wrap the column in this order
+func (c *C) expression(table string) string {
+	column := quoteColumn(table, c.name)
+	if c.name == countAllName && c.table == "" && c.aggregate != "" {
+		// Count(nil), a column named * is still quoted
+		column = "*"
+	}
+	if c.dateTimeConverter != nil {
+		if c.dateTimeConverter.TimeZoneConvertFormat != "" {
+			column = fmt.Sprintf("CONVERT_TZ(%v, '+00:00', %v)", column, quoteString(c.dateTimeConverter.TimeZoneConvertFormat))
+		}
+		if c.dateTimeConverter.DateFormat != "" {
+			column = fmt.Sprintf("DATE_FORMAT(%v, %v)", column, quoteString(c.dateTimeConverter.DateFormat))
+		}
+	}
+	if c.ifNullType == IFNULLINT {
+		column = fmt.Sprintf("IFNULL(%v, 0)", column)
+	}
+	if c.ifNullType == IFNULLSTR {
+		column = fmt.Sprintf("IFNULL(%v, '')", column)
+	}
+	if c.aggregate != "" {
+		if c.distinct {
+			column = "DISTINCT " + column
+		}
+		column = fmt.Sprintf("%v(%v)", c.aggregate, column)
+	}
+	return column
+}
+
 type QueryBuilder struct {
 	query      string
 	table      string
@@ -188,19 +252,52 @@ type QueryBuilder struct {
 	groupBy    Querier
 	orderBy    Querier
 	args       []interface{}
+	forUpdate  bool
+	// deletedAt is the DeletedAt column of a soft deleted table, its deleted rows are left
+	// out unless withDeleted
+	deletedAt   string
+	withDeleted bool
+	// joins of the preloads of GetById, rendered after the FROM table by Query
+	joins []joinClause
+}
+
+// joinClause is one preload join, table is joined on on. deletedAt is the DeletedAt column
+// of a soft deleted joined table
+type joinClause struct {
+	typeJoin  TYPEJOIN
+	table     string
+	on        string
+	deletedAt string
 }
 
+// ForUpdate locks the selected rows until the transaction ends,
+// only meaningful for queries run with RawQueryTx
+func (q *QueryBuilder) ForUpdate() *QueryBuilder {
+	q.forUpdate = true
+	return q
+}
+
+// OrderBy adds a sort key after the keys added before, a column with an empty table
+// sorts by an alias of the select
 func (q *QueryBuilder) OrderBy(c *C, orderType OrderType) *QueryBuilder {
-	o := &orderBy{
+	keys := []*orderKey{}
+	if o, ok := q.orderBy.(*orderBy); ok {
+		keys = append(keys, o.keys...)
+	}
+	q.orderBy = &orderBy{keys: append(keys, &orderKey{
 		name:      c.name,
 		table:     c.table,
+		as:        c.as,
 		orderType: orderType,
-	}
-	q.orderBy = o
+	})}
 	return q
 }
 
 func (q *QueryBuilder) Query() (string, []interface{}) {
+	if len(q.joins) > 0 {
+		q.query += q.joinQuery()
+		q.joins = nil
+	}
 	query := ""
 	if q.query != "" {
 		query = q.query
@@ -215,19 +312,36 @@ func (q *QueryBuilder) Query() (string, []interface{}) {
 		query += q.query
 		q.query = query
 	}
-	if q.Predicate != nil {
+	if predicate := q.wherePredicate(); predicate != nil {
 		q.query += " "
-		predicateQuery, args := q.Predicate.query()
+		predicateQuery, args := predicate.query()
 		q.query += predicateQuery
 		if args != nil {
 			q.args = append(q.args, args...)
 		}
 	}
+	if q.groupBy != nil {
+		groupByQuery, args := q.groupBy.query()
+		if groupByQuery != "" {
+			q.query += " " + groupByQuery
+			q.args = append(q.args, args...)
+		}
+	}
 	if q.orderBy != nil {
 		q.query += " "
 		orderByQuery, _ := q.orderBy.query()
 		q.query += orderByQuery
 	}
+	if q.limit != nil {
+		limitQuery, args := q.limit.query()
+		if limitQuery != "" {
+			q.query += " " + limitQuery
+			q.args = append(q.args, args...)
+		}
+	}
+	if q.forUpdate {
+		q.query += " FOR UPDATE"
+	}
 	return q.query, q.args
 }
 
@@ -261,294 +375,6 @@ func (q *QueryBuilder) SelectDate(col *C, dateTimeConverter ...*DateTimeConverte
 	return q
 }
 
-type PredicateOp uint
-
-func (p PredicateOp) toString() string {
-	if p == LessEqual {
-		return "<="
-	}
-	if p == Less {
-		return "<"
-	}
-	if p == GreaterEqual {
-		return ">="
-	}
-
-	if p == Greater {
-		return ">"
-	}
-
-	if p == Like {
-		return "LIKE"
-	}
-
-	if p == NotEqual {
-		return "!="
-	}
-	if p == ISNULL {
-		return "IS NULL"
-	}
-	if p == ISNOTNULL {
-		return "IS NOT NULL"
-	}
-	return "="
-}
-
-const (
-	LessEqual PredicateOp = iota
-	Less
-	GreaterEqual
-	Greater
-	Equal
-	Like
-	NotEqual
-	In
-	ISNULL
-	ISNOTNULL
-)
-
-type PrefixOp uint8
-
-func (o *PrefixOp) ToOpString() string {
-	if (*o) == PrefixOr {
-		return "OR"
-	}
-	return "AND"
-}
-
-const (
-	PrefixAnd PrefixOp = iota
-	PrefixOr
-)
-
-type Predicate struct {
-	prefixOp PrefixOp
-	col      string
-	table    string
-	op       string
-	val      interface{}
-	depth    int
-	down     *Predicate
-}
-
-func OrP(col string, table string, op PredicateOp, val ...interface{}) *Predicate {
-	p := &Predicate{
-		prefixOp: PrefixOr,
-		col:      col,
-		table:    table,
-		op:       op.toString(),
-		depth:    0,
-	}
-	if len(val) == 1 {
-		p.val = val[0]
-	}
-	return p
-}
-
-func P(col string, table string, op PredicateOp, val ...interface{}) *Predicate {
-	p := &Predicate{
-		prefixOp: PrefixAnd,
-		col:      col,
-		table:    table,
-		op:       op.toString(),
-		depth:    0,
-	}
-	if len(val) == 1 {
-		p.val = val[0]
-	}
-	return p
-}
-
-func Or(predicates ...*Predicate) *Predicate {
-	if len(predicates) == 1 {
-		return predicates[0]
-	}
-	f := predicates[0]
-	n := len(predicates) - 1
-	if f.down == nil {
-		f = predicates[len(predicates)-1]
-		n--
-	}
-	d := f
-	is_block := 0
-	for d != nil {
-		if d.down != nil {
-			is_block = 1
-		}
-		if is_block == 1 {
-			d.depth++
-		}
-		d = d.down
-	}
-	m := n
-	v := predicates[n]
-	for m > 0 {
-		m--
-		if predicates[m] != f {
-			v.down = predicates[m]
-			v = v.down
-		}
-	}
-	v.down = f
-	//k := predicates[n]
-	////replace fmt.Println
-	predicates[n].prefixOp = PrefixOr
-	return predicates[n]
-}
-
-func And(predicates ...*Predicate) *Predicate {
-	if len(predicates) == 1 {
-		return predicates[0]
-	}
-	f := predicates[0]
-	n := len(predicates) - 1
-	if f.down == nil {
-		f = predicates[len(predicates)-1]
-		n--
-	}
-	d := f
-	is_block := 0
-	for d != nil {
-		if d.down != nil {
-			is_block = 1
-		}
-		if is_block == 1 {
-			d.depth++
-		}
-		d = d.down
-	}
-	m := n
-	v := predicates[n]
-	for m > 0 {
-		m--
-		if predicates[m] != f {
-			v.down = predicates[m]
-			v = v.down
-		}
-	}
-	v.down = f
-	//k := predicates[n]
-	////replace fmt.Println
-	predicates[n].prefixOp = PrefixAnd
-	return predicates[n]
-}
-
-type Where struct {
-	curBlock   int
-	predicates []*Predicate
-}
-
-func (w *Where) Append(querier Querier) Querier {
-	if _, check := querier.(*Where); check {
-		w.predicates = append(w.predicates, querier.(*Where).predicates...)
-	}
-	return w
-}
-
-func (w *Where) query(config ...*DefaultConfigQuery) (string, []interface{}) {
-	query := "WHERE "
-	arguments := []interface{}{}
-	p := w.predicates[0]
-	curDepth := 0
-	//first := true
-	num_ends := 0
-	for p != nil {
-		// if depth > 0 and depth not equal curDepth
-		// mean we have one depth nested within
-		// then open "(" will created
-		// also mark be added to 1, to can add end ")" later
-		if p.depth > 0 && p.depth != curDepth {
-			query += "("
-			curDepth = p.depth
-			num_ends++
-			//first = false
-		}
-		query += fmt.Sprintf("`%v`.`%v` %v ", p.table, p.col, p.op)
-		if p.op != "IS NULL" && p.op != "IS NOT NULL" {
-			query += "?"
-			arguments = append(arguments, p.val)
-		}
-
-		if p.down != nil {
-			query += " " + p.prefixOp.ToOpString() + " "
-		}
-		p = p.down
-	}
-	for num_ends > 0 {
-		query += ")"
-		num_ends--
-	}
-	//for i, p := range w.predicates {
-	//	if i > 0 {
-	//		if p.prefixOp == PrefixAnd {
-	//			query += " AND "
-	//		} else {
-	//			query += " OR "
-	//		}
-	//	}
-	//	if len(config) > 0 {
-	//		p.table = config[0].RenameTableAs
-	//	}
-	//	query += fmt.Sprintf("`%v`.`%v` %v ", p.table, p.col, p.op)
-	//	if p.op != "IS NULL" {
-	//		query += "?"
-	//		arguments = append(arguments, p.val)
-	//	}
-	//
-	//}
-	return query, arguments
-}
-
-func (q *QueryBuilder) Where(predicate *Predicate) *QueryBuilder {
-	if q.Predicate == nil {
-		q.Predicate = &Where{
-			predicates: make([]*Predicate, 0),
-		}
-		q.Predicate.(*Where).predicates = append(q.Predicate.(*Where).predicates, predicate)
-		return q
-	}
-	p := q.Predicate.(*Where).predicates[0]
-	for p != nil {
-		if p.down == nil {
-			break
-		}
-		p = p.down
-	}
-	p.down = predicate
-	return q
-}
-
-func (q *QueryBuilder) Wheres(predicate *Predicate) *QueryBuilder {
-	if q.Predicate == nil {
-		q.Predicate = &Where{
-			predicates: make([]*Predicate, 0),
-		}
-		q.Predicate.(*Where).predicates = append(q.Predicate.(*Where).predicates, predicate)
-		return q
-	}
-	p := q.Predicate.(*Where).predicates[0]
-	for p != nil {
-		if p.down == nil {
-			break
-		}
-		p = p.down
-	}
-	d := predicate
-	is_block := 0
-	for d != nil {
-		if d.down != nil {
-			is_block = 1
-		}
-		if is_block == 1 {
-			d.depth++
-		}
-		d = d.down
-	}
-	p.down = predicate
-
-	return q
-}
-
 type TYPEJOIN uint8
 
 const (
@@ -568,54 +394,56 @@ func (t TYPEJOIN) ToQueryString() string {
 }
 func (r *Repo) GetById(need interface{}, preloads ...func() (to interface{}, fk string, pk string, inverse bool, type_join TYPEJOIN)) *QueryBuilder {
 	nv := reflect.Indirect(reflect.ValueOf(need))
-	nvName := nv.Type().Name()
-	nvTable := strings.ToLower(nvName) + "s"
+	nvTable := mappingOf(nv.Type()).table
+	deletedAt := deletedAtColumn(nv.Type())
 	if len(preloads) == 0 {
 		return &QueryBuilder{
-			query: fmt.Sprintf("FROM `%v`", nvTable),
-			table: nvTable,
-			args:  []interface{}{},
+			query:     "FROM " + QuoteIdent(nvTable),
+			table:     nvTable,
+			args:      []interface{}{},
+			deletedAt: deletedAt,
 		}
 	}
 	if len(preloads) == 1 {
 		to, fk, pk, inverse, typ := preloads[0]()
 		pv := reflect.Indirect(reflect.ValueOf(to))
 		//replace fmt.Println
-		pvName := pv.Type().Name()
 
 		nvKey := pk
-		pvTable := strings.ToLower(pvName) + "s"
+		pvTable := mappingOf(pv.Type()).table
 		pvKey := fk
 		if inverse {
 			nvKey, pvKey = pvKey, nvKey
 		}
-		typ_join := typ.ToQueryString()
-		query := fmt.Sprintf("FROM `%v` %v `%v` ON `%v`.`%v` = `%v`.`%v`", nvTable, typ_join, pvTable, nvTable, nvKey, pvTable, pvKey)
-		return &QueryBuilder{table: nvTable, query: query, args: []interface{}{}}
+		joins := []joinClause{{
+			typeJoin:  typ,
+			table:     pvTable,
+			on:        fmt.Sprintf("%v = %v", quoteColumn(nvTable, nvKey), quoteColumn(pvTable, pvKey)),
+			deletedAt: deletedAtColumn(pv.Type()),
+		}}
+		return &QueryBuilder{table: nvTable, query: "FROM " + QuoteIdent(nvTable), args: []interface{}{}, deletedAt: deletedAt, joins: joins}
 	}
 	// multiple joiners
-	query := ""
-	for i, preload := range preloads {
+	joins := make([]joinClause, 0, len(preloads))
+	for _, preload := range preloads {
 		to, fk, pk, inverse, typ := preload()
 		pv := reflect.Indirect(reflect.ValueOf(to))
 		//replace fmt.Println
-		pvName := pv.Type().Name()
 
 		nvKey := pk
-		pvTable := strings.ToLower(pvName) + "s"
+		pvTable := mappingOf(pv.Type()).table
 		pvKey := fk
 		if inverse {
 			nvKey, pvKey = pvKey, nvKey
 		}
-		if i == 0 {
-			query += fmt.Sprintf("FROM `%v`", nvTable)
-		} else {
-			query += " "
-		}
-		typ_join := typ.ToQueryString()
-		query += fmt.Sprintf("%v `%v` ON `%v`.`%v` = `%v`.`%v`", typ_join, pvTable, nvTable, nvKey, pvTable, pvKey)
-	}
-	return &QueryBuilder{table: nvTable, query: query, args: []interface{}{}}
+		joins = append(joins, joinClause{
+			typeJoin:  typ,
+			table:     pvTable,
+			on:        fmt.Sprintf("%v = %v", quoteColumn(nvTable, nvKey), quoteColumn(pvTable, pvKey)),
+			deletedAt: deletedAtColumn(pv.Type()),
+		})
+	}
+	return &QueryBuilder{table: nvTable, query: "FROM " + QuoteIdent(nvTable), args: []interface{}{}, deletedAt: deletedAt, joins: joins}
 }
 
 type Condition struct {
@@ -633,29 +461,31 @@ type RelRelation struct {
 	isO2O     bool
 }
 
-func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Condition) ([]interface{}, []interface{}) {
+func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Condition) ([]interface{}, error) {
 	cols, err := rows.Columns()
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 	var scaned = map[interface{}]reflect.Value{}
 	var orderId = []interface{}{}
 	//replace fmt.Println
 	castReflect := reflect.Indirect(reflect.ValueOf(cast))
 	results := []interface{}{}
-	count := 0
+	rowsWithoutId := []reflect.Value{}
 	for rows.Next() {
-		count += 1
 		jsonByteAddrs := map[string][]interface{}{}
 		castedNew := reflect.Indirect(reflect.New(castReflect.Type()))
-		print(fmt.Sprintf("casted new: %v\n", castedNew.Type()), r.debug)
 		addrs := make([]interface{}, len(cols))
 		rels := map[string]*RelRelation{}
 		for i, col := range cols {
+			col = fieldOfColumn(castedNew.Type(), col)
 			_, ok := castedNew.Type().FieldByName(col)
 			isJson := false
 			if !ok {
 				str := strings.Split(col, "$")
+				if relField, relOk := castedNew.Type().FieldByName(str[0]); relOk && len(str) == 2 {
+					str[1] = fieldOfColumn(relSchemaType(relField.Type), str[1])
+				}
 				scName := strings.Split(str[0], "Rel")
 				if _, jsonOk := changeset.JsonFieldsOfSchemas[scName[0]]; jsonOk {
 					if _, jsonOk := changeset.JsonFieldsOfSchemas[scName[0]][str[1]]; jsonOk {
@@ -738,7 +568,10 @@ func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Conditio
 			//replace fmt.Println
 			addrs[i] = f.Addr().Interface()
 		}
-		rows.Scan(addrs...)
+		if err := rows.Scan(addrs...); err != nil {
+			// no half filled rows, the caller gets the error instead
+			return nil, err
+		}
 		for fieldName, byteAndStructAddrJson := range jsonByteAddrs {
 
 			if byteAddr, ok := byteAndStructAddrJson[0].(*[]byte); ok {
@@ -780,6 +613,10 @@ func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Conditio
 			//replace fmt.Println
 			for _, rel := range rels {
 				if !rel.isO2O {
+					if relId := (*rel.relScaned).Elem().FieldByName("Id"); relId.IsValid() && relId.IsZero() {
+						// left join found no row on the many side
+						continue
+					}
 					newVal := reflect.Append(scaned[idVal].FieldByName(rel.fieldRef), *rel.relScaned)
 					scaned[idVal].FieldByName(rel.fieldRef).Set(newVal)
 				} else {
@@ -794,9 +631,22 @@ func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Conditio
 			//	rv := reflect.Indirect(reflect.ValueOf(addr))
 			//	//replace fmt.Println
 			//}
+		} else {
+			// structs without Id are report rows (group by, aggregates), one result per row in query order
+			for _, rel := range rels {
+				if rel.isO2O {
+					castedNew.FieldByName(rel.fieldRef).Set(*rel.relScaned)
+				}
+			}
+			rowsWithoutId = append(rowsWithoutId, castedNew)
 		}
 	}
-	print(fmt.Sprintf("TOTAL ROW SCANNED FROM MYSQL: %v\n", count), r.debug)
+	if len(rowsWithoutId) > 0 {
+		for _, row := range rowsWithoutId {
+			results = append(results, row.Addr().Interface())
+		}
+		return results, nil
+	}
 	if len(orderId) == 0 {
 		for k, v := range scaned {
 			results = append(results, v.Addr().Interface())
@@ -814,35 +664,54 @@ func (r *Repo) ParseToStruct(rows *sql.Rows, cast interface{}, cond ...*Conditio
 	return results, nil
 }
 
-func (r *Repo) RawQuery(query string, args []interface{}, cast interface{}) ([]interface{}, []interface{}) {
-	stmt, err := r.db.Prepare(query)
+// RawQuery runs a select, nothing is returned when the query or reading its rows fails.
+// A statement stopped by its deadline fails with ErrQueryTimeout
+func (r *Repo) RawQuery(ctx context.Context, query string, args []interface{}, cast interface{}) ([]interface{}, error) {
+	return r.rawQuery(ctx, nil, query, args, cast)
+}
+
+// RawQueryTx runs a select inside tx, use it with QueryBuilder.ForUpdate to lock rows
+func (r *Repo) RawQueryTx(ctx context.Context, tx *sql.Tx, query string, args []interface{}, cast interface{}) ([]interface{}, error) {
+	return r.rawQuery(ctx, tx, query, args, cast)
+}
+
+// rawQuery scans every row into cast, the rows and the statement are always closed
+func (r *Repo) rawQuery(ctx context.Context, tx *sql.Tx, query string, args []interface{}, cast interface{}) (results []interface{}, err error) {
+	ctx, event := r.beforeQuery(ctx, query, args)
+	defer func() {
+		r.afterQuery(ctx, event, int64(len(results)), err)
+	}()
+	rows, done, err := r.queryRows(ctx, tx, query, args)
 	if err != nil {
-		//replace fmt.Println
-		fmt.Println("[Log-RawQuery], prepare statement error: ", err)
-		return nil, nil
+		return nil, err
+	}
+	defer done()
+	if strings.Contains(query, "ORDER BY") {
+		results, err = r.ParseToStruct(rows, cast, &Condition{OrderBy: true})
+	} else {
+		results, err = r.ParseToStruct(rows, cast)
 	}
-	rows, err := stmt.Query(args...)
 	if err != nil {
-		//replace fmt.Println
-		print(fmt.Sprintf("[Log-RawQuery], error: %v\n", err), r.debug)
+		return nil, err
 	}
-
-	//replace fmt.Println
-	if strings.Contains(query, "ORDER BY") {
-		return r.ParseToStruct(rows, cast, &Condition{OrderBy: true})
+	if err = rows.Err(); err != nil {
+		return nil, timeoutError(ctx, err)
 	}
-	return r.ParseToStruct(rows, cast)
+	return results, nil
 }
 
 func (r *Repo) Save(ctx context.Context, cs *changeset.ChangeSet) error {
-	query, args := r.insertQuery(cs)
-	stmt, err := r.db.PrepareContext(ctx, query)
-	//replace fmt.Println
-	if err != nil {
-		//replace fmt.Println
+	if audited(cs.ReflectSchema.Type()) {
+		return r.inTx(ctx, func(tx *sql.Tx) error {
+			return r.SaveTx(ctx, cs, tx)
+		})
+	}
+	stampInsert(ctx, cs)
+	if err := validInsert(cs); err != nil {
 		return err
 	}
-	result, err := stmt.ExecContext(ctx, args...)
+	query, args := r.insertQuery(cs)
+	result, err := r.exec(ctx, nil, query, args)
 	if err != nil {
 		//replace fmt.Println
 		return err
@@ -857,14 +726,12 @@ func (r *Repo) Save(ctx context.Context, cs *changeset.ChangeSet) error {
 }
 
 func (r *Repo) SaveTx(ctx context.Context, cs *changeset.ChangeSet, tx *sql.Tx) error {
-	query, args := r.insertQuery(cs)
-	stmt, err := tx.PrepareContext(ctx, query)
-	//replace fmt.Println
-	if err != nil {
-		//replace fmt.Println
+	stampInsert(ctx, cs)
+	if err := validInsert(cs); err != nil {
 		return err
 	}
-	result, err := stmt.ExecContext(ctx, args...)
+	query, args := r.insertQuery(cs)
+	result, err := r.exec(ctx, tx, query, args)
 	if err != nil {
 		//replace fmt.Println
 		return err
@@ -879,9 +746,20 @@ func (r *Repo) SaveTx(ctx context.Context, cs *changeset.ChangeSet, tx *sql.Tx)
 		cs.ReflectSchema.FieldByName("Id").Set(reflect.ValueOf(uint32(id)))
 	}
 	cs.ActionRepo = changeset.ActionInsert
+	if audited(cs.ReflectSchema.Type()) {
+		return r.saveAudit(ctx, tx, cs.ReflectSchema.Type(), cs.ReflectSchema.FieldByName("Id").Interface(), AuditInsert, insertChanges(cs))
+	}
 	return nil
 }
 
+// validInsert refuses a changeset missing a not null field before it reaches the database
+func validInsert(cs *changeset.ChangeSet) error {
+	if cs.ValidInsert() {
+		return nil
+	}
+	return fmt.Errorf("repo: insert %v: %w", cs.ReflectSchema.Type().Name(), cs.NotNullErrors())
+}
+
 func (r *Repo) OpenTx(ctx context.Context) *sql.Tx {
 	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
 	if err != nil {
@@ -891,16 +769,12 @@ func (r *Repo) OpenTx(ctx context.Context) *sql.Tx {
 }
 
 func (r *Repo) insertQuery(cs *changeset.ChangeSet) (string, []interface{}) {
-	tb := strings.ToLower(cs.ReflectSchema.Type().Name()) + "s"
-	query := fmt.Sprintf("INSERT INTO `%v` (", tb)
+	tb := mappingOf(cs.ReflectSchema.Type()).table
+	query := fmt.Sprintf("INSERT INTO %v (", QuoteIdent(tb))
 	values := " VALUES ("
 	args := []interface{}{}
 	for i, col := range cs.CastedBoxes {
-		if cs.Boxes[col].UpdatedCol != "" {
-			query += fmt.Sprintf("`%v`", cs.Boxes[col].RelTbName+cs.Boxes[col].UpdatedCol)
-		} else {
-			query += fmt.Sprintf("`%v`", col)
-		}
+		query += QuoteIdent(boxColumn(cs.ReflectSchema.Type(), col, cs.Boxes[col]))
 		values += "?"
 		if i < len(cs.CastedBoxes)-1 {
 			query += ", "
@@ -915,14 +789,14 @@ func (r *Repo) insertQuery(cs *changeset.ChangeSet) (string, []interface{}) {
 }
 
 func (r *Repo) UpdateById(ctx context.Context, cs *changeset.ChangeSet) error {
-	query, args := UpdateQuery(cs)
-	stmt, err := r.db.PrepareContext(ctx, query)
-	if err != nil {
-		//replace fmt.Println
-		return err
+	if audited(cs.ReflectSchema.Type()) {
+		return r.inTx(ctx, func(tx *sql.Tx) error {
+			return r.UpdateTxById(ctx, cs, tx)
+		})
 	}
-
-	result, err := stmt.ExecContext(ctx, args...)
+	stampUpdate(cs)
+	query, args := UpdateQuery(cs)
+	result, err := r.exec(ctx, nil, query, args)
 	if err != nil {
 		//replace fmt.Println
 		return err
@@ -940,16 +814,21 @@ func (r *Repo) UpdateById(ctx context.Context, cs *changeset.ChangeSet) error {
 	return nil
 }
 
+// UpdateTxById updates the casted fields of the row of the changeset, an audited schema
+// locks the row first to record the values before
 func (r *Repo) UpdateTxById(ctx context.Context, cs *changeset.ChangeSet, tx *sql.Tx, append_query ...string) error {
+	stampUpdate(cs)
+	auditing := audited(cs.ReflectSchema.Type())
+	var before reflect.Value
+	if auditing {
+		var err error
+		if before, err = r.lockRowOf(ctx, tx, cs); err != nil {
+			return err
+		}
+	}
 	query, args := UpdateQuery(cs, append_query...)
-	fmt.Println("update by transaction query: ", query, args)
 	//replace fmt.Println
-	stmt, err := tx.PrepareContext(ctx, query)
-	if err != nil {
-		//replace fmt.Println
-		return err
-	}
-	result, err := stmt.ExecContext(ctx, args...)
+	result, err := r.exec(ctx, tx, query, args)
 	if err != nil {
 		//replace fmt.Println
 		return err
@@ -962,17 +841,22 @@ func (r *Repo) UpdateTxById(ctx context.Context, cs *changeset.ChangeSet, tx *sq
 		return fmt.Errorf(customPrefixUpdateNotFound)
 	}
 	cs.ActionRepo = changeset.ActionUpdate
+	if auditing {
+		if changes := updateChanges(cs, before); len(changes) > 0 {
+			return r.saveAudit(ctx, tx, cs.ReflectSchema.Type(), cs.ReflectSchema.FieldByName("Id").Interface(), AuditUpdate, changes)
+		}
+	}
 	return nil
 }
 
 func UpdateQuery(cs *changeset.ChangeSet, append_query ...string) (string, []interface{}) {
-	tbName := strings.ToLower(cs.ReflectSchema.Type().Name()) + "s"
-	query := fmt.Sprintf("UPDATE %v SET ", tbName)
+	tbName := mappingOf(cs.ReflectSchema.Type()).table
+	query := fmt.Sprintf("UPDATE %v SET ", QuoteIdent(tbName))
 	args := []interface{}{}
 	for i, col := range cs.CastedBoxes {
 		have_nil := false
 		if cs.Boxes[col].UpdatedCol != "" {
-			query += fmt.Sprintf("`%v` = ", cs.Boxes[col].RelTbName+cs.Boxes[col].UpdatedCol)
+			query += QuoteIdent(boxColumn(cs.ReflectSchema.Type(), col, cs.Boxes[col])) + " = "
 			rvVal := reflect.Indirect(reflect.ValueOf(cs.Boxes[col].GetVal()))
 			if rvVal.IsZero() {
 				query += " null"
@@ -981,7 +865,7 @@ func UpdateQuery(cs *changeset.ChangeSet, append_query ...string) (string, []int
 				query += " ?"
 			}
 		} else {
-			query += fmt.Sprintf("`%v` = ?", col)
+			query += QuoteIdent(boxColumn(cs.ReflectSchema.Type(), col, cs.Boxes[col])) + " = ?"
 		}
 		if !have_nil {
 			args = append(args, cs.Boxes[col].GetVal())
@@ -990,7 +874,7 @@ func UpdateQuery(cs *changeset.ChangeSet, append_query ...string) (string, []int
 			query += ", "
 		}
 	}
-	query += " WHERE `Id` = ?"
+	query += " WHERE " + QuoteIdent(ColumnName(cs.ReflectSchema.Interface(), "Id")) + " = ?"
 	if len(append_query) > 0 {
 		query += append_query[0]
 	}
@@ -999,138 +883,6 @@ func UpdateQuery(cs *changeset.ChangeSet, append_query ...string) (string, []int
 	return query, args
 }
 
-type Rel struct {
-	from    string
-	to      string
-	fromKey string
-	toKey   string
-	builder *QueryBuilder
-}
-
-type CacheKey struct {
-	key string
-	tb  string
-}
-
-type QueryRel struct {
-	rels            []*Rel
-	query           string
-	args            []interface{}
-	index           int
-	joinedKeysCache []*CacheKey
-}
-
-func (q *QueryRel) OpenRel(rel *Rel) *QueryRel {
-	q.rels = append(q.rels, rel)
-	return q
-}
-
-func (q *QueryRel) ParseToQuery() (string, []interface{}) {
-	dfs(q, true)
-	return q.query, q.args
-}
-
-func dfs(q *QueryRel, rebuild bool) {
-	if rebuild {
-		if q.index >= len(q.rels) {
-			q.index = 0
-			dfs(q, false)
-			return
-		}
-		index := q.index
-		source := q.rels[index]
-
-		if index < len(q.rels) && index > 0 {
-			if q.rels[index-1].from == source.from {
-				swapFromAndTo(q.rels[index-1], index, len(q.rels))
-			}
-			if q.rels[index-1].from == source.to {
-				swapFromAndTo(q.rels[index-1], index, len(q.rels))
-			}
-		}
-		q.index++
-		dfs(q, true)
-	} else {
-		if q.index >= len(q.rels) {
-			return
-		}
-		index := q.index
-		source := q.rels[index]
-		var haveExpandQuery bool
-		if index > 0 {
-			q.query += "("
-		}
-		if index < len(q.rels)-1 {
-			var IncludeColAs bool = false
-			if index == 0 {
-				IncludeColAs = true
-			}
-			for i := index; i < len(q.rels)-1; i++ {
-				if q.rels[i+1].builder != nil {
-					//replace fmt.Println
-					projectQuery, _ := q.rels[i+1].builder.Projection.query(&DefaultConfigQuery{
-						IncludeColAs:  IncludeColAs,
-						RenameTableAs: fmt.Sprintf("%v_%v", "r", index+1),
-					})
-					if projectQuery != "" {
-						if !haveExpandQuery {
-							haveExpandQuery = true
-							q.query += "SELECT "
-						} else {
-							q.query += ", "
-						}
-						q.query += projectQuery
-					}
-				}
-			}
-		}
-		// load self builder
-		if q.rels[index].builder != nil {
-			if q.rels[index].builder.Projection != nil {
-				selfProjectQuery, _ := q.rels[index].builder.Projection.query()
-				if selfProjectQuery != "" {
-					if !haveExpandQuery {
-						haveExpandQuery = true
-						q.query += "SELECT "
-					} else {
-						q.query += ", "
-					}
-					q.query += selfProjectQuery
-				}
-			}
-
-		}
-		if index > 0 {
-			if haveExpandQuery {
-				q.query += fmt.Sprintf(", `%v`.`%v`", q.rels[index-1].to, q.rels[index-1].toKey)
-			} else {
-				q.query += fmt.Sprintf("SELECT `%v`.`%v`", q.rels[index-1].to, q.rels[index-1].toKey)
-			}
-			q.query += " "
-		}
-		q.query += fmt.Sprintf("FROM %v INNER JOIN ", source.from)
-		q.index++
-		dfs(q, false)
-		// backtracking
-		if index == len(q.rels)-1 {
-			q.query += fmt.Sprintf("%v ON `%v`.`%v` = `%v`.`%v`", source.to, source.from, source.fromKey, source.to, source.toKey)
-		}
-		if index > 0 {
-			tbNameAs := fmt.Sprintf("%v_%v", "r", index)
-			q.query += fmt.Sprintf(") AS `%v` ON `%v`.`%v` = `%v`.`%v`", tbNameAs, q.rels[index-1].from, q.rels[index-1].fromKey, tbNameAs, q.rels[index-1].toKey)
-		}
-		if q.rels[index].builder != nil {
-			if q.rels[index].builder.Predicate != nil {
-				selfPredicateQuery, args := q.rels[index].builder.Predicate.query()
-				if selfPredicateQuery != "" {
-					q.query += selfPredicateQuery
-					q.args = append(q.args, args...)
-				}
-			}
-		}
-	}
-}
-
 func JoinMultipleBuilder(left, right *QueryBuilder) {
 	lv := reflect.Indirect(reflect.ValueOf(left))
 	rv := reflect.Indirect(reflect.ValueOf(right))
@@ -1168,18 +920,6 @@ func JoinMultipleBuilder(left, right *QueryBuilder) {
 	}
 }
 
-func swapFromAndTo(rel *Rel, indexRel int, n int) {
-	//replace fmt.Println
-	rel.fromKey, rel.toKey = rel.toKey, rel.fromKey
-	if indexRel == n-1 {
-		rel.from, rel.to = rel.to, rel.from
-	} else {
-		rel.from = rel.to
-		rel.to = fmt.Sprintf("r_%v", indexRel+1)
-	}
-	//replace fmt.Println
-}
-
 func JoinProjectBuilder(query *string, projectQuery *string) {
 	*query = *projectQuery + *query
 }
@@ -1223,40 +963,147 @@ func (q *QueryBuilder) ClearWhere() {
 	q.Predicate = nil
 }
 
+// CloneBuilder copies the builder, selects and wheres added to the copy do not change q
 func (q *QueryBuilder) CloneBuilder() *QueryBuilder {
+	var projection Querier
+	if selector, ok := q.Projection.(*Selector); ok {
+		projection = &Selector{cols: append([]*C{}, selector.cols...)}
+	}
+	var predicate Querier
+	if where, ok := q.Predicate.(*Where); ok {
+		predicate = &Where{expr: where.expr}
+	}
+	var grouping Querier
+	if group, ok := q.groupBy.(*groupBy); ok {
+		grouping = &groupBy{cols: append([]*C{}, group.cols...), having: group.having}
+	}
 	return &QueryBuilder{
-		query:      q.query,
-		table:      q.table,
-		Projection: q.Projection,
-		Predicate:  q.Predicate,
-		limit:      q.limit,
-		groupBy:    q.groupBy,
-		orderBy:    q.orderBy,
-		args:       q.args,
+		query:       q.query,
+		table:       q.table,
+		Projection:  projection,
+		Predicate:   predicate,
+		limit:       q.limit,
+		groupBy:     grouping,
+		orderBy:     q.orderBy,
+		args:        append([]interface{}{}, q.args...),
+		forUpdate:   q.forUpdate,
+		deletedAt:   q.deletedAt,
+		withDeleted: q.withDeleted,
+		joins:       append([]joinClause{}, q.joins...),
 	}
 }
 
 func DeleteQuery(cs *changeset.ChangeSet) (string, []interface{}) {
-	tbName := strings.ToLower(cs.ReflectSchema.Type().Name()) + "s"
-	query := fmt.Sprintf("DELETE FROM `%v` ", tbName)
+	tbName := mappingOf(cs.ReflectSchema.Type()).table
+	query := fmt.Sprintf("DELETE FROM %v ", QuoteIdent(tbName))
 	args := []interface{}{}
-	query += "WHERE `Id` = ?"
+	query += "WHERE " + QuoteIdent(ColumnName(cs.ReflectSchema.Interface(), "Id")) + " = ?"
 	args = append(args, cs.ReflectSchema.FieldByName("Id").Interface())
 	return query, args
 }
+
+// DeleteWhere deletes every row of the table of need matching predicate, returns rows deleted.
+// Rows of a schema with a DeletedAt field are soft deleted
+func (r *Repo) DeleteWhere(ctx context.Context, need interface{}, predicate *Predicate) (int64, error) {
+	if audited(reflect.Indirect(reflect.ValueOf(need)).Type()) {
+		var deleted int64
+		err := r.inTx(ctx, func(tx *sql.Tx) (err error) {
+			deleted, err = r.DeleteTxWhere(ctx, need, predicate, tx)
+			return err
+		})
+		return deleted, err
+	}
+	query, args := deleteWhereQuery(need, predicate)
+	result, err := r.exec(ctx, nil, query, args)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repo) DeleteTxWhere(ctx context.Context, need interface{}, predicate *Predicate, tx *sql.Tx) (int64, error) {
+	t := reflect.Indirect(reflect.ValueOf(need)).Type()
+	var before []reflect.Value
+	if audited(t) {
+		var err error
+		if before, err = r.lockRows(ctx, tx, t, predicate, false); err != nil {
+			return 0, err
+		}
+	}
+	query, args := deleteWhereQuery(need, predicate)
+	result, err := r.exec(ctx, tx, query, args)
+	if err != nil {
+		return 0, err
+	}
+	boxes := map[string]*changeset.Box{}
+	if len(before) > 0 {
+		boxes = reflect.New(t).Interface().(changeset.Schema).Validators()
+	}
+	for _, row := range before {
+		if err = r.saveAudit(ctx, tx, t, row.FieldByName("Id").Interface(), AuditDelete, deleteChanges(boxes, row)); err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected()
+}
+
+func deleteWhereQuery(need interface{}, predicate *Predicate) (string, []interface{}) {
+	nv := reflect.Indirect(reflect.ValueOf(need))
+	if deletedAtColumn(nv.Type()) != "" {
+		return softDeleteQuery(nv.Type(), predicate, stampNow())
+	}
+	tbName := mappingOf(nv.Type()).table
+	whereQuery, args := (&Where{expr: predicate}).query()
+	return fmt.Sprintf("DELETE FROM %v ", QuoteIdent(tbName)) + whereQuery, args
+}
+
 func (r *Repo) DeleteUserById(ctx context.Context, changeset *changeset.ChangeSet) error {
-	query, args := DeleteQuery(changeset)
-	stmt, err := r.db.PrepareContext(ctx, query)
+	return r.DeleteById(ctx, changeset)
+}
+
+// DeleteById deletes the row of the changeset, a schema with a DeletedAt field is soft deleted
+func (r *Repo) DeleteById(ctx context.Context, cs *changeset.ChangeSet) error {
+	if audited(cs.ReflectSchema.Type()) {
+		return r.inTx(ctx, func(tx *sql.Tx) error {
+			return r.DeleteTxById(ctx, cs, tx)
+		})
+	}
+	query, args := deleteByIdQuery(cs)
+	result, err := r.exec(ctx, nil, query, args)
 	if err != nil {
 		return err
 	}
-	result, err := stmt.ExecContext(ctx, args...)
+	return deletedOne(result, cs)
+}
+
+func (r *Repo) DeleteTxById(ctx context.Context, cs *changeset.ChangeSet, tx *sql.Tx) error {
+	auditing := audited(cs.ReflectSchema.Type())
+	var before reflect.Value
+	if auditing {
+		var err error
+		if before, err = r.lockRowOf(ctx, tx, cs); err != nil {
+			return err
+		}
+	}
+	query, args := deleteByIdQuery(cs)
+	result, err := r.exec(ctx, tx, query, args)
+	if err != nil {
+		return err
+	}
+	if err = deletedOne(result, cs); err != nil || !auditing || !before.IsValid() {
+		return err
+	}
+	return r.saveAudit(ctx, tx, cs.ReflectSchema.Type(), cs.ReflectSchema.FieldByName("Id").Interface(), AuditDelete, deleteChanges(cs.Boxes, before))
+}
+
+func deletedOne(result sql.Result, cs *changeset.ChangeSet) error {
 	n, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 	//replace fmt.Println
 	if n == 1 {
+		cs.ActionRepo = changeset.ActionDelete
 		return nil
 	}
 	return fmt.Errorf("%v", NotFoundErr)
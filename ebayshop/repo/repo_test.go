@@ -0,0 +1,31 @@
+package repo
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"testing"
+)
+
+func TestInsertRejectsMissingNotNull(t *testing.T) {
+	db, d := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(2)}
+	ctx := context.Background()
+	// OwnerRel is not null and not given
+	inserts := map[string]func(cs *changeset.ChangeSet) error{
+		"Save": func(cs *changeset.ChangeSet) error { return r.Save(ctx, cs) },
+		"Upsert": func(cs *changeset.ChangeSet) error {
+			return r.Upsert(ctx, cs, &UpsertOptions{ConflictFields: []string{"Name"}})
+		},
+	}
+	for name, insert := range inserts {
+		cs := changeset.CastValues(&registeredItem{}, map[string]any{"Name": "a"})
+		if err := insert(cs); err == nil {
+			t.Errorf("%v accepted a missing OwnerRel", name)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.prepared) != 0 {
+		t.Errorf("refused inserts reached the database: %v", d.prepared)
+	}
+}
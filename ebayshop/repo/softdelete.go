@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type actorKey struct{}
+
+// WithActor carries the user making the request, the repo stamps it into CreatedBy fields
+func WithActor(ctx context.Context, actorId uint32) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorId)
+}
+
+// ActorOf is the user carried by WithActor, 0 when nobody is
+func ActorOf(ctx context.Context) uint32 {
+	actorId, _ := ctx.Value(actorKey{}).(uint32)
+	return actorId
+}
+
+// stampNow is the time written into audit fields, whole seconds like the DATETIME columns keep
+var stampNow = func() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// stampInsert fills CreatedAt, UpdatedAt and CreatedBy of a changeset about to be inserted
+func stampInsert(ctx context.Context, cs *changeset.ChangeSet) {
+	audit := mappingOf(cs.ReflectSchema.Type()).audit
+	at := stampNow()
+	if field, ok := audit[changeset.CreatedAtOp]; ok {
+		cs.Stamp(field, at)
+	}
+	if field, ok := audit[changeset.UpdatedAtOp]; ok {
+		cs.Stamp(field, at)
+	}
+	if field, ok := audit[changeset.CreatedByOp]; ok {
+		if actorId := ActorOf(ctx); actorId != 0 {
+			cs.Stamp(field, actorId)
+		}
+	}
+}
+
+// stampUpdate fills UpdatedAt of a changeset about to be updated
+func stampUpdate(cs *changeset.ChangeSet) {
+	if field, ok := mappingOf(cs.ReflectSchema.Type()).audit[changeset.UpdatedAtOp]; ok {
+		cs.Stamp(field, stampNow())
+	}
+}
+
+// deletedAtColumn is the DeletedAt column of a soft deleted schema, "" when rows are really deleted
+func deletedAtColumn(t reflect.Type) string {
+	field, ok := mappingOf(t).audit[changeset.DeletedAtOp]
+	if !ok {
+		return ""
+	}
+	return boxColumn(t, field, nil)
+}
+
+// WithDeleted makes the query also return soft deleted rows of the table of the builder
+// and of the tables joined by its preloads
+func (q *QueryBuilder) WithDeleted() *QueryBuilder {
+	q.withDeleted = true
+	return q
+}
+
+// wherePredicate is the where of the query, rows of a soft deleted table need DeletedAt IS NULL
+// unless WithDeleted was called
+func (q *QueryBuilder) wherePredicate() Querier {
+	if q.deletedAt == "" || q.withDeleted {
+		return q.Predicate
+	}
+	notDeleted := P(q.deletedAt, q.table, ISNULL)
+	if where, ok := q.Predicate.(*Where); ok && where.expr != nil {
+		return &Where{expr: And(where.expr, notDeleted)}
+	}
+	return &Where{expr: notDeleted}
+}
+
+// joinQuery renders the preload joins. A soft deleted joined table is joined on its rows with
+// DeletedAt IS NULL unless WithDeleted was called, so a LEFT JOIN still keeps the row of the
+// builder when every joined row is deleted
+func (q *QueryBuilder) joinQuery() string {
+	var query strings.Builder
+	for _, join := range q.joins {
+		query.WriteString(fmt.Sprintf(" %v %v ON %v", join.typeJoin.ToQueryString(), QuoteIdent(join.table), join.on))
+		if join.deletedAt != "" && !q.withDeleted {
+			query.WriteString(fmt.Sprintf(" AND %v IS NULL", quoteColumn(join.table, join.deletedAt)))
+		}
+	}
+	return query.String()
+}
+
+// softDeleteQuery sets DeletedAt, and UpdatedAt when the schema has one, of the rows matching
+// predicate which are not deleted yet
+func softDeleteQuery(t reflect.Type, predicate *Predicate, at time.Time) (string, []interface{}) {
+	table := mappingOf(t).table
+	deletedAt := deletedAtColumn(t)
+	query := fmt.Sprintf("UPDATE %v SET %v = ?", QuoteIdent(table), QuoteIdent(deletedAt))
+	args := []interface{}{at}
+	if field, ok := mappingOf(t).audit[changeset.UpdatedAtOp]; ok {
+		query += fmt.Sprintf(", %v = ?", QuoteIdent(boxColumn(t, field, nil)))
+		args = append(args, at)
+	}
+	whereQuery, whereArgs := (&Where{expr: And(predicate, P(deletedAt, table, ISNULL))}).query()
+	return query + " " + whereQuery, append(args, whereArgs...)
+}
+
+// deleteByIdQuery deletes the row of the changeset, a soft deleted schema only gets its DeletedAt set
+func deleteByIdQuery(cs *changeset.ChangeSet) (string, []interface{}) {
+	t := cs.ReflectSchema.Type()
+	if deletedAtColumn(t) == "" {
+		return DeleteQuery(cs)
+	}
+	at := stampNow()
+	// the struct follows the row like the id of a saved changeset does
+	if field, ok := mappingOf(t).audit[changeset.DeletedAtOp]; ok {
+		cs.Stamp(field, at)
+	}
+	id := P(ColumnName(cs.ReflectSchema.Interface(), "Id"), mappingOf(t).table, Equal, cs.ReflectSchema.FieldByName("Id").Interface())
+	return softDeleteQuery(t, id, at)
+}
@@ -0,0 +1,190 @@
+package repo
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listing struct {
+	Id        uint32
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+	CreatedBy uint32 `db:"created_by"`
+}
+
+func (l *listing) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":        changeset.NewBox().Ops(changeset.AI),
+		"Name":      changeset.NewBox().Ops(changeset.NotNullable),
+		"CreatedAt": changeset.NewBox().Ops(changeset.NotNullable, changeset.CreatedAtOp),
+		"UpdatedAt": changeset.NewBox().Ops(changeset.NotNullable, changeset.UpdatedAtOp),
+		"DeletedAt": changeset.NewBox().Ops(changeset.Nullable, changeset.DeletedAtOp),
+		"CreatedBy": changeset.NewBox().Ops(changeset.Nullable, changeset.CreatedByOp),
+	}
+}
+
+type listingPhoto struct {
+	Id        uint32
+	ListingId uint32
+	DeletedAt *time.Time
+}
+
+func (p *listingPhoto) Validators() map[string]*changeset.Box {
+	return map[string]*changeset.Box{
+		"Id":        changeset.NewBox().Ops(changeset.AI),
+		"ListingId": changeset.NewBox().Ops(changeset.NotNullable),
+		"DeletedAt": changeset.NewBox().Ops(changeset.Nullable, changeset.DeletedAtOp),
+	}
+}
+
+func preloadListingPhotos() (to interface{}, fk string, pk string, inverse bool, type_join TYPEJOIN) {
+	return &listingPhoto{}, "ListingId", "Id", false, LEFTJOIN
+}
+
+// fixedStampNow makes the stamps of a test predictable
+func fixedStampNow(t *testing.T) time.Time {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	before := stampNow
+	stampNow = func() time.Time { return at }
+	t.Cleanup(func() { stampNow = before })
+	return at
+}
+
+func TestGetByIdExcludesDeleted(t *testing.T) {
+	r := &Repo{}
+	tests := []struct {
+		name    string
+		builder func() *QueryBuilder
+		want    string
+	}{
+		{
+			name: "no where",
+			builder: func() *QueryBuilder {
+				return r.GetById(&listing{}).Select(Col("Id", "listings"))
+			},
+			want: " SELECT `listings`.`Id` FROM `listings` WHERE `listings`.`DeletedAt` IS NULL",
+		},
+		{
+			name: "or where is grouped",
+			builder: func() *QueryBuilder {
+				b := r.GetById(&listing{}).Select(Col("Id", "listings"))
+				return b.Where(P("Name", "listings", Equal, "a")).Where(OrP("Id", "listings", Equal, 1))
+			},
+			want: " SELECT `listings`.`Id` FROM `listings` WHERE (`listings`.`Name` = ? OR `listings`.`Id` = ?) AND `listings`.`DeletedAt` IS NULL",
+		},
+		{
+			name: "with deleted",
+			builder: func() *QueryBuilder {
+				return r.GetById(&listing{}).Select(Col("Id", "listings")).WithDeleted()
+			},
+			want: " SELECT `listings`.`Id` FROM `listings`",
+		},
+		{
+			name: "clone keeps with deleted",
+			builder: func() *QueryBuilder {
+				return r.GetById(&listing{}).Select(Col("Id", "listings")).WithDeleted().CloneBuilder()
+			},
+			want: " SELECT `listings`.`Id` FROM `listings`",
+		},
+		{
+			name: "schema without DeletedAt",
+			builder: func() *QueryBuilder {
+				return r.GetById(&registeredItem{}).Select(Col("Id", "registereditems"))
+			},
+			want: " SELECT `registereditems`.`Id` FROM `registereditems`",
+		},
+		{
+			name: "joined deleted rows",
+			builder: func() *QueryBuilder {
+				return r.GetById(&listing{}, preloadListingPhotos).Select(Col("Id", "listings")).Where(P("Id", "listings", Equal, 1))
+			},
+			want: " SELECT `listings`.`Id` FROM `listings` LEFT JOIN `listingphotos` ON `listings`.`Id` = `listingphotos`.`ListingId` AND `listingphotos`.`DeletedAt` IS NULL WHERE `listings`.`Id` = ? AND `listings`.`DeletedAt` IS NULL",
+		},
+		{
+			name: "joined with deleted",
+			builder: func() *QueryBuilder {
+				return r.GetById(&listing{}, preloadListingPhotos).Select(Col("Id", "listings")).WithDeleted().CloneBuilder()
+			},
+			want: " SELECT `listings`.`Id` FROM `listings` LEFT JOIN `listingphotos` ON `listings`.`Id` = `listingphotos`.`ListingId`",
+		},
+	}
+	for _, tt := range tests {
+		if got, _ := tt.builder().Query(); got != tt.want {
+			t.Errorf("%v:\ngot  %v\nwant %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSoftDeleteQueries(t *testing.T) {
+	at := fixedStampNow(t)
+	cs := changeset.CastValues(&listing{Id: 7}, map[string]any{})
+	query, args := deleteByIdQuery(cs)
+	want := "UPDATE `listings` SET `DeletedAt` = ?, `UpdatedAt` = ? WHERE `listings`.`Id` = ? AND `listings`.`DeletedAt` IS NULL"
+	if query != want || !reflect.DeepEqual(args, []interface{}{at, at, uint32(7)}) {
+		t.Errorf("delete by id %v %v", query, args)
+	}
+	if deleted := cs.ReflectSchema.Addr().Interface().(*listing).DeletedAt; deleted == nil || !deleted.Equal(at) {
+		t.Errorf("DeletedAt of the struct %v", deleted)
+	}
+
+	query, _ = deleteWhereQuery(&listing{}, P("Name", "listings", Equal, "a"))
+	if !strings.HasPrefix(query, "UPDATE `listings` SET `DeletedAt` = ?") {
+		t.Errorf("delete where %v", query)
+	}
+	query, _ = deleteWhereQuery(&registeredItem{}, P("Name", "registereditems", Equal, "a"))
+	if !strings.HasPrefix(query, "DELETE FROM `registereditems`") {
+		t.Errorf("hard delete where %v", query)
+	}
+}
+
+func TestStampInsert(t *testing.T) {
+	at := fixedStampNow(t)
+	explicit := at.Add(-time.Hour)
+	cs := changeset.CastValues(&listing{}, map[string]any{"Name": "a", "CreatedAt": explicit})
+	if !cs.ValidInsert() {
+		t.Fatalf("audit fields are required: %v", cs.NotNullErrors())
+	}
+	stampInsert(WithActor(context.Background(), 9), cs)
+	row := cs.ReflectSchema.Addr().Interface().(*listing)
+	if !row.CreatedAt.Equal(explicit) || !row.UpdatedAt.Equal(at) || row.CreatedBy != 9 || row.DeletedAt != nil {
+		t.Errorf("stamped %+v", row)
+	}
+	query, args := (&Repo{}).insertQuery(cs)
+	if !strings.Contains(query, "`created_by`") || len(args) != 4 {
+		t.Errorf("insert %v %v", query, args)
+	}
+
+	anonymous := changeset.CastValues(&listing{}, map[string]any{"Name": "b"})
+	stampInsert(context.Background(), anonymous)
+	for _, col := range anonymous.CastedBoxes {
+		if col == "CreatedBy" {
+			t.Errorf("CreatedBy stamped without an actor")
+		}
+	}
+
+	update := changeset.CastValues(&listing{Id: 1}, map[string]any{"Name": "c"})
+	stampUpdate(update)
+	if query, _ := UpdateQuery(update); !strings.Contains(query, "`UpdatedAt` = ?") || strings.Contains(query, "CreatedAt") {
+		t.Errorf("update %v", query)
+	}
+}
+
+func TestUpsertKeepsCreated(t *testing.T) {
+	fixedStampNow(t)
+	cs := changeset.CastValues(&listing{}, map[string]any{"Name": "a"})
+	stampInsert(WithActor(context.Background(), 3), cs)
+	query, _, err := upsertQuery(cs, &UpsertOptions{ConflictFields: []string{"Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := query[strings.Index(query, "ON DUPLICATE KEY UPDATE"):]
+	if strings.Contains(update, "CreatedAt") || strings.Contains(update, "created_by") || !strings.Contains(update, "`UpdatedAt` = VALUES(`UpdatedAt`)") {
+		t.Errorf("upsert updates %v", update)
+	}
+}
@@ -0,0 +1,249 @@
+package repo
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PoolConfig sizes the connection pool and the prepared statement cache of a Repo
+// and sets its default statement timeouts
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StmtCacheSize is how many prepared statements are kept, 0 prepares and closes every statement
+	StmtCacheSize int
+	QueryTimeout  time.Duration
+	ExecTimeout   time.Duration
+	// SlowQueryThreshold logs statements running this long with a SlowQueryHook, 0 disables it
+	SlowQueryThreshold time.Duration
+	// RedactColumns are hidden from the hooks of the repo, like passwords or session keys
+	RedactColumns []string
+}
+
+var DefaultPoolConfig = &PoolConfig{
+	MaxOpenConns:    50,
+	MaxIdleConns:    10,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+	StmtCacheSize:   256,
+	QueryTimeout:    5 * time.Second,
+	ExecTimeout:     5 * time.Second,
+}
+
+// stmtCache keeps the most recently used prepared statements by sql text. A statement
+// evicted while in use is closed when its last user releases it
+type stmtCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is the most recently used
+	entries  map[string]*list.Element
+	warming  map[string]bool
+}
+
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	users   int
+	evicted bool
+}
+
+func newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		warming:  map[string]bool{},
+	}
+}
+
+// cached gives the statement of query when it is in the cache, release it when done
+func (c *stmtCache) cached(query string) *cachedStmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[query]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(element)
+	entry := element.Value.(*cachedStmt)
+	entry.users++
+	return entry
+}
+
+// acquire gives the statement of query prepared on db, release it when done
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*cachedStmt, error) {
+	if entry := c.cached(query); entry != nil {
+		return entry, nil
+	}
+
+	// prepare outside the lock, a slow prepare does not block the cached statements
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cachedStmt{query: query, stmt: stmt, users: 1}
+	if c.capacity <= 0 {
+		entry.evicted = true
+		return entry, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[query]; ok {
+		// prepared twice at the same time, keep the cached one
+		stmt.Close()
+		c.order.MoveToFront(element)
+		cached := element.Value.(*cachedStmt)
+		cached.users++
+		return cached, nil
+	}
+	c.entries[query] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.evict(c.order.Back())
+	}
+	return entry, nil
+}
+
+// warm prepares query for the cache in the background. A transaction missing the cache
+// prepares on its own connection instead of waiting for a second one: with every
+// connection of the pool in a transaction that wait would never end
+func (c *stmtCache) warm(db *sql.DB, query string, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 || c.warming[query] {
+		return
+	}
+	c.warming[query] = true
+	go func() {
+		ctx, cancel := statementContext(context.Background(), timeout)
+		defer cancel()
+		if entry, err := c.acquire(ctx, db, query); err == nil {
+			c.release(entry)
+		}
+		c.mu.Lock()
+		delete(c.warming, query)
+		c.mu.Unlock()
+	}()
+}
+
+func (c *stmtCache) release(entry *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.users--
+	if entry.evicted && entry.users == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// evict drops the element from the cache, the caller holds the lock
+func (c *stmtCache) evict(element *list.Element) {
+	entry := c.order.Remove(element).(*cachedStmt)
+	delete(c.entries, entry.query)
+	entry.evicted = true
+	if entry.users == 0 {
+		entry.stmt.Close()
+	}
+}
+
+func (c *stmtCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.order.Len() > 0 {
+		c.evict(c.order.Back())
+	}
+}
+
+func (c *stmtCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// exec runs a write with the cached statement of query, inside tx when tx is not nil
+func (r *Repo) exec(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (sql.Result, error) {
+	ctx, event := r.beforeQuery(ctx, query, args)
+	result, err := r.execStatement(ctx, tx, query, args)
+	rowsAffected := int64(-1)
+	if err == nil {
+		rowsAffected, _ = result.RowsAffected()
+	}
+	r.afterQuery(ctx, event, rowsAffected, err)
+	return result, err
+}
+
+func (r *Repo) execStatement(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (sql.Result, error) {
+	ctx, cancel := statementContext(ctx, r.timeouts.Exec)
+	defer cancel()
+	stmt, release, err := r.statement(ctx, tx, query)
+	if err != nil {
+		return nil, timeoutError(ctx, err)
+	}
+	defer release()
+	result, err := stmt.ExecContext(ctx, args...)
+	return result, timeoutError(ctx, err)
+}
+
+// statement gives the statement of query, bound to tx when tx is not nil. release closes
+// the statement bound to tx and gives the cached one back, the cached one stays prepared.
+//
+// A cached statement is prepared per connection, database/sql prepares it again the first
+// time a transaction runs it on another connection and keeps it on the cached statement
+// from then on. A statement not cached yet is prepared on the connection of tx for this
+// use only and warmed into the cache for the next one
+func (r *Repo) statement(ctx context.Context, tx *sql.Tx, query string) (stmt *sql.Stmt, release func(), err error) {
+	if tx == nil {
+		entry, err := r.stmts.acquire(ctx, r.db, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return entry.stmt, func() { r.stmts.release(entry) }, nil
+	}
+	if entry := r.stmts.cached(query); entry != nil {
+		stmt = tx.StmtContext(ctx, entry.stmt)
+		return stmt, func() {
+			stmt.Close()
+			r.stmts.release(entry)
+		}, nil
+	}
+	r.stmts.warm(r.db, query, r.timeouts.Query)
+	stmt, err = tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stmt, func() { stmt.Close() }, nil
+}
+
+// queryRows runs a select with the cached statement of query, inside tx when tx is not nil.
+// done closes the rows and releases the statement, call it once the rows are read
+func (r *Repo) queryRows(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (rows *sql.Rows, done func(), err error) {
+	ctx, cancel := statementContext(ctx, r.timeouts.Query)
+	stmt, release, err := r.statement(ctx, tx, query)
+	if err != nil {
+		cancel()
+		return nil, nil, timeoutError(ctx, err)
+	}
+	done = func() {
+		if rows != nil {
+			rows.Close()
+		}
+		release()
+		cancel()
+	}
+	rows, err = stmt.QueryContext(ctx, args...)
+	if err != nil {
+		done()
+		return nil, nil, timeoutError(ctx, err)
+	}
+	return rows, done, nil
+}
+
+// Close closes the cached statements and the connection pool
+func (r *Repo) Close() error {
+	r.stmts.close()
+	return r.db.Close()
+}
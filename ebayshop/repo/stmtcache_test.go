@@ -0,0 +1,277 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// countingDriver counts the statements prepared and closed on its connections,
+// queries answer the rows of results when a test sets it
+type countingDriver struct {
+	mu       sync.Mutex
+	prepared map[string]int
+	closed   map[string]int
+	results  func(query string, args []driver.Value) *cannedRows
+}
+
+// cannedRows are the rows a countingDriver answers, values hold one slice per row
+type cannedRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *cannedRows) Columns() []string { return r.columns }
+func (r *cannedRows) Close() error      { return nil }
+func (r *cannedRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+type countingConn struct{ d *countingDriver }
+
+type countingStmt struct {
+	d     *countingDriver
+	query string
+}
+
+func (d *countingDriver) Open(string) (driver.Conn, error) { return &countingConn{d: d}, nil }
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.prepared[query]++
+	return &countingStmt{d: c.d, query: query}, nil
+}
+func (c *countingConn) Close() error              { return nil }
+func (c *countingConn) Begin() (driver.Tx, error) { return countingTx{}, nil }
+
+type countingTx struct{}
+
+func (countingTx) Commit() error   { return nil }
+func (countingTx) Rollback() error { return nil }
+
+func (s *countingStmt) Close() error {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.closed[s.query]++
+	return nil
+}
+func (s *countingStmt) NumInput() int { return -1 }
+func (s *countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.d.results == nil {
+		return nil, errors.New("no rows")
+	}
+	return s.d.results(s.query, args), nil
+}
+func (d *countingDriver) counts(query string) (prepared, closed int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.prepared[query], d.closed[query]
+}
+
+var registerCountingDriver sync.Once
+
+func countingDB(t *testing.T) (*sql.DB, *countingDriver) {
+	d := &countingDriver{prepared: map[string]int{}, closed: map[string]int{}}
+	registerCountingDriver.Do(func() {
+		sql.Register("stmtcache-counting", &switchDriver{})
+	})
+	name := t.Name()
+	drivers.Store(name, d)
+	db, err := sql.Open("stmtcache-counting", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+// switchDriver hands every test its own countingDriver by dsn, sql.Register takes a name once
+type switchDriver struct{}
+
+var drivers sync.Map
+
+func (switchDriver) Open(dsn string) (driver.Conn, error) {
+	d, _ := drivers.Load(dsn)
+	return d.(*countingDriver).Open(dsn)
+}
+
+func TestStmtCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	db, d := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(2)}
+	ctx := context.Background()
+	for _, query := range []string{"a", "b", "a", "c", "a"} {
+		if _, err := r.exec(ctx, nil, query, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		query            string
+		prepared, closed int
+	}{
+		{"a", 1, 0},
+		{"b", 1, 1},
+		{"c", 1, 0},
+	}
+	for _, tt := range tests {
+		prepared, closed := d.counts(tt.query)
+		if prepared != tt.prepared || closed != tt.closed {
+			t.Errorf("%v prepared %v closed %v, want %v %v", tt.query, prepared, closed, tt.prepared, tt.closed)
+		}
+	}
+	if r.stmts.len() != 2 {
+		t.Errorf("cached %v", r.stmts.len())
+	}
+	r.stmts.close()
+	if _, closed := d.counts("a"); closed != 1 {
+		t.Errorf("close left a open")
+	}
+}
+
+func TestStmtCacheClosesEvictedAfterRelease(t *testing.T) {
+	db, d := countingDB(t)
+	cache := newStmtCache(1)
+	ctx := context.Background()
+	inUse, err := cache.acquire(ctx, db, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := cache.acquire(ctx, db, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.release(other)
+	if _, closed := d.counts("a"); closed != 0 {
+		t.Fatalf("evicted statement closed while in use")
+	}
+	cache.release(inUse)
+	if _, closed := d.counts("a"); closed != 1 {
+		t.Errorf("evicted statement not closed after release")
+	}
+}
+
+func TestStmtCacheDisabled(t *testing.T) {
+	db, d := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(0)}
+	for i := 0; i < 3; i++ {
+		if _, err := r.exec(context.Background(), nil, "a", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if prepared, closed := d.counts("a"); prepared != 3 || closed != 3 {
+		t.Errorf("prepared %v closed %v", prepared, closed)
+	}
+}
+
+func TestQueryRowsReleasesOnError(t *testing.T) {
+	db, d := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(0)}
+	rows, done, err := r.queryRows(context.Background(), nil, "a", nil)
+	if err == nil || rows != nil || done != nil {
+		t.Fatalf("got %v %v", rows, err)
+	}
+	if _, closed := d.counts("a"); closed != 1 {
+		t.Errorf("statement of a failed query left open")
+	}
+	if results, _ := r.RawQuery(context.Background(), "a", nil, &registeredItem{}); results != nil {
+		t.Errorf("results of a failed query %v", results)
+	}
+}
+
+func TestStmtCacheInTransaction(t *testing.T) {
+	db, d := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(2)}
+	ctx := context.Background()
+	inTx := func() {
+		t.Helper()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Commit()
+		if _, err = r.exec(ctx, tx, "a", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the transaction holds the only connection, a miss is prepared on it and not waited on
+	inTx()
+	if prepared, closed := d.counts("a"); prepared < 1 || closed < 1 {
+		t.Fatalf("miss prepared %v closed %v", prepared, closed)
+	}
+	deadline := time.Now().Add(time.Second)
+	for r.stmts.len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if r.stmts.len() != 1 {
+		t.Fatalf("miss not warmed into the cache")
+	}
+
+	// one connection, the cached statement is already prepared on it
+	prepared, _ := d.counts("a")
+	for i := 0; i < 3; i++ {
+		inTx()
+	}
+	if again, closed := d.counts("a"); again != prepared || closed != 1 {
+		t.Errorf("hits prepared %v closed %v", again-prepared, closed-1)
+	}
+}
+
+func TestNewRepoRejectsOtherConfig(t *testing.T) {
+	repo = nil
+	t.Cleanup(func() {
+		if repo != nil {
+			repo.Close()
+		}
+		repo = nil
+	})
+	config := &mysql.Config{User: "shop", Net: "tcp", Addr: "127.0.0.1:3306", DBName: "shop"}
+	pool := *DefaultPoolConfig
+	shared := NewRepo(config, false, &pool)
+	copied := pool
+	if again := NewRepo(config, false, &copied); again != shared {
+		t.Fatalf("same config opened another repo")
+	}
+	if again := NewRepo(config, false); again != shared {
+		t.Fatalf("default pool config opened another repo")
+	}
+	debug := NewRepo(config, true, &pool)
+	if debug == shared || debug.db != shared.db || debug.stmts != shared.stmts {
+		t.Fatalf("debug service did not get a scoped copy of the shared repo")
+	}
+	if len(debug.hooks) != len(shared.hooks)+1 {
+		t.Fatalf("debug service has %v hooks, want %v", len(debug.hooks), len(shared.hooks)+1)
+	}
+	copied.StmtCacheSize++
+	for name, open := range map[string]func(){
+		"pool": func() { NewRepo(config, false, &copied) },
+		"dsn": func() {
+			NewRepo(&mysql.Config{User: "other", Net: "tcp", Addr: "127.0.0.1:3306", DBName: "shop"}, false, &pool)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("other %v accepted", name)
+				}
+			}()
+			open()
+		})
+	}
+}
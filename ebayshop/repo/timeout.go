@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueryTimeout is returned when a statement runs past its deadline, it wraps
+// context.DeadlineExceeded so callers outside repo can match either
+var ErrQueryTimeout = fmt.Errorf("query timeout: %w", context.DeadlineExceeded)
+
+// Timeouts bound every statement a repo runs, 0 leaves the statement to the deadline of its context
+type Timeouts struct {
+	Query time.Duration
+	Exec  time.Duration
+}
+
+// WithTimeouts returns a repo sharing the pool and the statements of r with other default timeouts
+func (r *Repo) WithTimeouts(timeouts Timeouts) *Repo {
+	scoped := *r
+	scoped.timeouts = timeouts
+	return &scoped
+}
+
+// statementContext bounds ctx by timeout, a shorter deadline already on ctx wins
+func statementContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// timeoutError marks err as ErrQueryTimeout when the deadline of ctx stopped the statement
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrQueryTimeout) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrQueryTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	db, _ := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(2)}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+
+	_, err := r.exec(expired, nil, "a", nil)
+	if !errors.Is(err, ErrQueryTimeout) || GetErrCode(err) != ErrCodeTimeout {
+		t.Errorf("exec past deadline %v", err)
+	}
+	_, err = r.RawQuery(expired, "a", nil, &registeredItem{})
+	if !errors.Is(err, ErrQueryTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("query past deadline %v", err)
+	}
+	_, err = r.exec(canceled, nil, "a", nil)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrQueryTimeout) {
+		t.Errorf("canceled exec %v", err)
+	}
+}
+
+func TestWithTimeouts(t *testing.T) {
+	db, _ := countingDB(t)
+	r := &Repo{db: db, stmts: newStmtCache(2), timeouts: Timeouts{Query: time.Second, Exec: time.Second}}
+	scoped := r.WithTimeouts(Timeouts{Exec: time.Nanosecond})
+	if scoped.stmts != r.stmts || scoped.db != r.db {
+		t.Errorf("scoped repo does not share the pool")
+	}
+	if r.timeouts.Exec != time.Second {
+		t.Errorf("WithTimeouts changed the original repo")
+	}
+	if _, err := scoped.exec(context.Background(), nil, "a", nil); !errors.Is(err, ErrQueryTimeout) {
+		t.Errorf("exec under a passed repo timeout %v", err)
+	}
+	if _, err := r.exec(context.Background(), nil, "a", nil); err != nil {
+		t.Errorf("exec %v", err)
+	}
+}
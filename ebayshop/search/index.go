@@ -0,0 +1,185 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Field weights, a word of the name tells more about a product than an attribute option
+const (
+	NameWeight      = 3.0
+	AttributeWeight = 1.0
+	// a query word matching only the start of a token counts for this part of a full match
+	prefixMatchFactor = 0.5
+)
+
+// Document is what the index knows of one product
+type Document struct {
+	Id         uint32
+	Name       string
+	Attributes []string // option values of the product and its variants
+}
+
+type Hit struct {
+	Id    uint32  `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// Index is an in-memory inverted index with prefix lookup and tf-idf ranking,
+// safe for concurrent use
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[uint32]float64 // token => doc id => weighted term frequency
+	tokens   []string                      // sorted tokens of postings, for prefix lookup
+	docs     map[uint32]*indexedDocument
+}
+
+type indexedDocument struct {
+	name   string
+	tokens map[string]float64
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[uint32]float64{},
+		docs:     map[uint32]*indexedDocument{},
+	}
+}
+
+func weightedTokens(doc *Document) map[string]float64 {
+	tokens := map[string]float64{}
+	for _, token := range Tokenize(doc.Name) {
+		tokens[token] += NameWeight
+	}
+	for _, attribute := range doc.Attributes {
+		for _, token := range Tokenize(attribute) {
+			tokens[token] += AttributeWeight
+		}
+	}
+	return tokens
+}
+
+// Upsert adds the document or replaces the one indexed with the same id
+func (idx *Index) Upsert(doc *Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.Id)
+	indexed := &indexedDocument{name: doc.Name, tokens: weightedTokens(doc)}
+	for token, weight := range indexed.tokens {
+		posting, ok := idx.postings[token]
+		if !ok {
+			posting = map[uint32]float64{}
+			idx.postings[token] = posting
+			idx.insertToken(token)
+		}
+		posting[doc.Id] = weight
+	}
+	idx.docs[doc.Id] = indexed
+}
+
+func (idx *Index) Remove(id uint32) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+func (idx *Index) remove(id uint32) {
+	indexed, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for token := range indexed.tokens {
+		delete(idx.postings[token], id)
+		if len(idx.postings[token]) == 0 {
+			delete(idx.postings, token)
+			idx.deleteToken(token)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+func (idx *Index) insertToken(token string) {
+	at := sort.SearchStrings(idx.tokens, token)
+	idx.tokens = append(idx.tokens, "")
+	copy(idx.tokens[at+1:], idx.tokens[at:])
+	idx.tokens[at] = token
+}
+
+func (idx *Index) deleteToken(token string) {
+	at := sort.SearchStrings(idx.tokens, token)
+	if at < len(idx.tokens) && idx.tokens[at] == token {
+		idx.tokens = append(idx.tokens[:at], idx.tokens[at+1:]...)
+	}
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search returns the documents matching every word of the query, best first.
+// A word matches the tokens it is a prefix of, a full token match ranks higher
+func (idx *Index) Search(query string, limit int) []*Hit {
+	words := Tokenize(query)
+	if len(words) == 0 || limit <= 0 {
+		return []*Hit{}
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[uint32]float64
+	for _, word := range words {
+		wordScores := idx.scoreWord(word)
+		if scores == nil {
+			scores = wordScores
+			continue
+		}
+		for id := range scores {
+			if wordScore, ok := wordScores[id]; ok {
+				scores[id] += wordScore
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]*Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, &Hit{Id: id, Name: idx.docs[id].name, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Id > hits[j].Id // newer products first on ties
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// scoreWord sums tf-idf of every token starting with word, per document
+func (idx *Index) scoreWord(word string) map[uint32]float64 {
+	scores := map[uint32]float64{}
+	total := float64(len(idx.docs))
+	for at := sort.SearchStrings(idx.tokens, word); at < len(idx.tokens); at++ {
+		token := idx.tokens[at]
+		if len(token) < len(word) || token[:len(word)] != word {
+			break
+		}
+		posting := idx.postings[token]
+		idf := math.Log(1 + total/float64(len(posting)))
+		factor := 1.0
+		if token != word {
+			factor = prefixMatchFactor
+		}
+		for id, weight := range posting {
+			scores[id] += weight * idf * factor
+		}
+	}
+	return scores
+}
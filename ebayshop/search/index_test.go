@@ -0,0 +1,69 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func hitIds(hits []*Hit) []uint32 {
+	ids := []uint32{}
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+func testIndex() *Index {
+	idx := NewIndex()
+	idx.Upsert(&Document{Id: 1, Name: "Red cotton shirt", Attributes: []string{"Large"}})
+	idx.Upsert(&Document{Id: 2, Name: "Blue shirt", Attributes: []string{"Red", "Small"}})
+	idx.Upsert(&Document{Id: 3, Name: "Redmi phone"})
+	return idx
+}
+
+func TestIndexSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []uint32
+	}{
+		{name: "full name match, then name prefix, then attribute", query: "red", limit: 10, want: []uint32{1, 3, 2}},
+		{name: "every word is required", query: "red shirt", limit: 10, want: []uint32{1, 2}},
+		{name: "prefix match", query: "cott", limit: 10, want: []uint32{1}},
+		{name: "attribute match", query: "small", limit: 10, want: []uint32{2}},
+		{name: "limit", query: "shirt", limit: 1, want: []uint32{2}},
+		{name: "no match", query: "laptop", limit: 10, want: []uint32{}},
+		{name: "no word", query: "!!", limit: 10, want: []uint32{}},
+	}
+	idx := testIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitIds(idx.Search(tt.query, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexUpsertAndRemove(t *testing.T) {
+	idx := testIndex()
+	idx.Upsert(&Document{Id: 3, Name: "Galaxy phone"})
+	if got := hitIds(idx.Search("redmi", 10)); len(got) != 0 {
+		t.Errorf("replaced document still found: %v", got)
+	}
+	if got := hitIds(idx.Search("galaxy", 10)); !reflect.DeepEqual(got, []uint32{3}) {
+		t.Errorf("Search(galaxy) = %v, want [3]", got)
+	}
+	idx.Remove(1)
+	idx.Remove(42)
+	if got := hitIds(idx.Search("cotton", 10)); len(got) != 0 {
+		t.Errorf("removed document still found: %v", got)
+	}
+	if idx.Len() != 2 {
+		t.Errorf("Len() = %v, want 2", idx.Len())
+	}
+	if len(idx.tokens) != len(idx.postings) {
+		t.Errorf("tokens %v out of sync with postings", idx.tokens)
+	}
+}
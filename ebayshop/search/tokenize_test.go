@@ -0,0 +1,30 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "lower case words", text: "iPhone 15 Pro", want: []string{"iphone", "15", "pro"}},
+		{name: "punctuation splits", text: "usb-c, 2m cable!", want: []string{"usb", "c", "2m", "cable"}},
+		{name: "unicode letters", text: "Café Crème", want: []string{"café", "crème"}},
+		{name: "nothing to search", text: " -- ", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.text)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/audit_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/repo"
+	"net/http"
+)
+
+var audit_table = "auditlogs"
+
+type AuditService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var AuditServiceManager *AuditService
+
+func NewAuditService(debug bool) *AuditService {
+	if AuditServiceManager == nil {
+		// casting registers the json Changes the scan has to decode
+		changeset.CastValues(&domain.AuditLog{}, map[string]any{})
+		AuditServiceManager = &AuditService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "AuditService",
+		}
+	}
+	return AuditServiceManager
+}
+
+func (s *AuditService) ListAudit(ctx context.Context, userId uint32, req *audit_dto.AuditListReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	if !infrastructure.AdminUserIds[userId] {
+		base_response.StatusCode = http.StatusForbidden
+		base_response.ErrCodeString = "only an admin can browse the audit log"
+		return base_response
+	}
+	if !repo.IsAudited(req.Entity) {
+		base_response.TransformToBadRequest("entity is not audited")
+		return base_response
+	}
+	builder := s.repo.GetById(&domain.AuditLog{})
+	builder.
+		Select(repo.Col("Id", audit_table)).
+		Select(repo.Col("Entity", audit_table)).
+		Select(repo.Col("EntityId", audit_table)).
+		Select(repo.Col("ActorId", audit_table)).
+		Select(repo.Col("Action", audit_table)).
+		Select(repo.Col("Changes", audit_table)).
+		Select(repo.Col("CreatedAt", audit_table)).
+		Where(repo.P("Entity", audit_table, repo.Equal, req.Entity))
+	if req.Id != 0 {
+		builder.Where(repo.P("EntityId", audit_table, repo.Equal, req.Id))
+	}
+	builder.
+		OrderBy(repo.Col("Id", audit_table), repo.DESC).
+		Limit(audit_dto.MaxAuditRecordsListed)
+	if _, err := builder.SeekAfter(req.Cursor); err != nil {
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.AuditLog{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	records := make([]*domain.AuditLog, 0, len(entities))
+	for _, entity := range entities {
+		records = append(records, entity.(*domain.AuditLog))
+	}
+	next_cursor := ""
+	if len(records) == audit_dto.MaxAuditRecordsListed {
+		next_cursor, _ = builder.CursorOf(records[len(records)-1])
+	}
+	base_response.TransformToStatusOk(&audit_dto.AuditListRes{
+		Records:    records,
+		NextCursor: next_cursor,
+	})
+	return base_response
+}
@@ -0,0 +1,676 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/cart_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/repo"
+	"ebayclone/valueobject"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	cart_table      = "carts"
+	cart_item_table = "cartitems"
+)
+
+type CartService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var CartServiceManager *CartService
+
+func NewCartService(debug bool) *CartService {
+	if CartServiceManager == nil {
+		CartServiceManager = &CartService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "CartService",
+		}
+	}
+	return CartServiceManager
+}
+
+func newSessionKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// findCart reads the first cart matching predicates, nil when none does. With tx it is read
+// with FOR UPDATE and locked until tx ends
+func (s *CartService) findCart(ctx context.Context, tx *sql.Tx, predicates ...*repo.Predicate) (*domain.Cart, error) {
+	builder := s.repo.GetById(&domain.Cart{})
+	builder.
+		Select(repo.Col("Id", cart_table)).
+		Select(repo.Col("UserId", cart_table)).
+		Select(repo.Col("SessionKey", cart_table)).
+		Select(repo.Col("UpdatedAt", cart_table))
+	for _, predicate := range predicates {
+		builder.Where(predicate)
+	}
+	if tx != nil {
+		builder.ForUpdate()
+	}
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.Cart{})
+	if err != nil || len(entities) == 0 {
+		return nil, err
+	}
+	return entities[0].(*domain.Cart), nil
+}
+
+func (s *CartService) findUserCart(ctx context.Context, tx *sql.Tx, userId uint32) (*domain.Cart, error) {
+	return s.findCart(ctx, tx, repo.P("UserId", cart_table, repo.Equal, userId))
+}
+
+func (s *CartService) findAnonymousCart(ctx context.Context, tx *sql.Tx, sessionKey string) (*domain.Cart, error) {
+	return s.findCart(ctx, tx,
+		repo.P("SessionKey", cart_table, repo.Equal, sessionKey),
+		repo.P("UserId", cart_table, repo.Equal, 0))
+}
+
+// createCart creates the cart of identity. An anonymous cart always gets a new session key,
+// a key sent by the client is never saved so nobody can pick the key of a cart
+func (s *CartService) createCart(ctx context.Context, identity *cart_dto.CartIdentity) (*domain.Cart, error) {
+	sessionKey := ""
+	if identity.UserId == 0 {
+		var err error
+		if sessionKey, err = newSessionKey(); err != nil {
+			return nil, err
+		}
+	}
+	cart_entity := &domain.Cart{}
+	cart_changeset := changeset.CastValues(cart_entity, map[string]any{
+		"UserId":     identity.UserId,
+		"SessionKey": sessionKey,
+		"UpdatedAt":  time.Now().UTC(),
+	})
+	if err := s.repo.Save(ctx, cart_changeset); err != nil {
+		return nil, err
+	}
+	return cart_entity, nil
+}
+
+// resolveCart finds the cart of identity. Writes set merge to move the anonymous cart into
+// the user cart when both are known, reads leave it. A cart is created only when create is set
+func (s *CartService) resolveCart(ctx context.Context, identity *cart_dto.CartIdentity, create bool, merge bool) (*domain.Cart, error) {
+	var cart_entity *domain.Cart
+	var err error
+	if identity.UserId > 0 {
+		if merge && identity.SessionKey != "" {
+			if err = s.MergeAnonymousCart(ctx, identity.UserId, identity.SessionKey); err != nil {
+				return nil, err
+			}
+		}
+		cart_entity, err = s.findUserCart(ctx, nil, identity.UserId)
+	} else if identity.SessionKey != "" {
+		cart_entity, err = s.findAnonymousCart(ctx, nil, identity.SessionKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cart_entity == nil && create {
+		return s.createCart(ctx, identity)
+	}
+	return cart_entity, nil
+}
+
+func (s *CartService) itemsBuilder(cartId uint32) *repo.QueryBuilder {
+	builder := s.repo.GetById(&domain.CartItem{})
+	builder.
+		Select(repo.Col("Id", cart_item_table)).
+		Select(repo.Col("ProductVariantId", cart_item_table)).
+		Select(repo.Col("Quantity", cart_item_table)).
+		Select(repo.Col("PriceAmount", cart_item_table)).
+		Select(repo.Col("PriceCurrency", cart_item_table)).
+		Select(repo.Col("CartId", cart_item_table).As("CartRel$Id")).
+		Select(repo.Col("ProductId", cart_item_table).As("ProductRel$Id")).
+		Where(repo.P("CartId", cart_item_table, repo.Equal, cartId)).
+		OrderBy(repo.Col("Id", cart_item_table), repo.ASC)
+	return builder
+}
+
+func toCartItems(entities []interface{}) []*domain.CartItem {
+	items := make([]*domain.CartItem, 0, len(entities))
+	for _, entity := range entities {
+		items = append(items, entity.(*domain.CartItem))
+	}
+	return items
+}
+
+func (s *CartService) listItems(ctx context.Context, cartId uint32) ([]*domain.CartItem, error) {
+	query, args := s.itemsBuilder(cartId).Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.CartItem{})
+	if err != nil {
+		return nil, err
+	}
+	return toCartItems(entities), nil
+}
+
+// lockItems reads the items with FOR UPDATE, two checkouts of one cart can not both win
+func (s *CartService) lockItems(ctx context.Context, tx *sql.Tx, cartId uint32) ([]*domain.CartItem, error) {
+	query, args := s.itemsBuilder(cartId).ForUpdate().Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.CartItem{})
+	if err != nil {
+		return nil, err
+	}
+	return toCartItems(entities), nil
+}
+
+func findItem(items []*domain.CartItem, productId uint32, variantId uint32) *domain.CartItem {
+	for _, item := range items {
+		if item.ProductRel.Id == productId && item.ProductVariantId == variantId {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *CartService) touchCart(ctx context.Context, cartId uint32) {
+	cart_changeset := changeset.CastValues(&domain.Cart{Id: cartId}, map[string]any{
+		"UpdatedAt": time.Now().UTC(),
+	})
+	if err := s.repo.UpdateById(ctx, cart_changeset); err != nil {
+		log_util.PrintFlag(s.serviceName, s.debug, fmt.Sprintf("touch cart [%v]: %v", cartId, err))
+	}
+}
+
+// cartMerge is what merging anonymous items into user items writes
+type cartMerge struct {
+	moved      []*domain.CartItem // anonymous items the user cart does not have, they change cart
+	quantities map[uint32]uint32  // new quantity by user item id for items both carts have
+	dropped    []*domain.CartItem // anonymous items added into a user item, they are deleted
+}
+
+// planCartMerge adds the quantities of same items, up to MaxCartItemQuantity
+func planCartMerge(user_items []*domain.CartItem, anonymous_items []*domain.CartItem) *cartMerge {
+	plan := &cartMerge{quantities: map[uint32]uint32{}}
+	for _, anonymous_item := range anonymous_items {
+		same_item := findItem(user_items, anonymous_item.ProductRel.Id, anonymous_item.ProductVariantId)
+		if same_item == nil {
+			plan.moved = append(plan.moved, anonymous_item)
+			continue
+		}
+		quantity, ok := plan.quantities[same_item.Id]
+		if !ok {
+			quantity = same_item.Quantity
+		}
+		quantity += anonymous_item.Quantity
+		if quantity > domain.MaxCartItemQuantity {
+			quantity = domain.MaxCartItemQuantity
+		}
+		plan.quantities[same_item.Id] = quantity
+		plan.dropped = append(plan.dropped, anonymous_item)
+	}
+	return plan
+}
+
+// MergeAnonymousCart moves the anonymous cart of sessionKey into the cart of userId,
+// the login flow calls it once the user is known. Same items add their quantities.
+// Both carts are locked before deciding, the user cart first, so concurrent merges of one
+// user or one session wait for each other instead of merging twice or creating two user carts
+func (s *CartService) MergeAnonymousCart(ctx context.Context, userId uint32, sessionKey string) error {
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		return errors.New("can not open transaction")
+	}
+	user_cart, err := s.findUserCart(ctx, tx, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	anonymous_cart, err := s.findAnonymousCart(ctx, tx, sessionKey)
+	if err != nil || anonymous_cart == nil {
+		tx.Rollback()
+		return err
+	}
+	if user_cart == nil {
+		// user has no cart yet, the anonymous one simply becomes the user cart
+		cart_changeset := changeset.CastValues(&domain.Cart{Id: anonymous_cart.Id}, map[string]any{
+			"UserId":     userId,
+			"SessionKey": "",
+			"UpdatedAt":  time.Now().UTC(),
+		})
+		if err := s.repo.UpdateTxById(ctx, cart_changeset, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	anonymous_items, err := s.lockItems(ctx, tx, anonymous_cart.Id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	user_items, err := s.lockItems(ctx, tx, user_cart.Id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	plan := planCartMerge(user_items, anonymous_items)
+	for _, moved_item := range plan.moved {
+		item_changeset := changeset.CastValues(&domain.CartItem{Id: moved_item.Id}, map[string]any{
+			"CartRel": &domain.Cart{Id: user_cart.Id},
+		})
+		if err = s.repo.UpdateTxById(ctx, item_changeset, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for itemId, quantity := range plan.quantities {
+		item_changeset := changeset.CastValues(&domain.CartItem{Id: itemId}, map[string]any{
+			"Quantity": quantity,
+		})
+		if err = s.repo.UpdateTxById(ctx, item_changeset, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, dropped_item := range plan.dropped {
+		if err = s.repo.DeleteTxById(ctx, changeset.CastValues(&domain.CartItem{Id: dropped_item.Id}, map[string]any{}), tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = s.repo.DeleteTxById(ctx, changeset.CastValues(&domain.Cart{Id: anonymous_cart.Id}, map[string]any{}), tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *CartService) AddItem(ctx context.Context, identity *cart_dto.CartIdentity, req *cart_dto.CartAddItemReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if req.Quantity == 0 || req.Quantity > domain.MaxCartItemQuantity {
+		base_response.TransformToBadRequest(fmt.Sprintf("quantity must be between 1 and %v", domain.MaxCartItemQuantity))
+		return base_response
+	}
+	product_entity, err := ProductServiceManager.findProductById(ctx, req.ProductId)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_entity == nil {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+	price, stock, ok := product_entity.Offer(req.VariantId)
+	if !ok {
+		base_response.TransformToBadRequest("variant_id does not match a variant of this product")
+		return base_response
+	}
+
+	cart_entity, err := s.resolveCart(ctx, identity, true, true)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	items, err := s.listItems(ctx, cart_entity.Id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	quantity := req.Quantity
+	same_item := findItem(items, req.ProductId, req.VariantId)
+	if same_item != nil {
+		quantity += same_item.Quantity
+	}
+	if quantity > stock {
+		base_response.StatusCode = http.StatusConflict
+		base_response.ErrCodeString = fmt.Sprintf("%v: %v available", ErrNotEnoughStock, stock)
+		return base_response
+	}
+
+	if same_item != nil {
+		item_changeset := changeset.CastValues(&domain.CartItem{Id: same_item.Id}, map[string]any{
+			"Quantity":      quantity,
+			"PriceAmount":   price.Amount,
+			"PriceCurrency": price.Currency,
+		})
+		err = s.repo.UpdateById(ctx, item_changeset)
+	} else {
+		item_changeset := changeset.CastValues(&domain.CartItem{}, map[string]any{
+			"ProductVariantId": req.VariantId,
+			"Quantity":         quantity,
+			"PriceAmount":      price.Amount,
+			"PriceCurrency":    price.Currency,
+			"CartRel":          &domain.Cart{Id: cart_entity.Id},
+			"ProductRel":       &domain.Product{Id: req.ProductId},
+		})
+		err = s.repo.Save(ctx, item_changeset)
+	}
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	s.touchCart(ctx, cart_entity.Id)
+	return s.cartResponseOf(ctx, cart_entity, base_response)
+}
+
+func (s *CartService) UpdateQuantity(ctx context.Context, identity *cart_dto.CartIdentity, req *cart_dto.CartUpdateQuantityReq) *dto.BaseMessageResponse {
+	if req.Quantity == 0 {
+		return s.RemoveItem(ctx, identity, &cart_dto.CartRemoveItemReq{ItemId: req.ItemId})
+	}
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if req.Quantity > domain.MaxCartItemQuantity {
+		base_response.TransformToBadRequest(fmt.Sprintf("quantity must be between 1 and %v", domain.MaxCartItemQuantity))
+		return base_response
+	}
+	cart_entity, item, err := s.findItemOfIdentity(ctx, identity, req.ItemId)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if item == nil {
+		base_response.TransformToNotFoundEntity("CartItem")
+		return base_response
+	}
+	product_entity, err := ProductServiceManager.findProductById(ctx, item.ProductRel.Id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_entity == nil {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+	price, stock, ok := product_entity.Offer(item.ProductVariantId)
+	if !ok {
+		base_response.TransformToNotFoundEntity("ProductVariant")
+		return base_response
+	}
+	if req.Quantity > stock {
+		base_response.StatusCode = http.StatusConflict
+		base_response.ErrCodeString = fmt.Sprintf("%v: %v available", ErrNotEnoughStock, stock)
+		return base_response
+	}
+	item_changeset := changeset.CastValues(&domain.CartItem{Id: item.Id}, map[string]any{
+		"Quantity":      req.Quantity,
+		"PriceAmount":   price.Amount,
+		"PriceCurrency": price.Currency,
+	})
+	if err = s.repo.UpdateById(ctx, item_changeset); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	s.touchCart(ctx, cart_entity.Id)
+	return s.cartResponseOf(ctx, cart_entity, base_response)
+}
+
+func (s *CartService) RemoveItem(ctx context.Context, identity *cart_dto.CartIdentity, req *cart_dto.CartRemoveItemReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	cart_entity, item, err := s.findItemOfIdentity(ctx, identity, req.ItemId)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if item == nil {
+		base_response.TransformToNotFoundEntity("CartItem")
+		return base_response
+	}
+	if err = s.repo.DeleteById(ctx, changeset.CastValues(&domain.CartItem{Id: item.Id}, map[string]any{})); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	s.touchCart(ctx, cart_entity.Id)
+	return s.cartResponseOf(ctx, cart_entity, base_response)
+}
+
+// findItemOfIdentity finds an item only inside the cart of identity, ids of other carts
+// are not found and give a nil item
+func (s *CartService) findItemOfIdentity(ctx context.Context, identity *cart_dto.CartIdentity, itemId uint32) (*domain.Cart, *domain.CartItem, error) {
+	cart_entity, err := s.resolveCart(ctx, identity, false, true)
+	if err != nil || cart_entity == nil {
+		return nil, nil, err
+	}
+	items, err := s.listItems(ctx, cart_entity.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, item := range items {
+		if item.Id == itemId {
+			return cart_entity, item, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func (s *CartService) GetCart(ctx context.Context, identity *cart_dto.CartIdentity) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	cart_entity, err := s.resolveCart(ctx, identity, false, false)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if cart_entity == nil {
+		base_response.TransformToStatusOk(&cart_dto.CartRes{
+			Items:  []*cart_dto.CartItemRes{},
+			Totals: []valueobject.Money{},
+		})
+		return base_response
+	}
+	return s.cartResponseOf(ctx, cart_entity, base_response)
+}
+
+func (s *CartService) cartResponseOf(ctx context.Context, cart_entity *domain.Cart, base_response *dto.BaseMessageResponse) *dto.BaseMessageResponse {
+	res, err := s.cartResponse(ctx, cart_entity)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(res)
+	return base_response
+}
+
+// cartResponse re-validates every item against current price and stock, a changed
+// price is saved on the item so the buyer is told about it only once
+func (s *CartService) cartResponse(ctx context.Context, cart_entity *domain.Cart) (*cart_dto.CartRes, error) {
+	res := &cart_dto.CartRes{
+		CartId:     cart_entity.Id,
+		SessionKey: cart_entity.SessionKey,
+		Items:      []*cart_dto.CartItemRes{},
+		Totals:     []valueobject.Money{},
+	}
+	products := map[uint32]*domain.Product{}
+	totals := map[string]valueobject.Money{}
+	currencies := []string{}
+	items, err := s.listItems(ctx, cart_entity.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		item_res := &cart_dto.CartItemRes{
+			ItemId:    item.Id,
+			ProductId: item.ProductRel.Id,
+			VariantId: item.ProductVariantId,
+			Quantity:  item.Quantity,
+			UnitPrice: valueobject.Money{Amount: item.PriceAmount, Currency: item.PriceCurrency},
+		}
+		res.Items = append(res.Items, item_res)
+
+		product_entity, loaded := products[item.ProductRel.Id]
+		if !loaded {
+			if product_entity, err = ProductServiceManager.findProductById(ctx, item.ProductRel.Id); err != nil {
+				return nil, err
+			}
+			products[item.ProductRel.Id] = product_entity
+		}
+		if product_entity == nil {
+			item_res.Unavailable = true
+			continue
+		}
+		item_res.Name = product_entity.Name
+		price, stock, ok := product_entity.Offer(item.ProductVariantId)
+		if !ok {
+			item_res.Unavailable = true
+			continue
+		}
+		item_res.AvailableStock = stock
+		item_res.InsufficientStock = item.Quantity > stock
+		if price != item_res.UnitPrice {
+			previous := item_res.UnitPrice
+			item_res.PreviousUnitPrice = &previous
+			item_res.PriceChanged = true
+			item_res.UnitPrice = price
+			item_changeset := changeset.CastValues(&domain.CartItem{Id: item.Id}, map[string]any{
+				"PriceAmount":   price.Amount,
+				"PriceCurrency": price.Currency,
+			})
+			if err := s.repo.UpdateById(ctx, item_changeset); err != nil {
+				log_util.PrintFlag(s.serviceName, s.debug, fmt.Sprintf("update price of cart item [%v]: %v", item.Id, err))
+			}
+		}
+		lineTotal, err := price.MulQuantity(item.Quantity)
+		if err != nil {
+			item_res.Unavailable = true
+			continue
+		}
+		item_res.LineTotal = lineTotal
+		total, exist := totals[price.Currency]
+		if !exist {
+			currencies = append(currencies, price.Currency)
+			total = valueobject.Money{Currency: price.Currency}
+		}
+		if total, err = total.Add(lineTotal); err == nil {
+			totals[price.Currency] = total
+		}
+	}
+	for _, currency := range currencies {
+		res.Totals = append(res.Totals, totals[currency])
+	}
+	return res, nil
+}
+
+// Checkout turns the cart of a logged in user into one order: stock of every line is
+// reserved, the order is created and the cart emptied in a single transaction
+func (s *CartService) Checkout(ctx context.Context, identity *cart_dto.CartIdentity) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if identity.UserId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required to checkout"
+		return base_response
+	}
+	cart_entity, err := s.resolveCart(ctx, identity, false, true)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if cart_entity == nil {
+		base_response.TransformToBadRequest("cart is empty")
+		return base_response
+	}
+
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
+	items, err := s.lockItems(ctx, tx, cart_entity.Id)
+	if err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if len(items) == 0 {
+		tx.Rollback()
+		base_response.TransformToBadRequest("cart is empty")
+		return base_response
+	}
+
+	drafts := make([]*orderLineDraft, 0, len(items))
+	for _, item := range items {
+		product_entity, err := ProductServiceManager.findProductById(ctx, item.ProductRel.Id)
+		if err != nil {
+			tx.Rollback()
+			base_response.TransformToError(err)
+			return base_response
+		}
+		if product_entity == nil {
+			tx.Rollback()
+			base_response.StatusCode = http.StatusConflict
+			base_response.ErrCodeString = fmt.Sprintf("product [%v] is no longer available", item.ProductRel.Id)
+			return base_response
+		}
+		price, _, ok := product_entity.Offer(item.ProductVariantId)
+		if !ok {
+			tx.Rollback()
+			base_response.StatusCode = http.StatusConflict
+			base_response.ErrCodeString = fmt.Sprintf("variant [%v] is no longer available", item.ProductVariantId)
+			return base_response
+		}
+		if len(drafts) > 0 && drafts[0].UnitPrice.Currency != price.Currency {
+			tx.Rollback()
+			base_response.TransformToBadRequest("cart has items in several currencies, checkout them separately")
+			return base_response
+		}
+		drafts = append(drafts, &orderLineDraft{
+			ProductId: item.ProductRel.Id,
+			VariantId: item.ProductVariantId,
+			Quantity:  item.Quantity,
+			UnitPrice: price,
+		})
+	}
+
+	order_entity, reservationExpiresAt, err := OrderServiceManager.createOrderTx(ctx, tx, identity.UserId, drafts)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, ErrNotEnoughStock) {
+			base_response.StatusCode = http.StatusConflict
+		}
+		base_response.TransformToError(err)
+		return base_response
+	}
+	for _, item := range items {
+		if err = s.repo.DeleteTxById(ctx, changeset.CastValues(&domain.CartItem{Id: item.Id}, map[string]any{}), tx); err != nil {
+			tx.Rollback()
+			base_response.TransformToError(err)
+			return base_response
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&cart_dto.CartCheckoutRes{
+		OrderId:              order_entity.Id,
+		Total:                order_entity.Total(),
+		ReservationExpiresAt: reservationExpiresAt.Format(time.RFC3339),
+	})
+	return base_response
+}
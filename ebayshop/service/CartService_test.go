@@ -0,0 +1,81 @@
+package service
+
+import (
+	"ebayclone/domain"
+	"reflect"
+	"testing"
+)
+
+func cartItem(id uint32, productId uint32, variantId uint32, quantity uint32) *domain.CartItem {
+	return &domain.CartItem{
+		Id:               id,
+		ProductVariantId: variantId,
+		Quantity:         quantity,
+		ProductRel:       &domain.Product{Id: productId},
+	}
+}
+
+func TestPlanCartMerge(t *testing.T) {
+	tests := []struct {
+		name           string
+		userItems      []*domain.CartItem
+		anonymousItems []*domain.CartItem
+		wantMoved      []uint32
+		wantQuantities map[uint32]uint32
+		wantDropped    []uint32
+	}{
+		{
+			name:           "empty user cart takes every item",
+			anonymousItems: []*domain.CartItem{cartItem(10, 1, 0, 2), cartItem(11, 2, 5, 1)},
+			wantMoved:      []uint32{10, 11},
+			wantQuantities: map[uint32]uint32{},
+		},
+		{
+			name:           "same item adds quantities",
+			userItems:      []*domain.CartItem{cartItem(1, 1, 0, 3)},
+			anonymousItems: []*domain.CartItem{cartItem(10, 1, 0, 2)},
+			wantQuantities: map[uint32]uint32{1: 5},
+			wantDropped:    []uint32{10},
+		},
+		{
+			name:           "other variant of same product is moved",
+			userItems:      []*domain.CartItem{cartItem(1, 1, 4, 3)},
+			anonymousItems: []*domain.CartItem{cartItem(10, 1, 5, 2)},
+			wantMoved:      []uint32{10},
+			wantQuantities: map[uint32]uint32{},
+		},
+		{
+			name:           "quantity is capped",
+			userItems:      []*domain.CartItem{cartItem(1, 1, 0, domain.MaxCartItemQuantity-1)},
+			anonymousItems: []*domain.CartItem{cartItem(10, 1, 0, 5)},
+			wantQuantities: map[uint32]uint32{1: domain.MaxCartItemQuantity},
+			wantDropped:    []uint32{10},
+		},
+		{
+			name:           "empty anonymous cart writes nothing",
+			userItems:      []*domain.CartItem{cartItem(1, 1, 0, 3)},
+			wantQuantities: map[uint32]uint32{},
+		},
+	}
+	ids := func(items []*domain.CartItem) []uint32 {
+		var result []uint32
+		for _, item := range items {
+			result = append(result, item.Id)
+		}
+		return result
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planCartMerge(tt.userItems, tt.anonymousItems)
+			if got := ids(plan.moved); !reflect.DeepEqual(got, tt.wantMoved) {
+				t.Errorf("moved = %v, want %v", got, tt.wantMoved)
+			}
+			if !reflect.DeepEqual(plan.quantities, tt.wantQuantities) {
+				t.Errorf("quantities = %v, want %v", plan.quantities, tt.wantQuantities)
+			}
+			if got := ids(plan.dropped); !reflect.DeepEqual(got, tt.wantDropped) {
+				t.Errorf("dropped = %v, want %v", got, tt.wantDropped)
+			}
+		})
+	}
+}
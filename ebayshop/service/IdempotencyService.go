@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/repo"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyRace = errors.New("idempotency key taken and released concurrently")
+	idempotency_key_table = "idempotencykeys"
+)
+
+type IdempotencyService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+	window      time.Duration
+	inFlight    time.Duration
+}
+
+var IdempotencyServiceManager *IdempotencyService
+
+func NewIdempotencyService(debug bool) *IdempotencyService {
+	if IdempotencyServiceManager == nil {
+		IdempotencyServiceManager = &IdempotencyService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "IdempotencyService",
+			window:      infrastructure.IdempotencyKeyWindow,
+			inFlight:    infrastructure.IdempotencyInFlightTimeout,
+		}
+	}
+	return IdempotencyServiceManager
+}
+
+func (s *IdempotencyService) findKey(ctx context.Context, key string, route string) (*domain.IdempotencyKey, error) {
+	builder := s.repo.GetById(&domain.IdempotencyKey{})
+	builder.
+		Select(repo.Col("Id", idempotency_key_table)).
+		Select(repo.Col("IdemKey", idempotency_key_table)).
+		Select(repo.Col("Route", idempotency_key_table)).
+		Select(repo.Col("RequestHash", idempotency_key_table)).
+		Select(repo.Col("StatusCode", idempotency_key_table)).
+		Select(repo.Col("ResponseBody", idempotency_key_table, repo.IFNULLSTR)).
+		Select(repo.Col("ExpiresAt", idempotency_key_table)).
+		Where(repo.P("IdemKey", idempotency_key_table, repo.Equal, key)).
+		Where(repo.P("Route", idempotency_key_table, repo.Equal, route))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.IdempotencyKey{})
+	if err != nil || len(entities) == 0 {
+		return nil, err
+	}
+	return entities[0].(*domain.IdempotencyKey), nil
+}
+
+// freeKeys matches expired keys and keys still running after the in-flight timeout. A key
+// has no creation time, it was created a window before it expires
+func (s *IdempotencyService) freeKeys(now time.Time) *repo.Predicate {
+	return repo.Or(
+		repo.P("ExpiresAt", idempotency_key_table, repo.Less, now),
+		repo.And(
+			repo.P("StatusCode", idempotency_key_table, repo.Equal, 0),
+			repo.P("ExpiresAt", idempotency_key_table, repo.Less, now.Add(s.window-s.inFlight)),
+		),
+	)
+}
+
+// Begin claims key for route. When created is true the caller runs the request and must
+// call Complete or Release, otherwise record is the earlier request to compare and replay
+func (s *IdempotencyService) Begin(ctx context.Context, key string, route string, requestHash string) (record *domain.IdempotencyKey, created bool, err error) {
+	now := time.Now().UTC()
+	// an expired or stale key is free again
+	_, err = s.repo.DeleteWhere(ctx, &domain.IdempotencyKey{}, repo.And(
+		repo.P("IdemKey", idempotency_key_table, repo.Equal, key),
+		repo.P("Route", idempotency_key_table, repo.Equal, route),
+		s.freeKeys(now),
+	))
+	if err != nil {
+		return nil, false, err
+	}
+	record = &domain.IdempotencyKey{}
+	key_changeset := changeset.CastValues(record, map[string]any{
+		"IdemKey":     key,
+		"Route":       route,
+		"RequestHash": requestHash,
+		"StatusCode":  0,
+		"ExpiresAt":   now.Add(s.window),
+	})
+	err = s.repo.Save(ctx, key_changeset)
+	if err == nil {
+		return record, true, nil
+	}
+	if repo.GetErrCode(err) != repo.ErrCodeDuplicate {
+		return nil, false, err
+	}
+	record, err = s.findKey(ctx, key, route)
+	if err != nil {
+		return nil, false, err
+	}
+	if record == nil {
+		return nil, false, ErrIdempotencyKeyRace
+	}
+	return record, false, nil
+}
+
+func (s *IdempotencyService) Complete(ctx context.Context, record *domain.IdempotencyKey, statusCode int, responseBody string) error {
+	key_changeset := changeset.CastValues(&domain.IdempotencyKey{Id: record.Id}, map[string]any{
+		"StatusCode":   statusCode,
+		"ResponseBody": responseBody,
+	})
+	return s.repo.UpdateById(ctx, key_changeset)
+}
+
+// Release frees the key so the client can retry, used when the request failed on our side
+func (s *IdempotencyService) Release(ctx context.Context, record *domain.IdempotencyKey) error {
+	return s.repo.DeleteById(ctx, changeset.CastValues(&domain.IdempotencyKey{Id: record.Id}, map[string]any{}))
+}
+
+// SweepExpiredKeys deletes expired and stale keys every interval until ctx is done
+func (s *IdempotencyService) SweepExpiredKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.repo.DeleteWhere(ctx, &domain.IdempotencyKey{}, s.freeKeys(time.Now().UTC()))
+			if err != nil {
+				log_util.Print(s.serviceName, fmt.Sprintf("delete expired idempotency keys: %v", err))
+				continue
+			}
+			log_util.PrintFlag(s.serviceName, s.debug, fmt.Sprintf("expired idempotency keys deleted [%v]", n))
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"ebayclone/domain"
+	"ebayclone/repo"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFreeKeys(t *testing.T) {
+	s := &IdempotencyService{window: 24 * time.Hour, inFlight: 5 * time.Minute}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	builder := (&repo.Repo{}).GetById(&domain.IdempotencyKey{})
+	builder.Where(repo.And(
+		repo.P("IdemKey", idempotency_key_table, repo.Equal, "k"),
+		s.freeKeys(now),
+	))
+	query, args := builder.Query()
+	// the OR must stay inside the key, or Begin would free the keys of other clients
+	want := "FROM `idempotencykeys` WHERE `idempotencykeys`.`IdemKey` = ? AND (`idempotencykeys`.`ExpiresAt` < ? OR (`idempotencykeys`.`StatusCode` = ? AND `idempotencykeys`.`ExpiresAt` < ?))"
+	if query != want {
+		t.Errorf("query\n got: %v\nwant: %v", query, want)
+	}
+	// a running key created 5 minutes ago expires in 23h55m
+	stale := now.Add(23*time.Hour + 55*time.Minute)
+	if !reflect.DeepEqual(args, []interface{}{"k", now, 0, stale}) {
+		t.Errorf("args %v", args)
+	}
+}
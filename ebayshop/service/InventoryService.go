@@ -0,0 +1,565 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/inventory_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/repo"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotEnoughStock        = errors.New("not enough stock")
+	ErrNotFoundStockLevel    = errors.New("stock level not found")
+	ErrReservationNotActive  = errors.New("reservation is not active")
+	ErrNotFoundReservation   = errors.New("reservation not found")
+	ErrInvalidQuantity       = errors.New("quantity must be greater than 0")
+	ErrReturnExceedsSold     = errors.New("return exceeds the quantity sold")
+	ErrLoginRequired         = errors.New("login required")
+	ErrStockForbidden        = errors.New("only the seller of the product or an admin can move its stock")
+	DefaultReservationTTL    = 15 * time.Minute
+	ReservationSweepInterval = time.Minute
+	stock_level_table        = "stocklevels"
+	stock_reservation_table  = "stockreservations"
+	stock_movement_table     = "stockmovements"
+)
+
+type InventoryService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var InventoryServiceManager *InventoryService
+
+func NewInventoryService(debug bool) *InventoryService {
+	if InventoryServiceManager == nil {
+		InventoryServiceManager = &InventoryService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "InventoryService",
+		}
+	}
+	return InventoryServiceManager
+}
+
+func (s *InventoryService) stockLevelBuilder() *repo.QueryBuilder {
+	builder := s.repo.GetById(&domain.StockLevel{})
+	builder.
+		Select(repo.Col("Id", stock_level_table)).
+		Select(repo.Col("ProductVariantId", stock_level_table)).
+		Select(repo.Col("OnHand", stock_level_table)).
+		Select(repo.Col("Reserved", stock_level_table)).
+		Select(repo.Col("ProductId", stock_level_table).As("ProductRel$Id"))
+	return builder
+}
+
+// lockStockLevel reads the stock level with SELECT ... FOR UPDATE, so concurrent
+// reservations of the same product wait for tx to finish
+func (s *InventoryService) lockStockLevel(ctx context.Context, tx *sql.Tx, productId uint32, variantId uint32) (*domain.StockLevel, error) {
+	builder := s.stockLevelBuilder().
+		Where(repo.P("ProductId", stock_level_table, repo.Equal, productId)).
+		Where(repo.P("ProductVariantId", stock_level_table, repo.Equal, variantId)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.StockLevel{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFoundStockLevel
+	}
+	return entities[0].(*domain.StockLevel), nil
+}
+
+func (s *InventoryService) lockReservation(ctx context.Context, tx *sql.Tx, reservationId uint32) (*domain.StockReservation, error) {
+	builder := s.repo.GetById(&domain.StockReservation{})
+	builder.
+		Select(repo.Col("Id", stock_reservation_table)).
+		Select(repo.Col("Quantity", stock_reservation_table)).
+		Select(repo.Col("Status", stock_reservation_table)).
+		Select(repo.Col("ExpiresAt", stock_reservation_table)).
+		Select(repo.Col("StockLevelId", stock_reservation_table).As("StockLevelRel$Id")).
+		Where(repo.P("Id", stock_reservation_table, repo.Equal, reservationId)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.StockReservation{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFoundReservation
+	}
+	return entities[0].(*domain.StockReservation), nil
+}
+
+// lockStockLevelById locks the stock level inside tx, a nil tx reads it without a lock
+func (s *InventoryService) lockStockLevelById(ctx context.Context, tx *sql.Tx, stockLevelId uint32) (*domain.StockLevel, error) {
+	builder := s.stockLevelBuilder().
+		Where(repo.P("Id", stock_level_table, repo.Equal, stockLevelId))
+	if tx != nil {
+		builder.ForUpdate()
+	}
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.StockLevel{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFoundStockLevel
+	}
+	return entities[0].(*domain.StockLevel), nil
+}
+
+func (s *InventoryService) updateStockLevel(ctx context.Context, tx *sql.Tx, level *domain.StockLevel) error {
+	level_changeset := changeset.CastValues(&domain.StockLevel{Id: level.Id}, map[string]any{
+		"OnHand":   level.OnHand,
+		"Reserved": level.Reserved,
+	})
+	return s.repo.UpdateTxById(ctx, level_changeset, tx)
+}
+
+func (s *InventoryService) appendMovement(ctx context.Context, tx *sql.Tx, level *domain.StockLevel, kind domain.StockMovementKind, quantity uint32, reservationId uint32) error {
+	movement_changeset := changeset.CastValues(&domain.StockMovement{}, map[string]any{
+		"Kind":          string(kind),
+		"Quantity":      quantity,
+		"ReservationId": reservationId,
+		"CreatedAt":     time.Now().UTC(),
+		"StockLevelRel": &domain.StockLevel{Id: level.Id},
+	})
+	return s.repo.SaveTx(ctx, movement_changeset, tx)
+}
+
+// Receive adds stock, the stock level row is created on first receive. The quantity is
+// added by one upsert, so concurrent first receives of one product both count and neither
+// gets a duplicate. The row is then read back with the lock the upsert took
+func (s *InventoryService) Receive(ctx context.Context, tx *sql.Tx, productId uint32, variantId uint32, quantity uint32) (*domain.StockLevel, error) {
+	if quantity == 0 {
+		return nil, ErrInvalidQuantity
+	}
+	level_changeset := changeset.CastValues(&domain.StockLevel{}, map[string]any{
+		"ProductVariantId": variantId,
+		"OnHand":           quantity,
+		"Reserved":         uint32(0),
+		"ProductRel":       &domain.Product{Id: productId},
+	})
+	err := s.repo.UpsertTx(ctx, level_changeset, &repo.UpsertOptions{
+		ConflictFields:  []string{"ProductRel", "ProductVariantId"},
+		IncrementFields: []string{"OnHand"},
+	}, tx)
+	if err != nil {
+		return nil, err
+	}
+	level, err := s.lockStockLevel(ctx, tx, productId, variantId)
+	if err != nil {
+		return nil, err
+	}
+	return level, s.appendMovement(ctx, tx, level, domain.StockMovementReceive, quantity, 0)
+}
+
+// Return puts sold items back on hand, never more than were sold and not yet returned.
+// The lock on the stock level keeps concurrent returns from both passing the check
+func (s *InventoryService) Return(ctx context.Context, tx *sql.Tx, productId uint32, variantId uint32, quantity uint32) (*domain.StockLevel, error) {
+	if quantity == 0 {
+		return nil, ErrInvalidQuantity
+	}
+	level, err := s.lockStockLevel(ctx, tx, productId, variantId)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.movementTotals(ctx, tx, level.Id, domain.StockMovementSell, domain.StockMovementReturn)
+	if err != nil {
+		return nil, err
+	}
+	if returnable := returnableQuantity(totals); quantity > returnable {
+		return nil, fmt.Errorf("%w: product [%v] variant [%v] has %v returnable", ErrReturnExceedsSold, productId, variantId, returnable)
+	}
+	level.OnHand += quantity
+	if err = s.updateStockLevel(ctx, tx, level); err != nil {
+		return nil, err
+	}
+	return level, s.appendMovement(ctx, tx, level, domain.StockMovementReturn, quantity, 0)
+}
+
+// movementTotals sums the ledger of a stock level by kind
+func (s *InventoryService) movementTotals(ctx context.Context, tx *sql.Tx, stockLevelId uint32, kinds ...domain.StockMovementKind) (map[domain.StockMovementKind]uint32, error) {
+	values := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		values = append(values, string(kind))
+	}
+	builder := s.repo.GetById(&domain.StockMovement{})
+	builder.
+		Select(repo.Col("Kind", stock_movement_table)).
+		Select(repo.Sum(repo.Col("Quantity", stock_movement_table)).As("Quantity")).
+		Where(repo.P("StockLevelId", stock_movement_table, repo.Equal, stockLevelId)).
+		Where(repo.P("Kind", stock_movement_table, repo.In, values)).
+		GroupBy(repo.Col("Kind", stock_movement_table))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.StockMovement{})
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[domain.StockMovementKind]uint32, len(entities))
+	for _, entity := range entities {
+		movement := entity.(*domain.StockMovement)
+		totals[domain.StockMovementKind(movement.Kind)] = movement.Quantity
+	}
+	return totals, nil
+}
+
+// returnableQuantity is what was sold and not returned yet
+func returnableQuantity(totals map[domain.StockMovementKind]uint32) uint32 {
+	sold, returned := totals[domain.StockMovementSell], totals[domain.StockMovementReturn]
+	if returned >= sold {
+		return 0
+	}
+	return sold - returned
+}
+
+// Reserve holds quantity for ttl, the reservation is released by the sweeper
+// if it is neither sold nor released before it expires
+func (s *InventoryService) Reserve(ctx context.Context, tx *sql.Tx, productId uint32, variantId uint32, quantity uint32, ttl time.Duration) (*domain.StockReservation, error) {
+	level, err := s.lockStockLevel(ctx, tx, productId, variantId)
+	if errors.Is(err, ErrNotFoundStockLevel) {
+		// nothing was ever received
+		return nil, fmt.Errorf("%w: product [%v] variant [%v] has 0 available", ErrNotEnoughStock, productId, variantId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if quantity == 0 || level.Available() < quantity {
+		return nil, fmt.Errorf("%w: product [%v] variant [%v] has %v available", ErrNotEnoughStock, productId, variantId, level.Available())
+	}
+	level.Reserved += quantity
+	if err = s.updateStockLevel(ctx, tx, level); err != nil {
+		return nil, err
+	}
+	reservation := &domain.StockReservation{}
+	reservation_changeset := changeset.CastValues(reservation, map[string]any{
+		"Quantity":      quantity,
+		"Status":        string(domain.StockReservationActive),
+		"ExpiresAt":     time.Now().UTC().Add(ttl),
+		"StockLevelRel": &domain.StockLevel{Id: level.Id},
+	})
+	if err = s.repo.SaveTx(ctx, reservation_changeset, tx); err != nil {
+		return nil, err
+	}
+	return reservation, s.appendMovement(ctx, tx, level, domain.StockMovementReserve, quantity, reservation.Id)
+}
+
+// Release gives reserved stock back, kind is release for abandoned checkouts
+func (s *InventoryService) Release(ctx context.Context, tx *sql.Tx, reservationId uint32) error {
+	return s.closeReservation(ctx, tx, reservationId, domain.StockReservationReleased, domain.StockMovementRelease)
+}
+
+// Sell turns a reservation into a sale, stock leaves on hand
+func (s *InventoryService) Sell(ctx context.Context, tx *sql.Tx, reservationId uint32) error {
+	return s.closeReservation(ctx, tx, reservationId, domain.StockReservationSold, domain.StockMovementSell)
+}
+
+func (s *InventoryService) closeReservation(ctx context.Context, tx *sql.Tx, reservationId uint32, status domain.StockReservationStatus, kind domain.StockMovementKind) error {
+	reservation, err := s.lockReservation(ctx, tx, reservationId)
+	if err != nil {
+		return err
+	}
+	if reservation.Status != string(domain.StockReservationActive) {
+		return fmt.Errorf("%w [%v]", ErrReservationNotActive, reservationId)
+	}
+	level, err := s.lockStockLevelById(ctx, tx, reservation.StockLevelRel.Id)
+	if err != nil {
+		return err
+	}
+	level.Reserved -= reservation.Quantity
+	if kind == domain.StockMovementSell {
+		level.OnHand -= reservation.Quantity
+	}
+	if err = s.updateStockLevel(ctx, tx, level); err != nil {
+		return err
+	}
+	reservation_changeset := changeset.CastValues(&domain.StockReservation{Id: reservation.Id}, map[string]any{
+		"Status": string(status),
+	})
+	if err = s.repo.UpdateTxById(ctx, reservation_changeset, tx); err != nil {
+		return err
+	}
+	return s.appendMovement(ctx, tx, level, kind, reservation.Quantity, reservation.Id)
+}
+
+// IsReservationActive locks the reservation and tells if it can still be sold
+func (s *InventoryService) IsReservationActive(ctx context.Context, tx *sql.Tx, reservationId uint32) (bool, error) {
+	reservation, err := s.lockReservation(ctx, tx, reservationId)
+	if err != nil {
+		return false, err
+	}
+	return reservation.Status == string(domain.StockReservationActive), nil
+}
+
+// GetStockLevels returns stock levels of a product keyed by variant id, 0 is the product itself
+func (s *InventoryService) GetStockLevels(ctx context.Context, productId uint32) (map[uint32]*domain.StockLevel, error) {
+	builder := s.repo.GetById(&domain.StockLevel{})
+	builder.
+		Select(repo.Col("Id", stock_level_table)).
+		Select(repo.Col("ProductVariantId", stock_level_table)).
+		Select(repo.Col("OnHand", stock_level_table)).
+		Select(repo.Col("Reserved", stock_level_table)).
+		Where(repo.P("ProductId", stock_level_table, repo.Equal, productId))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.StockLevel{})
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[uint32]*domain.StockLevel, len(entities))
+	for _, entity := range entities {
+		level := entity.(*domain.StockLevel)
+		levels[level.ProductVariantId] = level
+	}
+	return levels, nil
+}
+
+// SweepExpiredReservations releases expired reservations every interval until ctx is done
+func (s *InventoryService) SweepExpiredReservations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ReleaseExpiredReservations(ctx); err != nil {
+				log_util.Print(s.serviceName, fmt.Sprintf("release expired reservations: %v", err))
+			}
+		}
+	}
+}
+
+// ReleaseExpiredReservations releases active reservations past their expiry, each in its own transaction
+func (s *InventoryService) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	builder := s.repo.GetById(&domain.StockReservation{})
+	builder.
+		Select(repo.Col("Id", stock_reservation_table)).
+		Where(repo.P("Status", stock_reservation_table, repo.Equal, string(domain.StockReservationActive))).
+		Where(repo.P("ExpiresAt", stock_reservation_table, repo.Less, time.Now().UTC()))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.StockReservation{})
+	if err != nil {
+		return 0, err
+	}
+	released := 0
+	for _, entity := range entities {
+		reservation := entity.(*domain.StockReservation)
+		tx := s.repo.OpenTx(ctx)
+		if tx == nil {
+			return released, errors.New("can not open transaction")
+		}
+		if err := s.Release(ctx, tx, reservation.Id); err != nil {
+			// sold or released meanwhile by checkout
+			tx.Rollback()
+			log_util.PrintFlag(s.serviceName, s.debug, fmt.Sprintf("release expired reservation [%v]: %v", reservation.Id, err))
+			continue
+		}
+		if err := tx.Commit(); err == nil {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// runInTx opens a transaction for one inventory operation called from the api
+func (s *InventoryService) runInTx(ctx context.Context, operation func(tx *sql.Tx) (any, error)) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
+	result, err := operation(tx)
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, ErrNotFoundStockLevel), errors.Is(err, ErrNotFoundReservation), errors.Is(err, ErrNotFoundProduct):
+			base_response.TransformToNotFoundEntity(err.Error())
+		case errors.Is(err, ErrNotEnoughStock), errors.Is(err, ErrReservationNotActive), errors.Is(err, ErrReturnExceedsSold):
+			base_response.StatusCode = http.StatusConflict
+			base_response.TransformToError(err)
+		case errors.Is(err, ErrInvalidQuantity):
+			base_response.TransformToBadRequest(err.Error())
+		case errors.Is(err, ErrLoginRequired):
+			base_response.StatusCode = http.StatusUnauthorized
+			base_response.ErrCodeString = err.Error()
+		case errors.Is(err, ErrStockForbidden):
+			base_response.StatusCode = http.StatusForbidden
+			base_response.ErrCodeString = err.Error()
+		default:
+			base_response.TransformToError(err)
+		}
+		return base_response
+	}
+	if err = tx.Commit(); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(result)
+	return base_response
+}
+
+// authorizeSeller lets admins and the seller of a product move its stock
+func authorizeSeller(userId uint32, sellerId uint32) error {
+	if userId == 0 {
+		return ErrLoginRequired
+	}
+	if infrastructure.AdminUserIds[userId] || userId == sellerId {
+		return nil
+	}
+	return ErrStockForbidden
+}
+
+func (s *InventoryService) authorizeProduct(ctx context.Context, userId uint32, productId uint32) error {
+	if userId == 0 {
+		return ErrLoginRequired
+	}
+	builder := s.repo.GetById(&domain.Product{})
+	builder.
+		Select(repo.Col("Id", "products")).
+		Select(repo.Col("SellerId", "products")).
+		Where(repo.P("Id", "products", repo.Equal, productId))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil {
+		return err
+	}
+	if len(entities) == 0 {
+		return ErrNotFoundProduct
+	}
+	return authorizeSeller(userId, entities[0].(*domain.Product).SellerId)
+}
+
+// authorizeReservation checks the product the reservation holds stock of, inside tx
+func (s *InventoryService) authorizeReservation(ctx context.Context, tx *sql.Tx, userId uint32, reservationId uint32) error {
+	if userId == 0 {
+		return ErrLoginRequired
+	}
+	reservation, err := s.lockReservation(ctx, tx, reservationId)
+	if err != nil {
+		return err
+	}
+	level, err := s.lockStockLevelById(ctx, tx, reservation.StockLevelRel.Id)
+	if err != nil {
+		return err
+	}
+	return s.authorizeProduct(ctx, userId, level.ProductRel.Id)
+}
+
+func (s *InventoryService) ReceiveStock(ctx context.Context, userId uint32, req *inventory_dto.StockChangeReq) *dto.BaseMessageResponse {
+	return s.runInTx(ctx, func(tx *sql.Tx) (any, error) {
+		if err := s.authorizeProduct(ctx, userId, req.ProductId); err != nil {
+			return nil, err
+		}
+		return s.Receive(ctx, tx, req.ProductId, req.VariantId, req.Quantity)
+	})
+}
+
+func (s *InventoryService) ReturnStock(ctx context.Context, userId uint32, req *inventory_dto.StockChangeReq) *dto.BaseMessageResponse {
+	return s.runInTx(ctx, func(tx *sql.Tx) (any, error) {
+		if err := s.authorizeProduct(ctx, userId, req.ProductId); err != nil {
+			return nil, err
+		}
+		return s.Return(ctx, tx, req.ProductId, req.VariantId, req.Quantity)
+	})
+}
+
+func (s *InventoryService) ReserveStock(ctx context.Context, userId uint32, req *inventory_dto.StockReserveReq) *dto.BaseMessageResponse {
+	ttl := DefaultReservationTTL
+	if req.TtlSeconds > 0 {
+		ttl = time.Duration(req.TtlSeconds) * time.Second
+	}
+	return s.runInTx(ctx, func(tx *sql.Tx) (any, error) {
+		if err := s.authorizeProduct(ctx, userId, req.ProductId); err != nil {
+			return nil, err
+		}
+		return s.Reserve(ctx, tx, req.ProductId, req.VariantId, req.Quantity, ttl)
+	})
+}
+
+func (s *InventoryService) ReleaseReservation(ctx context.Context, userId uint32, req *inventory_dto.ReservationReq) *dto.BaseMessageResponse {
+	return s.runInTx(ctx, func(tx *sql.Tx) (any, error) {
+		if err := s.authorizeReservation(ctx, tx, userId, req.ReservationId); err != nil {
+			return nil, err
+		}
+		return nil, s.Release(ctx, tx, req.ReservationId)
+	})
+}
+
+func (s *InventoryService) SellReservation(ctx context.Context, userId uint32, req *inventory_dto.ReservationReq) *dto.BaseMessageResponse {
+	return s.runInTx(ctx, func(tx *sql.Tx) (any, error) {
+		if err := s.authorizeReservation(ctx, tx, userId, req.ReservationId); err != nil {
+			return nil, err
+		}
+		return nil, s.Sell(ctx, tx, req.ReservationId)
+	})
+}
+
+func (s *InventoryService) GetMovements(ctx context.Context, userId uint32, stockLevelId uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = ErrLoginRequired.Error()
+		return base_response
+	}
+	level, err := s.lockStockLevelById(ctx, nil, stockLevelId)
+	if errors.Is(err, ErrNotFoundStockLevel) {
+		base_response.TransformToNotFoundEntity("StockLevel")
+		return base_response
+	}
+	if err == nil {
+		err = s.authorizeProduct(ctx, userId, level.ProductRel.Id)
+	}
+	if errors.Is(err, ErrStockForbidden) {
+		base_response.StatusCode = http.StatusForbidden
+		base_response.ErrCodeString = err.Error()
+		return base_response
+	}
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	builder := s.repo.GetById(&domain.StockMovement{})
+	builder.
+		Select(repo.Col("Id", stock_movement_table)).
+		Select(repo.Col("Kind", stock_movement_table)).
+		Select(repo.Col("Quantity", stock_movement_table)).
+		Select(repo.Col("ReservationId", stock_movement_table)).
+		Select(repo.Col("CreatedAt", stock_movement_table)).
+		Select(repo.Col("StockLevelId", stock_movement_table).As("StockLevelRel$Id")).
+		Where(repo.P("StockLevelId", stock_movement_table, repo.Equal, stockLevelId)).
+		OrderBy(repo.Col("Id", stock_movement_table), repo.ASC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.StockMovement{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	movements := make([]*domain.StockMovement, 0, len(entities))
+	for _, entity := range entities {
+		movements = append(movements, entity.(*domain.StockMovement))
+	}
+	base_response.TransformToStatusOk(&inventory_dto.StockMovementsRes{
+		Movements: movements,
+	})
+	return base_response
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"ebayclone/domain"
+	"ebayclone/infrastructure"
+	"errors"
+	"testing"
+)
+
+func TestReturnableQuantity(t *testing.T) {
+	tests := []struct {
+		name   string
+		totals map[domain.StockMovementKind]uint32
+		want   uint32
+	}{
+		{name: "nothing sold", totals: map[domain.StockMovementKind]uint32{}, want: 0},
+		{name: "sold", totals: map[domain.StockMovementKind]uint32{domain.StockMovementSell: 5}, want: 5},
+		{name: "partly returned", totals: map[domain.StockMovementKind]uint32{domain.StockMovementSell: 5, domain.StockMovementReturn: 2}, want: 3},
+		{name: "all returned", totals: map[domain.StockMovementKind]uint32{domain.StockMovementSell: 5, domain.StockMovementReturn: 5}, want: 0},
+		{name: "returned more than sold", totals: map[domain.StockMovementKind]uint32{domain.StockMovementSell: 1, domain.StockMovementReturn: 3}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := returnableQuantity(tt.totals); got != tt.want {
+				t.Errorf("returnableQuantity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeSeller(t *testing.T) {
+	infrastructure.AdminUserIds[99] = true
+	defer delete(infrastructure.AdminUserIds, 99)
+	tests := []struct {
+		name     string
+		userId   uint32
+		sellerId uint32
+		want     error
+	}{
+		{name: "seller", userId: 7, sellerId: 7},
+		{name: "admin", userId: 99, sellerId: 7},
+		{name: "other user", userId: 8, sellerId: 7, want: ErrStockForbidden},
+		{name: "anonymous", userId: 0, sellerId: 7, want: ErrLoginRequired},
+		{name: "anonymous on product without seller", userId: 0, sellerId: 0, want: ErrLoginRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := authorizeSeller(tt.userId, tt.sellerId); !errors.Is(got, tt.want) {
+				t.Errorf("authorizeSeller() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
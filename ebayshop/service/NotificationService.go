@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/notification_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/repo"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var notification_table = "notifications"
+
+// matchQueueSize bounds the products waiting for the matcher, CreateProduct never blocks on it
+const matchQueueSize = 1024
+
+// MatchDrainTimeout bounds matching the products still queued at shutdown
+const MatchDrainTimeout = 10 * time.Second
+
+type NotificationService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+
+	matchQueue chan uint32
+	// dropped counts products never matched, the queue was full or the shutdown drain timed out
+	dropped atomic.Uint64
+}
+
+var NotificationServiceManager *NotificationService
+
+func NewNotificationService(debug bool) *NotificationService {
+	if NotificationServiceManager == nil {
+		NotificationServiceManager = &NotificationService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "NotificationService",
+			matchQueue:  make(chan uint32, matchQueueSize),
+		}
+	}
+	return NotificationServiceManager
+}
+
+// EnqueueNewProduct hands a committed product to the matcher. Delivery is at most once:
+// the queue lives in memory, so a product is not matched when the queue is full, when
+// matching fails or when the process stops first. Drops are logged and counted in Dropped
+func (s *NotificationService) EnqueueNewProduct(productId uint32) {
+	select {
+	case s.matchQueue <- productId:
+	default:
+		dropped := s.dropped.Add(1)
+		log_util.Print(s.serviceName, fmt.Sprintf("match queue full, product [%v] dropped, [%v] dropped so far", productId, dropped))
+	}
+}
+
+// Dropped is how many new products were never matched, see dropped
+func (s *NotificationService) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Run matches queued products until ctx is done, it is started from main with the process
+// signal context. A product being matched when ctx is done is still finished
+func (s *NotificationService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case productId := <-s.matchQueue:
+			s.match(context.WithoutCancel(ctx), productId)
+		}
+	}
+}
+
+// Drain matches the products still queued once Run returned and the server stopped, so
+// no request can queue more. What is left when timeout passes is counted in Dropped and logged
+func (s *NotificationService) Drain(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	left := 0
+	for {
+		select {
+		case productId := <-s.matchQueue:
+			if ctx.Err() != nil {
+				left++
+				continue
+			}
+			s.match(ctx, productId)
+		default:
+			if left > 0 {
+				dropped := s.dropped.Add(uint64(left))
+				log_util.Print(s.serviceName, fmt.Sprintf("drain timed out, [%v] products dropped, [%v] dropped so far", left, dropped))
+			}
+			return
+		}
+	}
+}
+
+func (s *NotificationService) match(ctx context.Context, productId uint32) {
+	if err := s.matchSavedSearches(ctx, productId); err != nil {
+		log_util.Print(s.serviceName, fmt.Sprintf("match saved searches of product [%v]: %v", productId, err))
+	}
+}
+
+// matchSavedSearches notifies the owners of saved searches the new product fits,
+// a seller is not notified about own products
+func (s *NotificationService) matchSavedSearches(ctx context.Context, productId uint32) error {
+	product_entity, err := ProductServiceManager.findProductById(ctx, productId)
+	if err != nil {
+		return err
+	}
+	if product_entity == nil || product_entity.ProductTypeRel == nil {
+		return ErrNotFoundProduct
+	}
+	saved_searches, err := WatchlistServiceManager.findSavedSearchesOfProductType(ctx, product_entity.ProductTypeRel.Id)
+	if err != nil {
+		return err
+	}
+	for _, saved_search_entity := range saved_searches {
+		if saved_search_entity.UserId == product_entity.SellerId || !saved_search_entity.Matches(product_entity) {
+			continue
+		}
+		notification_changeset := changeset.CastValues(&domain.Notification{}, map[string]any{
+			"UserId":        saved_search_entity.UserId,
+			"Kind":          string(domain.NotificationSavedSearchMatch),
+			"Message":       truncate(fmt.Sprintf("New match for [%v]: %v", saved_search_entity.Name, product_entity.Name), 255),
+			"SavedSearchId": saved_search_entity.Id,
+			"CreatedAt":     time.Now().UTC(),
+			"ProductRel":    &domain.Product{Id: product_entity.Id},
+		})
+		// notifications are unique on (SavedSearchId, ProductId), a replayed product is skipped
+		if err := s.repo.Save(ctx, notification_changeset); err != nil && repo.GetErrCode(err) != repo.ErrCodeDuplicate {
+			return err
+		}
+	}
+	return nil
+}
+
+func truncate(value string, size int) string {
+	runes := []rune(value)
+	if len(runes) <= size {
+		return value
+	}
+	return string(runes[:size])
+}
+
+func (s *NotificationService) ListNotifications(ctx context.Context, userId uint32, req *notification_dto.NotificationListReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	builder := s.repo.GetById(&domain.Notification{})
+	builder.
+		Select(repo.Col("Id", notification_table)).
+		Select(repo.Col("UserId", notification_table)).
+		Select(repo.Col("Kind", notification_table)).
+		Select(repo.Col("Message", notification_table)).
+		Select(repo.Col("SavedSearchId", notification_table)).
+		Select(repo.Col("IsRead", notification_table)).
+		Select(repo.Col("CreatedAt", notification_table)).
+		Select(repo.Col("ProductId", notification_table).As("ProductRel$Id")).
+		Where(repo.P("UserId", notification_table, repo.Equal, userId))
+	if req.UnreadOnly {
+		builder.Where(repo.P("IsRead", notification_table, repo.Equal, false))
+	}
+	builder.
+		OrderBy(repo.Col("Id", notification_table), repo.DESC).
+		Limit(notification_dto.MaxNotificationsListed)
+	if _, err := builder.SeekAfter(req.Cursor); err != nil {
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Notification{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	notifications := make([]*domain.Notification, 0, len(entities))
+	for _, entity := range entities {
+		notifications = append(notifications, entity.(*domain.Notification))
+	}
+	next_cursor := ""
+	if len(notifications) == notification_dto.MaxNotificationsListed {
+		next_cursor, _ = builder.CursorOf(notifications[len(notifications)-1])
+	}
+	base_response.TransformToStatusOk(&notification_dto.NotificationListRes{
+		Notifications: notifications,
+		NextCursor:    next_cursor,
+	})
+	return base_response
+}
+
+func (s *NotificationService) MarkRead(ctx context.Context, userId uint32, req *notification_dto.NotificationReadReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	builder := s.repo.GetById(&domain.Notification{})
+	builder.
+		Select(repo.Col("Id", notification_table)).
+		Select(repo.Col("UserId", notification_table)).
+		Where(repo.P("Id", notification_table, repo.Equal, req.Id))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Notification{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if len(entities) == 0 || userId == 0 || entities[0].(*domain.Notification).UserId != userId {
+		base_response.TransformToNotFoundEntity("Notification")
+		return base_response
+	}
+	err = s.repo.UpdateById(ctx, changeset.CastValues(&domain.Notification{Id: req.Id}, map[string]any{
+		"IsRead": true,
+	}))
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&notification_dto.NotificationReadReq{
+		Id: req.Id,
+	})
+	return base_response
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNotificationMatcherStopsAndDrainCountsLeftovers(t *testing.T) {
+	s := &NotificationService{serviceName: "NotificationService", matchQueue: make(chan uint32, 4)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return once its context was done")
+	}
+
+	s.EnqueueNewProduct(1)
+	s.EnqueueNewProduct(2)
+	// no time left to match, both are dropped instead of matched
+	s.Drain(0)
+	if len(s.matchQueue) != 0 || s.Dropped() != 2 {
+		t.Errorf("queued %v dropped %v, want 0 and 2", len(s.matchQueue), s.Dropped())
+	}
+	s.Drain(time.Second)
+}
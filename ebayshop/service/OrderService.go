@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/order_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/repo"
+	"ebayclone/valueobject"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotFoundOrder = errors.New("order not found")
+	order_table      = "orders"
+	order_line_table = "orderlines"
+)
+
+type OrderService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var OrderServiceManager *OrderService
+
+func NewOrderService(debug bool) *OrderService {
+	if OrderServiceManager == nil {
+		OrderServiceManager = &OrderService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "OrderService",
+		}
+	}
+	return OrderServiceManager
+}
+
+// orderLineDraft is a line before it is saved, unit price is the current price of the offer
+type orderLineDraft struct {
+	ProductId uint32
+	VariantId uint32
+	Quantity  uint32
+	UnitPrice valueobject.Money
+}
+
+// createOrderTx saves the order with its lines and reserves stock of every line in tx,
+// all lines must share one currency and totals are summed in minor units
+func (s *OrderService) createOrderTx(ctx context.Context, tx *sql.Tx, userId uint32, drafts []*orderLineDraft) (*domain.Order, time.Time, error) {
+	if len(drafts) == 0 {
+		return nil, time.Time{}, errors.New("order has no line")
+	}
+	currency := drafts[0].UnitPrice.Currency
+	lineTotals := make([]valueobject.Money, 0, len(drafts))
+	for _, draft := range drafts {
+		lineTotal, err := draft.UnitPrice.MulQuantity(draft.Quantity)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		lineTotals = append(lineTotals, lineTotal)
+	}
+	total, err := valueobject.SumMoney(currency, lineTotals...)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	order_entity := &domain.Order{}
+	order_changeset := changeset.CastValues(order_entity, map[string]any{
+		"UserId":      userId,
+		"Status":      string(domain.OrderPendingPayment),
+		"TotalAmount": total.Amount,
+		"Currency":    total.Currency,
+		"CreatedAt":   time.Now().UTC(),
+	})
+	if err = order_changeset.ValidValues(); err != nil {
+		return nil, time.Time{}, err
+	}
+	if err = s.repo.SaveTx(ctx, order_changeset, tx); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	reservationExpiresAt := time.Now().UTC().Add(DefaultReservationTTL)
+	line_changesets := make([]*changeset.ChangeSet, 0, len(drafts))
+	for index, draft := range drafts {
+		reservation, err := InventoryServiceManager.Reserve(ctx, tx, draft.ProductId, draft.VariantId, draft.Quantity, DefaultReservationTTL)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if reservation.ExpiresAt.Before(reservationExpiresAt) {
+			reservationExpiresAt = reservation.ExpiresAt
+		}
+		line_changeset := changeset.CastValues(&domain.OrderLine{}, map[string]any{
+			"ProductVariantId": draft.VariantId,
+			"Quantity":         draft.Quantity,
+			"UnitPriceAmount":  draft.UnitPrice.Amount,
+			"LineTotalAmount":  lineTotals[index].Amount,
+			"Currency":         currency,
+			"ReservationId":    reservation.Id,
+			"OrderRel":         &domain.Order{Id: order_entity.Id},
+			"ProductRel":       &domain.Product{Id: draft.ProductId},
+		})
+		line_changesets = append(line_changesets, line_changeset)
+	}
+	if err = s.repo.SaveAllTx(ctx, line_changesets, tx); err != nil {
+		return nil, time.Time{}, err
+	}
+	return order_entity, reservationExpiresAt, nil
+}
+
+func (s *OrderService) findOrderById(ctx context.Context, id uint32) (*domain.Order, error) {
+	builder := s.repo.GetById(&domain.Order{})
+	builder.
+		Select(repo.Col("Id", order_table)).
+		Select(repo.Col("UserId", order_table)).
+		Select(repo.Col("Status", order_table)).
+		Select(repo.Col("TotalAmount", order_table)).
+		Select(repo.Col("Currency", order_table)).
+		Select(repo.Col("CreatedAt", order_table)).
+		Where(repo.P("Id", order_table, repo.Equal, id))
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Order{})
+	if err != nil || len(entities) == 0 {
+		return nil, err
+	}
+	// lines and their products are loaded with one query each
+	if err = s.repo.Preload(ctx, entities, "OrderLineRel.ProductRel"); err != nil {
+		return nil, err
+	}
+	return entities[0].(*domain.Order), nil
+}
+
+// findOrderLines loads the lines of one order with only the id of their product,
+// inside tx so the lines match the order the caller locked
+func (s *OrderService) findOrderLines(ctx context.Context, tx *sql.Tx, orderId uint32) ([]*domain.OrderLine, error) {
+	builder := s.repo.GetById(&domain.OrderLine{})
+	builder.
+		Select(repo.Col("Id", order_line_table)).
+		Select(repo.Col("ProductVariantId", order_line_table)).
+		Select(repo.Col("Quantity", order_line_table)).
+		Select(repo.Col("UnitPriceAmount", order_line_table)).
+		Select(repo.Col("LineTotalAmount", order_line_table)).
+		Select(repo.Col("Currency", order_line_table)).
+		Select(repo.Col("ReservationId", order_line_table)).
+		Select(repo.Col("ProductId", order_line_table).As("ProductRel$Id")).
+		Where(repo.P("OrderId", order_line_table, repo.Equal, orderId)).
+		OrderBy(repo.Col("Id", order_line_table), repo.ASC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.OrderLine{})
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]*domain.OrderLine, 0, len(entities))
+	for _, entity := range entities {
+		lines = append(lines, entity.(*domain.OrderLine))
+	}
+	return lines, nil
+}
+
+func (s *OrderService) GetOrderById(ctx context.Context, id uint32, userId uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	order_entity, err := s.findOrderById(ctx, id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	// other users get not found, so order ids can not be probed
+	if order_entity == nil || order_entity.UserId != userId {
+		base_response.TransformToNotFoundEntity("Order")
+		return base_response
+	}
+	base_response.TransformToStatusOk(&order_dto.OrderGetRes{
+		Order: order_entity,
+	})
+	return base_response
+}
+
+func (s *OrderService) lockOrder(ctx context.Context, tx *sql.Tx, id uint32) (*domain.Order, error) {
+	builder := s.repo.GetById(&domain.Order{})
+	builder.
+		Select(repo.Col("Id", order_table)).
+		Select(repo.Col("UserId", order_table)).
+		Select(repo.Col("Status", order_table)).
+		Select(repo.Col("TotalAmount", order_table)).
+		Select(repo.Col("Currency", order_table)).
+		Where(repo.P("Id", order_table, repo.Equal, id)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.Order{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFoundOrder
+	}
+	return entities[0].(*domain.Order), nil
+}
+
+func (s *OrderService) updateOrderStatusTx(ctx context.Context, tx *sql.Tx, id uint32, status domain.OrderStatus) error {
+	order_changeset := changeset.CastValues(&domain.Order{Id: id}, map[string]any{
+		"Status": string(status),
+	})
+	return s.repo.UpdateTxById(ctx, order_changeset, tx)
+}
+
+// CompleteOrder is called by the buyer once the goods arrived, only paid orders can complete
+// and a completed order can be reviewed
+func (s *OrderService) CompleteOrder(ctx context.Context, id uint32, userId uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
+	order_entity, err := s.lockOrder(ctx, tx, id)
+	if err != nil && !errors.Is(err, ErrNotFoundOrder) {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if order_entity == nil || order_entity.UserId != userId {
+		tx.Rollback()
+		base_response.TransformToNotFoundEntity("Order")
+		return base_response
+	}
+	if order_entity.Status != string(domain.OrderPaid) {
+		tx.Rollback()
+		base_response.StatusCode = http.StatusConflict
+		base_response.ErrCodeString = fmt.Sprintf("order is %v", order_entity.Status)
+		return base_response
+	}
+	if err = s.updateOrderStatusTx(ctx, tx, id, domain.OrderCompleted); err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if err = tx.Commit(); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	order_entity.Status = string(domain.OrderCompleted)
+	base_response.TransformToStatusOk(&order_dto.OrderGetRes{
+		Order: order_entity,
+	})
+	return base_response
+}
@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/payment_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/payment"
+	"ebayclone/repo"
+	"ebayclone/valueobject"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotFoundPayment = errors.New("payment not found")
+	ErrOrderNotPending = errors.New("order is not pending payment")
+	payment_table      = "payments"
+)
+
+type PaymentService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var PaymentServiceManager *PaymentService
+
+func NewPaymentService(debug bool) *PaymentService {
+	if PaymentServiceManager == nil {
+		PaymentServiceManager = &PaymentService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "PaymentService",
+		}
+		// real providers register themselves the same way
+		if infrastructure.FakePaymentEnabled {
+			if infrastructure.FakePaymentSecret == "" {
+				panic("fake payment provider enabled without EBAYCLONE_FAKE_PAYMENT_SECRET")
+			}
+			payment.Register(payment.NewFakeProvider(infrastructure.FakePaymentSecret))
+		}
+	}
+	return PaymentServiceManager
+}
+
+func (s *PaymentService) savePayment(ctx context.Context, order_entity *domain.Order, providerName string, result *payment.Result) (*domain.Payment, error) {
+	payment_entity := &domain.Payment{}
+	payment_changeset := changeset.CastValues(payment_entity, map[string]any{
+		"Provider":    providerName,
+		"ProviderRef": result.ProviderRef,
+		"Amount":      order_entity.TotalAmount,
+		"Currency":    order_entity.Currency,
+		"Status":      string(result.Status),
+		"UpdatedAt":   time.Now().UTC(),
+		"OrderRel":    &domain.Order{Id: order_entity.Id},
+	})
+	return payment_entity, s.repo.Save(ctx, payment_changeset)
+}
+
+// Pay authorizes and captures the total of a pending order. The order itself moves
+// through the same event handling as webhooks, so a later webhook for it is a no-op
+func (s *PaymentService) Pay(ctx context.Context, userId uint32, req *payment_dto.PaymentPayReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	providerName := req.Provider
+	if providerName == "" {
+		base_response.TransformToBadRequest("payment provider required")
+		return base_response
+	}
+	provider, ok := payment.Get(providerName)
+	if !ok {
+		base_response.TransformToBadRequest(fmt.Sprintf("unknown payment provider [%v]", providerName))
+		return base_response
+	}
+	order_entity, err := s.claimOrder(ctx, req.OrderId, userId)
+	if err != nil {
+		if errors.Is(err, ErrNotFoundOrder) {
+			base_response.TransformToNotFoundEntity("Order")
+			return base_response
+		}
+		if errors.Is(err, ErrOrderNotPending) {
+			base_response.StatusCode = http.StatusConflict
+			base_response.ErrCodeString = fmt.Sprintf("order is %v", order_entity.Status)
+			return base_response
+		}
+		base_response.TransformToError(err)
+		return base_response
+	}
+
+	result, authorizeErr := provider.Authorize(ctx, &payment.AuthorizeRequest{
+		OrderId: order_entity.Id,
+		Amount:  order_entity.Total(),
+	})
+	if result == nil {
+		s.releaseClaim(ctx, order_entity.Id)
+		base_response.StatusCode = http.StatusBadGateway
+		base_response.ErrCodeString = authorizeErr.Error()
+		return base_response
+	}
+	payment_entity, err := s.savePayment(ctx, order_entity, providerName, result)
+	if err != nil {
+		s.releaseClaim(ctx, order_entity.Id)
+		base_response.TransformToError(err)
+		return base_response
+	}
+
+	event := &payment.WebhookEvent{
+		EventId:     "capture:" + result.ProviderRef,
+		Type:        payment.EventCaptured,
+		ProviderRef: result.ProviderRef,
+		Amount:      order_entity.Total(),
+	}
+	if authorizeErr != nil {
+		event.EventId = "authorize:" + result.ProviderRef
+		event.Type = payment.EventFailed
+	} else if _, err = provider.Capture(ctx, result.ProviderRef, order_entity.Total()); err != nil {
+		s.releaseClaim(ctx, order_entity.Id)
+		base_response.StatusCode = http.StatusBadGateway
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if _, err = s.applyEvent(ctx, provider, event); err != nil {
+		// a captured order stays paying, the provider webhook for the capture settles it
+		if authorizeErr != nil {
+			s.releaseClaim(ctx, order_entity.Id)
+		}
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if authorizeErr != nil {
+		base_response.StatusCode = http.StatusPaymentRequired
+		base_response.ErrCodeString = authorizeErr.Error()
+		return base_response
+	}
+	base_response.TransformToStatusOk(&payment_dto.PaymentRes{
+		PaymentId:   payment_entity.Id,
+		OrderId:     order_entity.Id,
+		ProviderRef: result.ProviderRef,
+		Status:      string(payment.StatusCaptured),
+	})
+	return base_response
+}
+
+// claimOrder moves a pending order of the user to paying, so a second Pay for it gets a
+// conflict instead of charging the buyer twice. A not pending order is returned with ErrOrderNotPending
+func (s *PaymentService) claimOrder(ctx context.Context, orderId uint32, userId uint32) (*domain.Order, error) {
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		return nil, errors.New("can not open transaction")
+	}
+	order_entity, err := OrderServiceManager.lockOrder(ctx, tx, orderId)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// other users get not found, so order ids can not be probed
+	if order_entity.UserId != userId {
+		tx.Rollback()
+		return nil, ErrNotFoundOrder
+	}
+	if order_entity.Status != string(domain.OrderPendingPayment) {
+		tx.Rollback()
+		return order_entity, ErrOrderNotPending
+	}
+	if err = OrderServiceManager.updateOrderStatusTx(ctx, tx, order_entity.Id, domain.OrderPaying); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	order_entity.Status = string(domain.OrderPaying)
+	return order_entity, nil
+}
+
+// releaseClaim moves an order still paying back to pending after the payment did not go
+// through, so the buyer can pay again. A failure is logged, the order stays paying
+func (s *PaymentService) releaseClaim(ctx context.Context, orderId uint32) {
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		log_util.Print(s.serviceName, fmt.Sprintf("order [%v] left %v: can not open transaction", orderId, domain.OrderPaying))
+		return
+	}
+	order_entity, err := OrderServiceManager.lockOrder(ctx, tx, orderId)
+	if err == nil && order_entity.Status == string(domain.OrderPaying) {
+		err = OrderServiceManager.updateOrderStatusTx(ctx, tx, orderId, domain.OrderPendingPayment)
+	}
+	if err != nil {
+		tx.Rollback()
+		log_util.Print(s.serviceName, fmt.Sprintf("order [%v] left %v: %v", orderId, domain.OrderPaying, err))
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		log_util.Print(s.serviceName, fmt.Sprintf("order [%v] left %v: %v", orderId, domain.OrderPaying, err))
+	}
+}
+
+func (s *PaymentService) HandleWebhook(ctx context.Context, providerName string, payload []byte, signature string) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if providerName == "" {
+		base_response.TransformToBadRequest("payment provider required")
+		return base_response
+	}
+	provider, ok := payment.Get(providerName)
+	if !ok {
+		base_response.TransformToBadRequest(fmt.Sprintf("unknown payment provider [%v]", providerName))
+		return base_response
+	}
+	event, err := provider.VerifyWebhook(payload, signature)
+	if err != nil {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.TransformToError(err)
+		return base_response
+	}
+	duplicate, err := s.applyEvent(ctx, provider, event)
+	if err != nil {
+		if errors.Is(err, ErrNotFoundPayment) {
+			base_response.TransformToNotFoundEntity("Payment")
+			return base_response
+		}
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&payment_dto.PaymentWebhookRes{
+		EventId:   event.EventId,
+		Duplicate: duplicate,
+	})
+	return base_response
+}
+
+func (s *PaymentService) lockPayment(ctx context.Context, tx *sql.Tx, providerName string, providerRef string) (*domain.Payment, error) {
+	builder := s.repo.GetById(&domain.Payment{})
+	builder.
+		Select(repo.Col("Id", payment_table)).
+		Select(repo.Col("Provider", payment_table)).
+		Select(repo.Col("ProviderRef", payment_table)).
+		Select(repo.Col("Amount", payment_table)).
+		Select(repo.Col("Currency", payment_table)).
+		Select(repo.Col("Status", payment_table)).
+		Select(repo.Col("OrderId", payment_table).As("OrderRel$Id")).
+		Where(repo.P("Provider", payment_table, repo.Equal, providerName)).
+		Where(repo.P("ProviderRef", payment_table, repo.Equal, providerRef)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.Payment{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("%w [%v]", ErrNotFoundPayment, providerRef)
+	}
+	return entities[0].(*domain.Payment), nil
+}
+
+func (s *PaymentService) updatePaymentStatusTx(ctx context.Context, tx *sql.Tx, id uint32, status payment.Status) error {
+	payment_changeset := changeset.CastValues(&domain.Payment{Id: id}, map[string]any{
+		"Status":    string(status),
+		"UpdatedAt": time.Now().UTC(),
+	})
+	return s.repo.UpdateTxById(ctx, payment_changeset, tx)
+}
+
+// applyEvent moves payment and order for one provider event in one transaction.
+// The event row is inserted first: a duplicate key means it was already handled.
+// A refund decided in the transaction is sent to the provider after the commit
+func (s *PaymentService) applyEvent(ctx context.Context, provider payment.Provider, event *payment.WebhookEvent) (bool, error) {
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		return false, errors.New("can not open transaction")
+	}
+	event_changeset := changeset.CastValues(&domain.PaymentEvent{}, map[string]any{
+		"Provider":    provider.Name(),
+		"EventId":     event.EventId,
+		"Type":        string(event.Type),
+		"ProcessedAt": time.Now().UTC(),
+	})
+	if err := s.repo.SaveTx(ctx, event_changeset, tx); err != nil {
+		tx.Rollback()
+		if repo.GetErrCode(err) == repo.ErrCodeDuplicate {
+			return true, nil
+		}
+		return false, err
+	}
+	payment_entity, err := s.lockPayment(ctx, tx, provider.Name(), event.ProviderRef)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	order_entity, err := OrderServiceManager.lockOrder(ctx, tx, payment_entity.OrderRel.Id)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	refund := false
+	switch event.Type {
+	case payment.EventCaptured:
+		refund, err = s.onCaptured(ctx, tx, payment_entity, order_entity)
+	case payment.EventFailed:
+		err = s.onFailed(ctx, tx, payment_entity, order_entity)
+	case payment.EventRefunded:
+		err = s.onRefunded(ctx, tx, payment_entity, order_entity)
+	default:
+		log_util.PrintFlag(s.serviceName, s.debug, fmt.Sprintf("ignore payment event type [%v]", event.Type))
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	if refund {
+		s.refundPending(ctx, provider, payment_entity, order_entity.Total())
+	}
+	return false, nil
+}
+
+// refundPending gives back a payment left in refund_pending. When the provider fails the
+// payment stays refund_pending, it is logged so the refund can be sent again
+func (s *PaymentService) refundPending(ctx context.Context, provider payment.Provider, payment_entity *domain.Payment, amount valueobject.Money) {
+	_, err := provider.Refund(ctx, payment_entity.ProviderRef, amount)
+	if err == nil {
+		err = s.repo.UpdateById(ctx, changeset.CastValues(&domain.Payment{Id: payment_entity.Id}, map[string]any{
+			"Status":    string(payment.StatusRefunded),
+			"UpdatedAt": time.Now().UTC(),
+		}))
+	}
+	if err != nil {
+		log_util.Print(s.serviceName, fmt.Sprintf("payment [%v] left %v: %v", payment_entity.Id, domain.PaymentRefundPending, err))
+	}
+}
+
+// onCaptured sells the reserved stock and marks the order paid. When the order can not be
+// served, because it no longer awaits payment or a reservation expired before the money
+// arrived, the payment is marked refund_pending and refund tells the caller to refund after commit
+func (s *PaymentService) onCaptured(ctx context.Context, tx *sql.Tx, payment_entity *domain.Payment, order_entity *domain.Order) (refund bool, err error) {
+	switch payment_entity.Status {
+	case string(payment.StatusCaptured), string(payment.StatusRefunded), domain.PaymentRefundPending:
+		// the capture was already handled under another event id
+		return false, nil
+	}
+	if !order_entity.AwaitsPayment() {
+		return true, s.updatePaymentStatusTx(ctx, tx, payment_entity.Id, payment.Status(domain.PaymentRefundPending))
+	}
+	lines, err := OrderServiceManager.findOrderLines(ctx, tx, order_entity.Id)
+	if err != nil {
+		return false, err
+	}
+	allActive := true
+	for _, line := range lines {
+		active, err := InventoryServiceManager.IsReservationActive(ctx, tx, line.ReservationId)
+		if err != nil {
+			return false, err
+		}
+		allActive = allActive && active
+	}
+	if !allActive {
+		if err = s.releaseLines(ctx, tx, lines); err != nil {
+			return false, err
+		}
+		if err = s.updatePaymentStatusTx(ctx, tx, payment_entity.Id, payment.Status(domain.PaymentRefundPending)); err != nil {
+			return false, err
+		}
+		return true, OrderServiceManager.updateOrderStatusTx(ctx, tx, order_entity.Id, domain.OrderCancelled)
+	}
+	for _, line := range lines {
+		if err = InventoryServiceManager.Sell(ctx, tx, line.ReservationId); err != nil {
+			return false, err
+		}
+	}
+	if err = s.updatePaymentStatusTx(ctx, tx, payment_entity.Id, payment.StatusCaptured); err != nil {
+		return false, err
+	}
+	return false, OrderServiceManager.updateOrderStatusTx(ctx, tx, order_entity.Id, domain.OrderPaid)
+}
+
+func (s *PaymentService) onFailed(ctx context.Context, tx *sql.Tx, payment_entity *domain.Payment, order_entity *domain.Order) error {
+	if err := s.updatePaymentStatusTx(ctx, tx, payment_entity.Id, payment.StatusFailed); err != nil {
+		return err
+	}
+	if !order_entity.AwaitsPayment() {
+		return nil
+	}
+	lines, err := OrderServiceManager.findOrderLines(ctx, tx, order_entity.Id)
+	if err != nil {
+		return err
+	}
+	if err = s.releaseLines(ctx, tx, lines); err != nil {
+		return err
+	}
+	return OrderServiceManager.updateOrderStatusTx(ctx, tx, order_entity.Id, domain.OrderCancelled)
+}
+
+func (s *PaymentService) onRefunded(ctx context.Context, tx *sql.Tx, payment_entity *domain.Payment, order_entity *domain.Order) error {
+	if err := s.updatePaymentStatusTx(ctx, tx, payment_entity.Id, payment.StatusRefunded); err != nil {
+		return err
+	}
+	if order_entity.Status != string(domain.OrderPaid) && order_entity.Status != string(domain.OrderCompleted) {
+		return nil
+	}
+	lines, err := OrderServiceManager.findOrderLines(ctx, tx, order_entity.Id)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err = InventoryServiceManager.Return(ctx, tx, line.ProductRel.Id, line.ProductVariantId, line.Quantity); err != nil {
+			return err
+		}
+	}
+	return OrderServiceManager.updateOrderStatusTx(ctx, tx, order_entity.Id, domain.OrderRefunded)
+}
+
+// releaseLines gives back stock still held by the lines, expired reservations are already released
+func (s *PaymentService) releaseLines(ctx context.Context, tx *sql.Tx, lines []*domain.OrderLine) error {
+	for _, line := range lines {
+		err := InventoryServiceManager.Release(ctx, tx, line.ReservationId)
+		if err != nil && !errors.Is(err, ErrReservationNotActive) {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,16 +2,21 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"ebayclone/changeset"
 	"ebayclone/domain"
 	"ebayclone/dto"
 	"ebayclone/dto/product"
 	"ebayclone/infrastructure"
 	"ebayclone/repo"
+	"ebayclone/valueobject"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
+var ErrNotFoundProduct = errors.New("product not found")
+
 type ProductService struct {
 	repo  *repo.Repo
 	debug bool
@@ -23,7 +28,7 @@ func NewProductServiceManager(debug bool) *ProductService {
 	if ProductServiceManager == nil {
 		ProductServiceManager = &ProductService{
 			debug: debug,
-			repo:  repo.NewRepo(infrastructure.MysqlConfig, debug),
+			repo:  repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
 		}
 	}
 	return ProductServiceManager
@@ -44,60 +49,625 @@ func (p *ProductService) CreateService(ctx context.Context, req *product.Product
 }
 
 func (p *ProductService) CreateProduct(ctx context.Context, req *product.ProductCreateReq) *dto.BaseMessageResponse {
-	tx := p.repo.OpenTx(ctx)
 	base_response := &dto.BaseMessageResponse{
 		StatusCode:    http.StatusInternalServerError,
 		ErrCodeString: "",
 		ReponseObject: nil,
 	}
+	if req.SellerId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
 	product_type_entity_before := ProductTypeServiceManager.getProductTypeEntityExistById(req.ProductTypeId)
 	if product_type_entity_before == nil {
 		base_response.TransformToNotFoundEntity("ProductTypeService, sorry hacker")
 		return base_response
 	}
 
+	fields := valueobject.FieldsJSON{}
+	if req.Fields != nil {
+		fields = *req.Fields
+	}
+	variantOptions := make([]valueobject.VariantOptionsJSON, 0, len(req.Variants))
+	seenSkus := map[string]bool{}
+	for index, variantReq := range req.Variants {
+		if variantReq == nil || variantReq.Sku == "" {
+			base_response.TransformToBadRequest(fmt.Sprintf("variant at position %v has empty sku", index))
+			return base_response
+		}
+		if seenSkus[variantReq.Sku] {
+			base_response.TransformToBadRequest(fmt.Sprintf("duplicate sku [%v]", variantReq.Sku))
+			return base_response
+		}
+		seenSkus[variantReq.Sku] = true
+		variantOptions = append(variantOptions, variantReq.Options)
+	}
+	listingPrice, err := listingPriceOfProduct(req)
+	if err != nil {
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	if err := product_type_entity_before.ValidateFields(fields, variantOptions...); err != nil {
+		if errors.Is(err, domain.ErrNotFoundAttributeId) || errors.Is(err, domain.ErrNotFoundOptionValueId) {
+			base_response.TransformToNotFoundEntity(err.Error())
+			return base_response
+		}
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+
+	// counters are added to the locked row in the transaction, cache is replaced only after commit
+	aggregate_increments := &valueobject.AggregateFieldJSON{}
+	for attributeIdCreated, optionValueIdsCreated := range fields {
+		for _, optionValueIdCreated := range optionValueIdsCreated {
+			aggregate_increments.Increment(attributeIdCreated, optionValueIdCreated, 1)
+		}
+	}
+	// each variant is one sellable combination, so it counts once per option it picks
+	for _, options := range variantOptions {
+		for attributeIdCreated, optionValueIdCreated := range options {
+			aggregate_increments.Increment(attributeIdCreated, optionValueIdCreated, 1)
+		}
+	}
+
+	tx := p.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
 	product_entity := &domain.Product{}
 	product_changeset := changeset.CastValues(product_entity, map[string]any{
-		"Name": req.Name,
+		"Name":          req.Name,
+		"SellerId":      req.SellerId,
+		"PriceAmount":   listingPrice.Amount,
+		"PriceCurrency": listingPrice.Currency,
+		"Fields":        &fields,
 		"ProductTypeRel": &domain.ProductType{
 			Id: req.ProductTypeId,
 		},
 	})
+	if err = product_changeset.ValidValues(); err != nil {
+		tx.Rollback()
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
 
-	err := p.repo.SaveTx(ctx, product_changeset, tx)
+	err = p.repo.SaveTx(ctx, product_changeset, tx)
 	if err != nil {
-		base_response.ErrCodeString = err.Error()
+		tx.Rollback()
+		base_response.TransformToError(err)
 		return base_response
 	}
 
-	product_type_entity_cloned_update := product_type_entity_before.CloneProductType()
-	fmt.Println(product_type_entity_before.AggregateFields, product_type_entity_cloned_update.AggregateFields)
-	for attributeIdCreated, optionValueIdCreated := range *req.Fields {
-		if _, existAttributeId := product_type_entity_before.AggregateFields.Fields[attributeIdCreated]; !existAttributeId {
-			base_response.TransformToNotFoundEntity("ProductType Not Found Attribute Id")
+	variant_entities := make([]*domain.ProductVariant, 0, len(req.Variants))
+	variant_changesets := make([]*changeset.ChangeSet, 0, len(req.Variants))
+	for _, variantReq := range req.Variants {
+		variant_entity := &domain.ProductVariant{}
+		variant_changeset := changeset.CastValues(variant_entity, map[string]any{
+			"Sku":           variantReq.Sku,
+			"PriceAmount":   variantReq.Price.Amount,
+			"PriceCurrency": variantReq.Price.Currency,
+			"Options":       &variantReq.Options,
+			"ProductRel": &domain.Product{
+				Id: product_entity.Id,
+			},
+		})
+		if err = variant_changeset.ValidValues(); err != nil {
+			tx.Rollback()
+			base_response.TransformToBadRequest(fmt.Sprintf("variant [%v]: %v", variantReq.Sku, err))
+			return base_response
+		}
+		variant_entities = append(variant_entities, variant_entity)
+		variant_changesets = append(variant_changesets, variant_changeset)
+	}
+	// variants are inserted with one statement, their ids are set back in order
+	if err = p.repo.SaveAllTx(ctx, variant_changesets, tx); err != nil {
+		tx.Rollback()
+		if repo.GetErrCode(err) == repo.ErrCodeDuplicate {
+			base_response.StatusCode = http.StatusConflict
+		}
+		base_response.TransformToError(err)
+		return base_response
+	}
+	// a stock level is created by the first receive, none is needed without stock
+	variantIds := make([]uint32, 0, len(variant_entities))
+	for index, variant_entity := range variant_entities {
+		variantIds = append(variantIds, variant_entity.Id)
+		if req.Variants[index].Stock == 0 {
+			continue
+		}
+		if _, err = InventoryServiceManager.Receive(ctx, tx, product_entity.Id, variant_entity.Id, req.Variants[index].Stock); err != nil {
 			tx.Rollback()
+			base_response.TransformToError(err)
 			return base_response
 		}
-		if _, existValueId := product_type_entity_before.AggregateFields.Fields[attributeIdCreated][optionValueIdCreated]; !existValueId {
-			base_response.TransformToNotFoundEntity("ProductType Not Found OptionValue Id")
+	}
+	if len(req.Variants) == 0 && req.Stock > 0 {
+		if _, err = InventoryServiceManager.Receive(ctx, tx, product_entity.Id, 0, req.Stock); err != nil {
 			tx.Rollback()
+			base_response.TransformToError(err)
 			return base_response
 		}
-		product_type_entity_cloned_update.AggregateFields.Fields[attributeIdCreated][optionValueIdCreated] += 1
 	}
 
-	fmt.Println("prepare update from product type service")
-	err = ProductTypeServiceManager.UpdateAggregateFields(ctx, product_type_entity_cloned_update, tx)
+	product_type_entity_updated, err := ProductTypeServiceManager.UpdateAggregateFields(ctx, req.ProductTypeId, aggregate_increments, tx)
 	if err != nil {
 		tx.Rollback()
-		base_response.ErrCodeString = err.Error()
+		base_response.TransformToError(err)
 		return base_response
 	}
 
 	err = tx.Commit()
-	ProductTypeServiceManager.UpdateCacheProductTypeById(product_type_entity_cloned_update.Id, product_type_entity_cloned_update)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	ProductTypeServiceManager.UpdateCacheProductTypeById(product_type_entity_updated.Id, product_type_entity_updated)
+	if SearchServiceManager != nil {
+		SearchServiceManager.IndexProduct(ctx, product_entity.Id)
+	}
+	// saved searches are matched in background, only committed products are visible to the matcher
+	if NotificationServiceManager != nil {
+		NotificationServiceManager.EnqueueNewProduct(product_entity.Id)
+	}
 	base_response.TransformToStatusOk(&product.ProductCreateRes{
-		Id: product_entity.Id,
+		Id:         product_entity.Id,
+		VariantIds: variantIds,
+	})
+	return base_response
+}
+
+// listingPriceOfProduct gives the price shown in listings: the request price for a simple
+// product, the lowest variant price when the product has variants
+func listingPriceOfProduct(req *product.ProductCreateReq) (valueobject.Money, error) {
+	if len(req.Variants) == 0 {
+		if req.Price == nil {
+			return valueobject.Money{}, errors.New("price is required for product without variants")
+		}
+		return *req.Price, nil
+	}
+	listingPrice := req.Variants[0].Price
+	for _, variantReq := range req.Variants {
+		if variantReq.Price.Currency != listingPrice.Currency {
+			return valueobject.Money{}, fmt.Errorf("%w: all variants must use one currency", valueobject.ErrCurrencyMismatch)
+		}
+		if variantReq.Price.Amount < listingPrice.Amount {
+			listingPrice = variantReq.Price
+		}
+	}
+	if req.Price != nil && req.Price.Currency != listingPrice.Currency {
+		return valueobject.Money{}, fmt.Errorf("%w: product and variants must use one currency", valueobject.ErrCurrencyMismatch)
+	}
+	return listingPrice, nil
+}
+
+// preloadVariants joins the variants of a product, used by read apis
+func preloadVariants() (to interface{}, fk string, pk string, inverse bool, type_join repo.TYPEJOIN) {
+	return &domain.ProductVariant{}, "ProductId", "Id", false, repo.LEFTJOIN
+}
+
+// findProductById loads a product with its variants and available stock, nil when not found
+func (p *ProductService) findProductById(ctx context.Context, id uint32) (*domain.Product, error) {
+	builder := p.repo.GetById(&domain.Product{}, preloadVariants)
+	product_table := "products"
+	variant_table := "productvariants"
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Name", product_table)).
+		Select(repo.Col("SellerId", product_table)).
+		Select(repo.Col("PriceAmount", product_table)).
+		Select(repo.Col("PriceCurrency", product_table)).
+		Select(repo.Col("Fields", product_table)).
+		Select(repo.Col("Rating", product_table)).
+		Select(repo.Col("ProductTypeId", product_table).As("ProductTypeRel$Id")).
+		Select(repo.Col("Id", variant_table, repo.IFNULLINT).As("ProductVariantRel$Id")).
+		Select(repo.Col("Sku", variant_table, repo.IFNULLSTR).As("ProductVariantRel$Sku")).
+		Select(repo.Col("PriceAmount", variant_table, repo.IFNULLINT).As("ProductVariantRel$PriceAmount")).
+		Select(repo.Col("PriceCurrency", variant_table, repo.IFNULLSTR).As("ProductVariantRel$PriceCurrency")).
+		Select(repo.Col("Options", variant_table).As("ProductVariantRel$Options")).
+		Where(repo.P("Id", product_table, repo.Equal, id)).
+		OrderBy(repo.Col("Id", variant_table), repo.ASC)
+
+	query, args := builder.Query()
+	entities, err := p.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil || len(entities) == 0 {
+		return nil, err
+	}
+	product_entity := entities[0].(*domain.Product)
+	levels, err := InventoryServiceManager.GetStockLevels(ctx, product_entity.Id)
+	if err != nil {
+		return nil, err
+	}
+	fillStockOfProduct(product_entity, levels)
+	return product_entity, nil
+}
+
+func (p *ProductService) GetProductById(ctx context.Context, id uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	product_entity, err := p.findProductById(ctx, id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_entity == nil {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+	base_response.TransformToStatusOk(&product.ProductGetRes{
+		Product: product_entity,
+	})
+	return base_response
+}
+
+func fillStockOfProduct(product_entity *domain.Product, levels map[uint32]*domain.StockLevel) {
+	if len(product_entity.ProductVariantRel) == 0 {
+		if level, ok := levels[0]; ok {
+			product_entity.Stock = level.Available()
+		}
+		return
+	}
+	product_entity.Stock = 0
+	for _, variant := range product_entity.ProductVariantRel {
+		if level, ok := levels[variant.Id]; ok {
+			variant.Stock = level.Available()
+			product_entity.Stock += variant.Stock
+		}
+	}
+}
+
+func (p *ProductService) ListProducts(ctx context.Context, req *product.ProductListReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	// amounts of different currencies can not be compared
+	if (req.MinPrice != nil || req.MaxPrice != nil) && !valueobject.IsSupportedCurrency(req.Currency) {
+		base_response.TransformToBadRequest("currency is required to filter by price")
+		return base_response
+	}
+	product_table := "products"
+	builder := p.repo.GetById(&domain.Product{})
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Name", product_table)).
+		Select(repo.Col("SellerId", product_table)).
+		Select(repo.Col("PriceAmount", product_table)).
+		Select(repo.Col("PriceCurrency", product_table)).
+		Select(repo.Col("Fields", product_table)).
+		Select(repo.Col("Rating", product_table)).
+		Select(repo.Col("ProductTypeId", product_table).As("ProductTypeRel$Id"))
+	if req.ProductTypeId > 0 {
+		builder.Where(repo.P("ProductTypeId", product_table, repo.Equal, req.ProductTypeId))
+	}
+	if req.Currency != "" {
+		builder.Where(repo.P("PriceCurrency", product_table, repo.Equal, req.Currency))
+	}
+	if req.MinPrice != nil {
+		builder.Where(repo.P("PriceAmount", product_table, repo.GreaterEqual, *req.MinPrice))
+	}
+	if req.MaxPrice != nil {
+		builder.Where(repo.P("PriceAmount", product_table, repo.LessEqual, *req.MaxPrice))
+	}
+	// Id breaks ties of equal prices so the order, and so the pages, are stable
+	switch req.Sort {
+	case "price_asc":
+		builder.
+			OrderBy(repo.Col("PriceAmount", product_table), repo.ASC).
+			OrderBy(repo.Col("Id", product_table), repo.ASC)
+	case "price_desc":
+		builder.
+			OrderBy(repo.Col("PriceAmount", product_table), repo.DESC).
+			OrderBy(repo.Col("Id", product_table), repo.DESC)
+	case "newest", "":
+		builder.OrderBy(repo.Col("Id", product_table), repo.DESC)
+	default:
+		base_response.TransformToBadRequest(fmt.Sprintf("unknown sort [%v]", req.Sort))
+		return base_response
+	}
+	builder.Limit(product.MaxProductsListed)
+	if _, err := builder.SeekAfter(req.Cursor); err != nil {
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+
+	query, args := builder.Query()
+	entities, err := p.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	products := make([]*domain.Product, 0, len(entities))
+	for _, entity := range entities {
+		products = append(products, entity.(*domain.Product))
+	}
+	next_cursor := ""
+	if len(products) == product.MaxProductsListed {
+		next_cursor, _ = builder.CursorOf(products[len(products)-1])
+	}
+	base_response.TransformToStatusOk(&product.ProductListRes{
+		Products:   products,
+		NextCursor: next_cursor,
+	})
+	return base_response
+}
+
+// findProductsByIds loads the listing columns of many products, keyed by id
+func (p *ProductService) findProductsByIds(ctx context.Context, ids []uint32) (map[uint32]*domain.Product, error) {
+	products := map[uint32]*domain.Product{}
+	if len(ids) == 0 {
+		return products, nil
+	}
+	product_table := "products"
+	builder := p.repo.GetById(&domain.Product{})
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Name", product_table)).
+		Select(repo.Col("SellerId", product_table)).
+		Select(repo.Col("PriceAmount", product_table)).
+		Select(repo.Col("PriceCurrency", product_table)).
+		Select(repo.Col("Fields", product_table)).
+		Select(repo.Col("Rating", product_table)).
+		Select(repo.Col("ProductTypeId", product_table).As("ProductTypeRel$Id")).
+		Where(repo.P("Id", product_table, repo.In, ids))
+	query, args := builder.Query()
+	entities, err := p.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		product_entity := entity.(*domain.Product)
+		products[product_entity.Id] = product_entity
+	}
+	return products, nil
+}
+
+// lockProduct reads the columns an owner can change with FOR UPDATE
+func (p *ProductService) lockProduct(ctx context.Context, tx *sql.Tx, id uint32) (*domain.Product, error) {
+	product_table := "products"
+	builder := p.repo.GetById(&domain.Product{})
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Name", product_table)).
+		Select(repo.Col("SellerId", product_table)).
+		Select(repo.Col("Fields", product_table)).
+		Select(repo.Col("ProductTypeId", product_table).As("ProductTypeRel$Id")).
+		Where(repo.P("Id", product_table, repo.Equal, id)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := p.repo.RawQueryTx(ctx, tx, query, args, &domain.Product{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFoundProduct
+	}
+	return entities[0].(*domain.Product), nil
+}
+
+// lockProductOfSeller locks a product only its seller may change, it fills base_response
+// and returns nil when the product is missing or belongs to someone else
+func (p *ProductService) lockProductOfSeller(ctx context.Context, tx *sql.Tx, id uint32, userId uint32, base_response *dto.BaseMessageResponse) *domain.Product {
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = ErrLoginRequired.Error()
+		return nil
+	}
+	product_entity, err := p.lockProduct(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, ErrNotFoundProduct) {
+			base_response.TransformToNotFoundEntity("Product")
+			return nil
+		}
+		base_response.TransformToError(err)
+		return nil
+	}
+	if product_entity.SellerId != userId {
+		base_response.StatusCode = http.StatusForbidden
+		base_response.ErrCodeString = "only the seller can change the product"
+		return nil
+	}
+	return product_entity
+}
+
+// countOptions adds delta to the aggregate counter of every option the product and its variants pick
+func countOptions(aggregate *valueobject.AggregateFieldJSON, fields *valueobject.FieldsJSON, variants []*domain.ProductVariant, delta int) {
+	if fields != nil {
+		for attributeId, optionValueIds := range *fields {
+			for _, optionValueId := range optionValueIds {
+				aggregate.Increment(attributeId, optionValueId, delta)
+			}
+		}
+	}
+	for _, variant := range variants {
+		if variant.Options == nil {
+			continue
+		}
+		for attributeId, optionValueId := range *variant.Options {
+			aggregate.Increment(attributeId, optionValueId, delta)
+		}
+	}
+}
+
+// UpdateProduct changes name and fields of a product of the seller, option counters of the
+// product type move from the old fields to the new ones in the same transaction.
+// The search document of the product is rebuilt after commit
+func (p *ProductService) UpdateProduct(ctx context.Context, id uint32, userId uint32, req *product.ProductUpdateReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if req.Name != nil && *req.Name == "" {
+		base_response.TransformToBadRequest("name can not be empty")
+		return base_response
+	}
+	if req.Name == nil && req.Fields == nil {
+		base_response.TransformToBadRequest("nothing to update")
+		return base_response
+	}
+	product_before, err := p.findProductById(ctx, id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_before == nil {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+	variantOptions := make([]valueobject.VariantOptionsJSON, 0, len(product_before.ProductVariantRel))
+	for _, variant := range product_before.ProductVariantRel {
+		if variant.Options != nil {
+			variantOptions = append(variantOptions, *variant.Options)
+		}
+	}
+
+	tx := p.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
+	product_entity := p.lockProductOfSeller(ctx, tx, id, userId, base_response)
+	if product_entity == nil {
+		tx.Rollback()
+		return base_response
+	}
+
+	values := map[string]any{}
+	if req.Name != nil {
+		values["Name"] = *req.Name
+	}
+	var aggregate_increments *valueobject.AggregateFieldJSON
+	if req.Fields != nil {
+		product_type_entity_before := ProductTypeServiceManager.getProductTypeEntityExistById(product_entity.ProductTypeRel.Id)
+		if product_type_entity_before == nil {
+			tx.Rollback()
+			base_response.TransformToNotFoundEntity("ProductType")
+			return base_response
+		}
+		if err = product_type_entity_before.ValidateFields(*req.Fields, variantOptions...); err != nil {
+			tx.Rollback()
+			if errors.Is(err, domain.ErrNotFoundAttributeId) || errors.Is(err, domain.ErrNotFoundOptionValueId) {
+				base_response.TransformToNotFoundEntity(err.Error())
+				return base_response
+			}
+			base_response.TransformToBadRequest(err.Error())
+			return base_response
+		}
+		aggregate_increments = &valueobject.AggregateFieldJSON{}
+		countOptions(aggregate_increments, product_entity.Fields, nil, -1)
+		countOptions(aggregate_increments, req.Fields, nil, 1)
+		values["Fields"] = req.Fields
+	}
+
+	product_changeset := changeset.CastValues(&domain.Product{Id: id}, values)
+	if err = product_changeset.ValidValues(); err != nil {
+		tx.Rollback()
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	if err = p.repo.UpdateTxById(ctx, product_changeset, tx); err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	var product_type_entity_updated *domain.ProductType
+	if aggregate_increments != nil {
+		product_type_entity_updated, err = ProductTypeServiceManager.UpdateAggregateFields(ctx, product_entity.ProductTypeRel.Id, aggregate_increments, tx)
+		if err != nil {
+			tx.Rollback()
+			base_response.TransformToError(err)
+			return base_response
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_type_entity_updated != nil {
+		ProductTypeServiceManager.UpdateCacheProductTypeById(product_type_entity_updated.Id, product_type_entity_updated)
+	}
+	if SearchServiceManager != nil {
+		SearchServiceManager.IndexProduct(ctx, id)
+	}
+	product_entity, err = p.findProductById(ctx, id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&product.ProductGetRes{
+		Product: product_entity,
+	})
+	return base_response
+}
+
+// DeleteProduct soft deletes a product of the seller with its variants and takes it out of
+// carts. Orders keep pointing to the deleted product and its stock ledger is kept.
+// IndexProduct after commit finds the product gone and removes its search document
+func (p *ProductService) DeleteProduct(ctx context.Context, id uint32, userId uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	product_before, err := p.findProductById(ctx, id)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_before == nil {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+
+	tx := p.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
+	product_entity := p.lockProductOfSeller(ctx, tx, id, userId, base_response)
+	if product_entity == nil {
+		tx.Rollback()
+		return base_response
+	}
+	if _, err = p.repo.DeleteTxWhere(ctx, &domain.CartItem{}, repo.P("ProductId", cart_item_table, repo.Equal, id), tx); err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if _, err = p.repo.DeleteTxWhere(ctx, &domain.ProductVariant{}, repo.P("ProductId", "productvariants", repo.Equal, id), tx); err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if err = p.repo.DeleteTxById(ctx, changeset.CastValues(&domain.Product{Id: id}, map[string]any{}), tx); err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	aggregate_increments := &valueobject.AggregateFieldJSON{}
+	countOptions(aggregate_increments, product_entity.Fields, product_before.ProductVariantRel, -1)
+	product_type_entity_updated, err := ProductTypeServiceManager.UpdateAggregateFields(ctx, product_entity.ProductTypeRel.Id, aggregate_increments, tx)
+	if err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if err = tx.Commit(); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	ProductTypeServiceManager.UpdateCacheProductTypeById(product_type_entity_updated.Id, product_type_entity_updated)
+	if SearchServiceManager != nil {
+		SearchServiceManager.IndexProduct(ctx, id)
+	}
+	base_response.TransformToStatusOk(&product.ProductDeleteRes{
+		Id: id,
 	})
 	return base_response
 }
@@ -11,12 +11,20 @@ import (
 	"ebayclone/log_util"
 	"ebayclone/repo"
 	"ebayclone/valueobject"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
+var ErrNotFoundProductType = errors.New("product type not found")
+
 type ProductTypeService struct {
-	repo                *repo.Repo
+	repo *repo.Repo
+	// cached entities are never changed in place, an update replaces them under cacheMu
+	cacheMu             sync.RWMutex
 	cacheAllProductType map[uint32]*domain.ProductType
 	debug               bool
 	serviceName         string
@@ -30,36 +38,10 @@ func (s *ProductTypeService) CreateProductType(ctx context.Context, req *product
 		ReponseObject: nil,
 	}
 
-	attributeObjectRes := &valueobject.AttributesObjectRes{
-		Attributes: make([]*valueobject.OneAttributeObjectRes, 0),
-	}
-	var globalAttributeId uint32 = 0
-	var aggregateFieldsJSON = map[valueobject.AttributeId]map[valueobject.OptionValueId]int{}
-	for attributeNameReq, optionValues := range req.Attributes {
-		oneAttributeObjectRes := &valueobject.OneAttributeObjectRes{
-			Id:           valueobject.AttributeId(globalAttributeId + 1),
-			Name:         attributeNameReq,
-			OptionValues: make([]*valueobject.OptionValueRes, 0),
-		}
-
-		if _, existMapOptionValueJsonFieldAggregate := aggregateFieldsJSON[oneAttributeObjectRes.Id]; !existMapOptionValueJsonFieldAggregate {
-			aggregateFieldsJSON[oneAttributeObjectRes.Id] = make(map[valueobject.OptionValueId]int)
-		}
-
-		var globalOptionValueId uint32 = 0
-		for _, optionValueReq := range optionValues {
-			oneOptionValueRes := &valueobject.OptionValueRes{
-				Id:    valueobject.OptionValueId(globalOptionValueId + 1),
-				Value: optionValueReq,
-			}
-			oneAttributeObjectRes.OptionValues = append(
-				oneAttributeObjectRes.OptionValues, oneOptionValueRes)
-			globalOptionValueId++
-			aggregateFieldsJSON[oneAttributeObjectRes.Id][oneOptionValueRes.Id] = 0
-		}
-		attributeObjectRes.Attributes = append(
-			attributeObjectRes.Attributes, oneAttributeObjectRes)
-		globalAttributeId++
+	attributeObjectRes, aggregateFieldsJSON, err := buildAttributesOfProductType(req.Attributes)
+	if err != nil {
+		base_message_response.TransformToBadRequest(err.Error())
+		return base_message_response
 	}
 	product_type_changeset := changeset.CastValues(product_type_entity, map[string]any{
 		"Name":       req.Name,
@@ -69,7 +51,7 @@ func (s *ProductTypeService) CreateProductType(ctx context.Context, req *product
 		},
 	})
 
-	err := s.repo.Save(ctx, product_type_changeset)
+	err = s.repo.Save(ctx, product_type_changeset)
 	if err != nil {
 		base_message_response.ErrCodeString = err.Error()
 		return base_message_response
@@ -81,29 +63,106 @@ func (s *ProductTypeService) CreateProductType(ctx context.Context, req *product
 	base_message_response.TransformToStatusOk(&product_type_dto.ProductTypeCreateRes{
 		Id:         product_type_entity.Id,
 		Attributes: product_type_entity.Attributes,
+		Mapping:    attributeMappingOf(attributeObjectRes),
 	})
 
 	return base_message_response
 }
 
+// buildAttributesOfProductType assigns ids by position in the request,
+// so the same request always gives the same attribute and option ids.
+func buildAttributesOfProductType(attributesReq []*product_type_dto.AttributeCreateReq) (*valueobject.AttributesObjectRes, map[valueobject.AttributeId]map[valueobject.OptionValueId]int, error) {
+	attributeObjectRes := &valueobject.AttributesObjectRes{
+		Attributes: make([]*valueobject.OneAttributeObjectRes, 0, len(attributesReq)),
+	}
+	aggregateFieldsJSON := map[valueobject.AttributeId]map[valueobject.OptionValueId]int{}
+	seenAttributeNames := map[string]bool{}
+	for index, attributeReq := range attributesReq {
+		if attributeReq == nil || strings.TrimSpace(attributeReq.Name) == "" {
+			return nil, nil, fmt.Errorf("attribute at position %v has empty name", index)
+		}
+		attributeName := strings.TrimSpace(attributeReq.Name)
+		if seenAttributeNames[strings.ToLower(attributeName)] {
+			return nil, nil, fmt.Errorf("duplicate attribute name [%v]", attributeName)
+		}
+		seenAttributeNames[strings.ToLower(attributeName)] = true
+
+		oneAttributeObjectRes := &valueobject.OneAttributeObjectRes{
+			Id:           valueobject.AttributeId(index + 1),
+			Name:         attributeName,
+			DisplayOrder: index,
+			Required:     attributeReq.Required,
+			MultiSelect:  attributeReq.MultiSelect,
+			OptionValues: make([]*valueobject.OptionValueRes, 0, len(attributeReq.OptionValues)),
+		}
+		aggregateFieldsJSON[oneAttributeObjectRes.Id] = make(map[valueobject.OptionValueId]int)
+
+		seenOptionValues := map[string]bool{}
+		for optionIndex, optionValueReq := range attributeReq.OptionValues {
+			// option values come from json so compare them by their printed form
+			optionValueKey := fmt.Sprintf("%v", optionValueReq)
+			if seenOptionValues[optionValueKey] {
+				return nil, nil, fmt.Errorf("duplicate option value [%v] of attribute [%v]", optionValueKey, attributeName)
+			}
+			seenOptionValues[optionValueKey] = true
+
+			oneOptionValueRes := &valueobject.OptionValueRes{
+				Id:    valueobject.OptionValueId(optionIndex + 1),
+				Value: optionValueReq,
+			}
+			oneAttributeObjectRes.OptionValues = append(
+				oneAttributeObjectRes.OptionValues, oneOptionValueRes)
+			aggregateFieldsJSON[oneAttributeObjectRes.Id][oneOptionValueRes.Id] = 0
+		}
+		attributeObjectRes.Attributes = append(
+			attributeObjectRes.Attributes, oneAttributeObjectRes)
+	}
+	return attributeObjectRes, aggregateFieldsJSON, nil
+}
+
+// attributeMappingOf gives every attribute id by name with the ids of its option values,
+// keyed by the printed value like the duplicate check of buildAttributesOfProductType
+func attributeMappingOf(attributeObjectRes *valueobject.AttributesObjectRes) map[string]*product_type_dto.AttributeMappingRes {
+	mapping := make(map[string]*product_type_dto.AttributeMappingRes, len(attributeObjectRes.Attributes))
+	for _, oneAttribute := range attributeObjectRes.Attributes {
+		attributeMapping := &product_type_dto.AttributeMappingRes{
+			Id:             oneAttribute.Id,
+			OptionValueIds: make(map[string]valueobject.OptionValueId, len(oneAttribute.OptionValues)),
+		}
+		for _, oneOptionValue := range oneAttribute.OptionValues {
+			attributeMapping.OptionValueIds[fmt.Sprintf("%v", oneOptionValue.Value)] = oneOptionValue.Id
+		}
+		mapping[oneAttribute.Name] = attributeMapping
+	}
+	return mapping
+}
+
 var ProductTypeServiceManager *ProductTypeService
 
+// productTypesLoadTimeout bounds loading every product type at startup, the table is read
+// in one query which may outlast the default query timeout of the repo
+const productTypesLoadTimeout = 30 * time.Second
+
 func NewProductTypeService(debug bool) *ProductTypeService {
 	if ProductTypeServiceManager == nil {
 		ProductTypeServiceManager = &ProductTypeService{
-			repo:                repo.NewRepo(infrastructure.MysqlConfig, debug),
+			repo:                repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
 			cacheAllProductType: make(map[uint32]*domain.ProductType),
 			debug:               debug,
 			serviceName:         "ProductTypeService",
 		}
 
 		// load all product type here
-		ProductTypeServiceManager.FetchAllProductTypesInMemoryFromDatabase()
+		ctx, cancel := context.WithTimeout(context.Background(), productTypesLoadTimeout)
+		defer cancel()
+		if err := ProductTypeServiceManager.FetchAllProductTypesInMemoryFromDatabase(ctx); err != nil {
+			log_util.PrintFlag(ProductTypeServiceManager.serviceName, true, fmt.Sprintf("load product types: %v", err))
+		}
 	}
 	return ProductTypeServiceManager
 }
 
-func (p *ProductTypeService) FetchAllProductTypesInMemoryFromDatabase() {
+func (p *ProductTypeService) FetchAllProductTypesInMemoryFromDatabase(ctx context.Context) error {
 	// create builder query
 	builder := p.repo.GetById(&domain.ProductType{})
 	table_name := "producttypes"
@@ -111,10 +170,19 @@ func (p *ProductTypeService) FetchAllProductTypesInMemoryFromDatabase() {
 		Select(repo.Col("Id", table_name)).
 		Select(repo.Col("Name", table_name)).
 		Select(repo.Col("Attributes", table_name)).
-		Select(repo.Col("AggregateFields", table_name))
+		Select(repo.Col("AggregateFields", table_name)).
+		Select(repo.Col("CreatedAt", table_name)).
+		Select(repo.Col("UpdatedAt", table_name)).
+		Select(repo.Col("CreatedBy", table_name))
 
 	query, args := builder.Query()
-	entities, _ := p.repo.RawQuery(query, args, &domain.ProductType{})
+	// only the deadline of ctx bounds the load
+	entities, err := p.repo.WithTimeouts(repo.Timeouts{}).RawQuery(ctx, query, args, &domain.ProductType{})
+	if err != nil {
+		return err
+	}
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
 	if len(entities) > 0 {
 		for _, entity := range entities {
 			productEntity := entity.(*domain.ProductType)
@@ -124,19 +192,24 @@ func (p *ProductTypeService) FetchAllProductTypesInMemoryFromDatabase() {
 			}
 			for _, oneAttribute := range productEntity.Attributes.Attributes {
 				log_util.PrintFlag(p.serviceName, p.debug, fmt.Sprintf("type_name [%v], attribute_name [%v]",
-					productEntity.Name, oneAttribute.Name, productEntity.AggregateFields))
+					productEntity.Name, oneAttribute.Name))
 			}
 		}
 	}
+	return nil
 }
 
 func (p *ProductTypeService) addProductTypeEntityIntoCache(entity *domain.ProductType) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
 	if _, exist := p.cacheAllProductType[entity.Id]; !exist {
 		p.cacheAllProductType[entity.Id] = entity
 	}
 }
 
 func (p *ProductTypeService) getProductTypeEntityExistById(productTypeId uint32) *domain.ProductType {
+	p.cacheMu.RLock()
+	defer p.cacheMu.RUnlock()
 	fmt.Println("global cache: ", p.cacheAllProductType)
 	return p.cacheAllProductType[productTypeId]
 }
@@ -147,6 +220,8 @@ func (s *ProductTypeService) GetAllProductType(ctx context.Context) *dto2.BaseMe
 		ErrCodeString: "",
 		ReponseObject: nil,
 	}
+	s.cacheMu.RLock()
+	defer s.cacheMu.RUnlock()
 	if len(s.cacheAllProductType) == 0 {
 		base_message.TransformToNotFoundEntity("ProductType")
 		return base_message
@@ -162,25 +237,118 @@ func (s *ProductTypeService) GetAllProductType(ctx context.Context) *dto2.BaseMe
 	return base_message
 }
 
-func (p *ProductTypeService) UpdateAggregateFields(ctx context.Context, entity *domain.ProductType, tx *sql.Tx) error {
-	product_entity := &domain.ProductType{Id: entity.Id} // must add id here , to it get reflection id where id update
-	product_update_changeset := changeset.CastValues(product_entity, map[string]any{
-
-		"AggregateFields": entity.AggregateFields,
+// GetProductTypeProducts loads one page of the products of a type, oldest first, and
+// preloads their variants with one query so a product is not repeated per variant
+func (s *ProductTypeService) GetProductTypeProducts(ctx context.Context, id uint32, req *product_type_dto.ProductTypeProductsReq) *dto2.BaseMessageResponse {
+	base_message := &dto2.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	cached := s.getProductTypeEntityExistById(id)
+	if cached == nil {
+		base_message.TransformToNotFoundEntity("ProductType")
+		return base_message
+	}
+	product_table := "products"
+	builder := s.repo.GetById(&domain.Product{})
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Name", product_table)).
+		Select(repo.Col("SellerId", product_table)).
+		Select(repo.Col("PriceAmount", product_table)).
+		Select(repo.Col("PriceCurrency", product_table)).
+		Select(repo.Col("Fields", product_table)).
+		Select(repo.Col("Rating", product_table)).
+		Select(repo.Col("ProductTypeId", product_table).As("ProductTypeRel$Id")).
+		Where(repo.P("ProductTypeId", product_table, repo.Equal, id)).
+		OrderBy(repo.Col("Id", product_table), repo.ASC).
+		Limit(product_type_dto.MaxProductTypeProductsListed)
+	if _, err := builder.SeekAfter(req.Cursor); err != nil {
+		base_message.TransformToBadRequest(err.Error())
+		return base_message
+	}
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil {
+		base_message.TransformToError(err)
+		return base_message
+	}
+	if err = s.repo.Preload(ctx, entities, "ProductVariantRel"); err != nil {
+		base_message.TransformToError(err)
+		return base_message
+	}
+	// the cached entity is shared, the page is set on a copy
+	product_type_entity := cached.CloneProductType()
+	product_type_entity.ProductRel = make([]*domain.Product, 0, len(entities))
+	for _, entity := range entities {
+		product_type_entity.ProductRel = append(product_type_entity.ProductRel, entity.(*domain.Product))
+	}
+	next_cursor := ""
+	if len(entities) == product_type_dto.MaxProductTypeProductsListed {
+		next_cursor, _ = builder.CursorOf(entities[len(entities)-1])
+	}
+	base_message.TransformToStatusOk(&product_type_dto.ProductTypeProductsRes{
+		ProductType: product_type_entity,
+		NextCursor:  next_cursor,
 	})
+	return base_message
+}
 
-	fmt.Println("before update repo save")
-	err := p.repo.UpdateTxById(ctx, product_update_changeset, tx)
+// lockProductType reads a product type row in tx and holds it until the tx ends
+func (p *ProductTypeService) lockProductType(ctx context.Context, tx *sql.Tx, id uint32) (*domain.ProductType, error) {
+	builder := p.repo.GetById(&domain.ProductType{})
+	table_name := "producttypes"
+	builder.
+		Select(repo.Col("Id", table_name)).
+		Select(repo.Col("Name", table_name)).
+		Select(repo.Col("Attributes", table_name)).
+		Select(repo.Col("AggregateFields", table_name)).
+		Select(repo.Col("CreatedAt", table_name)).
+		Select(repo.Col("UpdatedAt", table_name)).
+		Select(repo.Col("CreatedBy", table_name)).
+		Where(repo.P("Id", table_name, repo.Equal, id)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := p.repo.RawQueryTx(ctx, tx, query, args, &domain.ProductType{})
 	if err != nil {
-		fmt.Println("error: ", err)
-		log_util.PrintFlag("ProductService", p.debug, fmt.Sprintf("error: ", err))
-		return err
+		return nil, err
 	}
-	fmt.Println("update success")
-	return nil
+	if len(entities) == 0 {
+		return nil, ErrNotFoundProductType
+	}
+	return entities[0].(*domain.ProductType), nil
+}
+
+// UpdateAggregateFields adds increments to the counters of the product type row locked in tx,
+// so concurrent products of one type do not overwrite each other. The updated entity is
+// returned for UpdateCacheProductTypeById once tx is committed
+func (p *ProductTypeService) UpdateAggregateFields(ctx context.Context, id uint32, increments *valueobject.AggregateFieldJSON, tx *sql.Tx) (*domain.ProductType, error) {
+	product_type_entity, err := p.lockProductType(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if product_type_entity.AggregateFields == nil {
+		product_type_entity.AggregateFields = &valueobject.AggregateFieldJSON{}
+	}
+	for attributeId, optionValues := range increments.Fields {
+		for optionValueId, delta := range optionValues {
+			product_type_entity.AggregateFields.Increment(attributeId, optionValueId, delta)
+		}
+	}
+	product_update_changeset := changeset.CastValues(&domain.ProductType{Id: id}, map[string]any{
+		"AggregateFields": product_type_entity.AggregateFields,
+	})
+	if err = p.repo.UpdateTxById(ctx, product_update_changeset, tx); err != nil {
+		log_util.PrintFlag(p.serviceName, p.debug, fmt.Sprintf("error: %v", err))
+		return nil, err
+	}
+	return product_type_entity, nil
 }
 
 func (p *ProductTypeService) UpdateCacheProductTypeById(id uint32, new_product_type *domain.ProductType) {
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
 	if _, ok := p.cacheAllProductType[id]; ok {
 		p.cacheAllProductType[id] = new_product_type
 	}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"ebayclone/dto/product_type_dto"
+	"ebayclone/valueobject"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildAttributesOfProductType(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes []*product_type_dto.AttributeCreateReq
+		want       []*valueobject.OneAttributeObjectRes
+		wantErr    string
+	}{
+		{name: "no attributes", want: []*valueobject.OneAttributeObjectRes{}},
+		{
+			name: "ids follow request order",
+			attributes: []*product_type_dto.AttributeCreateReq{
+				{Name: " ram ", OptionValues: []any{float64(8), float64(16)}, Required: true},
+				{Name: "color", OptionValues: []any{"red"}, MultiSelect: true},
+			},
+			want: []*valueobject.OneAttributeObjectRes{
+				{Id: 1, Name: "ram", DisplayOrder: 0, Required: true, OptionValues: []*valueobject.OptionValueRes{{Id: 1, Value: float64(8)}, {Id: 2, Value: float64(16)}}},
+				{Id: 2, Name: "color", DisplayOrder: 1, MultiSelect: true, OptionValues: []*valueobject.OptionValueRes{{Id: 1, Value: "red"}}},
+			},
+		},
+		{name: "nil attribute", attributes: []*product_type_dto.AttributeCreateReq{nil}, wantErr: "position 0 has empty name"},
+		{name: "blank name", attributes: []*product_type_dto.AttributeCreateReq{{Name: "ram"}, {Name: "  "}}, wantErr: "position 1 has empty name"},
+		{name: "duplicate name ignores case", attributes: []*product_type_dto.AttributeCreateReq{{Name: "Color"}, {Name: "color "}}, wantErr: "duplicate attribute name [color]"},
+		{name: "duplicate option value", attributes: []*product_type_dto.AttributeCreateReq{{Name: "ram", OptionValues: []any{float64(8), float64(8)}}}, wantErr: "duplicate option value [8] of attribute [ram]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, aggregate, err := buildAttributesOfProductType(tt.attributes)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Attributes, tt.want) {
+				t.Errorf("attributes = %+v, want %+v", got.Attributes, tt.want)
+			}
+			// every option starts counted at 0
+			for _, attribute := range tt.want {
+				if len(aggregate[attribute.Id]) != len(attribute.OptionValues) {
+					t.Errorf("aggregate of %v = %v", attribute.Name, aggregate[attribute.Id])
+				}
+				for _, option := range attribute.OptionValues {
+					if count, ok := aggregate[attribute.Id][option.Id]; !ok || count != 0 {
+						t.Errorf("aggregate of %v option %v = %v, %v", attribute.Name, option.Id, count, ok)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAttributeMappingOf(t *testing.T) {
+	attributes, _, err := buildAttributesOfProductType([]*product_type_dto.AttributeCreateReq{
+		{Name: "ram", OptionValues: []any{float64(8), float64(16)}},
+		{Name: "color", OptionValues: []any{"red", "blue"}},
+		{Name: "note"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*product_type_dto.AttributeMappingRes{
+		"ram":   {Id: 1, OptionValueIds: map[string]valueobject.OptionValueId{"8": 1, "16": 2}},
+		"color": {Id: 2, OptionValueIds: map[string]valueobject.OptionValueId{"red": 1, "blue": 2}},
+		"note":  {Id: 3, OptionValueIds: map[string]valueobject.OptionValueId{}},
+	}
+	if got := attributeMappingOf(attributes); !reflect.DeepEqual(got, want) {
+		t.Errorf("attributeMappingOf() = %+v, want %+v", got, want)
+	}
+}
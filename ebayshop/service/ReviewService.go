@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/review_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/repo"
+	"ebayclone/valueobject"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	review_table        = "reviews"
+	seller_rating_table = "sellerratings"
+)
+
+type ReviewService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var ReviewServiceManager *ReviewService
+
+func NewReviewService(debug bool) *ReviewService {
+	if ReviewServiceManager == nil {
+		ReviewServiceManager = &ReviewService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "ReviewService",
+		}
+	}
+	return ReviewServiceManager
+}
+
+// lockProductRating locks the product row so concurrent reviews do not lose counts
+func (s *ReviewService) lockProductRating(ctx context.Context, tx *sql.Tx, productId uint32) (*domain.Product, error) {
+	product_table := "products"
+	builder := s.repo.GetById(&domain.Product{})
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("SellerId", product_table)).
+		Select(repo.Col("Rating", product_table)).
+		Where(repo.P("Id", product_table, repo.Equal, productId)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.Product{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return entities[0].(*domain.Product), nil
+}
+
+func (s *ReviewService) lockSellerRating(ctx context.Context, tx *sql.Tx, sellerId uint32) (*domain.SellerRating, error) {
+	builder := s.repo.GetById(&domain.SellerRating{})
+	builder.
+		Select(repo.Col("Id", seller_rating_table)).
+		Select(repo.Col("Rating", seller_rating_table)).
+		Where(repo.P("Id", seller_rating_table, repo.Equal, sellerId)).
+		ForUpdate()
+	query, args := builder.Query()
+	entities, err := s.repo.RawQueryTx(ctx, tx, query, args, &domain.SellerRating{})
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return entities[0].(*domain.SellerRating), nil
+}
+
+// addSellerRatingTx counts one rating for the seller, the row is created on the first review.
+// Two first reviews of one seller race on the primary key: the insert of the loser waits for
+// the winner to commit and fails as a duplicate, the loser then locks the row it created
+func (s *ReviewService) addSellerRatingTx(ctx context.Context, tx *sql.Tx, sellerId uint32, rating uint8) error {
+	seller_rating_entity, err := s.lockSellerRating(ctx, tx, sellerId)
+	if err != nil {
+		return err
+	}
+	if seller_rating_entity == nil {
+		aggregate := &valueobject.RatingAggregateJSON{}
+		aggregate.Add(rating)
+		err = s.repo.SaveTx(ctx, changeset.CastValues(&domain.SellerRating{}, map[string]any{
+			"Id":     sellerId,
+			"Rating": aggregate,
+		}), tx)
+		if err == nil || repo.GetErrCode(err) != repo.ErrCodeDuplicate {
+			return err
+		}
+		// a locking read sees the committed row even under REPEATABLE READ
+		if seller_rating_entity, err = s.lockSellerRating(ctx, tx, sellerId); err != nil {
+			return err
+		}
+		if seller_rating_entity == nil {
+			return fmt.Errorf("seller rating [%v] missing after a duplicate insert", sellerId)
+		}
+	}
+	aggregate := seller_rating_entity.Rating.Clone()
+	aggregate.Add(rating)
+	return s.repo.UpdateTxById(ctx, changeset.CastValues(&domain.SellerRating{Id: sellerId}, map[string]any{
+		"Rating": aggregate,
+	}), tx)
+}
+
+// CreateReview saves the review of the buyer and updates the rating aggregates of the
+// product and its seller in the same transaction
+func (s *ReviewService) CreateReview(ctx context.Context, orderId uint32, userId uint32, req *review_dto.ReviewCreateReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if req.Rating < valueobject.MinRating || req.Rating > valueobject.MaxRating {
+		base_response.TransformToBadRequest(fmt.Sprintf("rating must be between %v and %v", valueobject.MinRating, valueobject.MaxRating))
+		return base_response
+	}
+	if len(req.Comment) > domain.MaxReviewCommentSize {
+		base_response.TransformToBadRequest(fmt.Sprintf("comment longer than %v", domain.MaxReviewCommentSize))
+		return base_response
+	}
+
+	tx := s.repo.OpenTx(ctx)
+	if tx == nil {
+		base_response.ErrCodeString = "can not open transaction"
+		return base_response
+	}
+	order_entity, err := OrderServiceManager.lockOrder(ctx, tx, orderId)
+	if err != nil && !errors.Is(err, ErrNotFoundOrder) {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	// only the buyer can review, other users get not found like GetOrderById
+	if order_entity == nil || order_entity.UserId != userId {
+		tx.Rollback()
+		base_response.TransformToNotFoundEntity("Order")
+		return base_response
+	}
+	if order_entity.Status != string(domain.OrderCompleted) {
+		tx.Rollback()
+		base_response.StatusCode = http.StatusConflict
+		base_response.ErrCodeString = fmt.Sprintf("order is %v, only completed orders can be reviewed", order_entity.Status)
+		return base_response
+	}
+	lines, err := OrderServiceManager.findOrderLines(ctx, tx, orderId)
+	if err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	boughtProduct := false
+	for _, line := range lines {
+		if line.ProductRel != nil && line.ProductRel.Id == req.ProductId {
+			boughtProduct = true
+			break
+		}
+	}
+	if !boughtProduct {
+		tx.Rollback()
+		base_response.TransformToNotFoundEntity("Product of Order")
+		return base_response
+	}
+	product_entity, err := s.lockProductRating(ctx, tx, req.ProductId)
+	if err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_entity == nil {
+		tx.Rollback()
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+
+	review_entity := &domain.Review{}
+	review_changeset := changeset.CastValues(review_entity, map[string]any{
+		"UserId":     userId,
+		"SellerId":   product_entity.SellerId,
+		"Rating":     req.Rating,
+		"Comment":    req.Comment,
+		"CreatedAt":  time.Now().UTC(),
+		"OrderRel":   &domain.Order{Id: orderId},
+		"ProductRel": &domain.Product{Id: req.ProductId},
+	})
+	if err = review_changeset.ValidValues(); err != nil {
+		tx.Rollback()
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	// reviews are unique on (OrderId, ProductId)
+	if err = s.repo.SaveTx(ctx, review_changeset, tx); err != nil {
+		tx.Rollback()
+		if repo.GetErrCode(err) == repo.ErrCodeDuplicate {
+			base_response.StatusCode = http.StatusConflict
+			base_response.ErrCodeString = "product of this order is already reviewed"
+			return base_response
+		}
+		base_response.TransformToError(err)
+		return base_response
+	}
+
+	product_rating := product_entity.Rating.Clone()
+	product_rating.Add(req.Rating)
+	err = s.repo.UpdateTxById(ctx, changeset.CastValues(&domain.Product{Id: product_entity.Id}, map[string]any{
+		"Rating": product_rating,
+	}), tx)
+	if err != nil {
+		tx.Rollback()
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_entity.SellerId != 0 {
+		if err = s.addSellerRatingTx(ctx, tx, product_entity.SellerId, req.Rating); err != nil {
+			tx.Rollback()
+			base_response.TransformToError(err)
+			return base_response
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&review_dto.ReviewCreateRes{
+		Id: review_entity.Id,
+	})
+	return base_response
+}
+
+// ListProductReviews pages the reviews of a product newest first, the total comes
+// from the rating aggregate of the product
+func (s *ReviewService) ListProductReviews(ctx context.Context, productId uint32, req *review_dto.ReviewListReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	page, pageSize := req.Page, req.PageSize
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = review_dto.DefaultReviewPageSize
+	}
+	if pageSize > review_dto.MaxReviewPageSize {
+		base_response.TransformToBadRequest(fmt.Sprintf("page_size bigger than %v", review_dto.MaxReviewPageSize))
+		return base_response
+	}
+
+	product_table := "products"
+	product_builder := s.repo.GetById(&domain.Product{})
+	product_builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Rating", product_table)).
+		Where(repo.P("Id", product_table, repo.Equal, productId))
+	query, args := product_builder.Query()
+	products, err := s.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if len(products) == 0 {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+	product_rating := products[0].(*domain.Product).Rating
+	if product_rating == nil {
+		product_rating = &valueobject.RatingAggregateJSON{}
+	}
+
+	builder := s.repo.GetById(&domain.Review{})
+	builder.
+		Select(repo.Col("Id", review_table)).
+		Select(repo.Col("UserId", review_table)).
+		Select(repo.Col("SellerId", review_table)).
+		Select(repo.Col("Rating", review_table)).
+		Select(repo.Col("Comment", review_table)).
+		Select(repo.Col("CreatedAt", review_table)).
+		Select(repo.Col("OrderId", review_table).As("OrderRel$Id")).
+		Select(repo.Col("ProductId", review_table).As("ProductRel$Id")).
+		Where(repo.P("ProductId", review_table, repo.Equal, productId)).
+		OrderBy(repo.Col("Id", review_table), repo.DESC).
+		Limit(uint64(pageSize)).
+		Offset(uint64(page-1) * uint64(pageSize))
+	query, args = builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Review{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	reviews := make([]*domain.Review, 0, len(entities))
+	for _, entity := range entities {
+		reviews = append(reviews, entity.(*domain.Review))
+	}
+	base_response.TransformToStatusOk(&review_dto.ReviewListRes{
+		Reviews:  reviews,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    product_rating.Count,
+		Rating:   product_rating,
+	})
+	return base_response
+}
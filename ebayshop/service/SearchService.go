@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/product"
+	"ebayclone/infrastructure"
+	"ebayclone/log_util"
+	"ebayclone/repo"
+	"ebayclone/search"
+	"ebayclone/valueobject"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SearchSourceIndex         = "index"
+	SearchSourceMysqlFullText = "mysql_fulltext"
+
+	SearchIndexRetryInterval = time.Minute
+)
+
+type SearchService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+
+	index *search.Index
+	ready atomic.Bool // set once the index holds every product
+}
+
+var SearchServiceManager *SearchService
+
+func NewSearchService(debug bool) *SearchService {
+	if SearchServiceManager == nil {
+		SearchServiceManager = &SearchService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "SearchService",
+			index:       search.NewIndex(),
+		}
+	}
+	return SearchServiceManager
+}
+
+// productDocument collects the name and the option values picked by the product and its variants
+func productDocument(product_entity *domain.Product) *search.Document {
+	doc := &search.Document{Id: product_entity.Id, Name: product_entity.Name}
+	if product_entity.ProductTypeRel == nil {
+		return doc
+	}
+	product_type_entity := ProductTypeServiceManager.getProductTypeEntityExistById(product_entity.ProductTypeRel.Id)
+	if product_type_entity == nil {
+		return doc
+	}
+	addOption := func(attributeId valueobject.AttributeId, optionValueId valueobject.OptionValueId) {
+		attribute := product_type_entity.FindAttribute(attributeId)
+		if attribute == nil {
+			return
+		}
+		for _, optionValue := range attribute.OptionValues {
+			if optionValue.Id == optionValueId {
+				doc.Attributes = append(doc.Attributes, fmt.Sprint(optionValue.Value))
+				return
+			}
+		}
+	}
+	if product_entity.Fields != nil {
+		for attributeId, optionValueIds := range *product_entity.Fields {
+			for _, optionValueId := range optionValueIds {
+				addOption(attributeId, optionValueId)
+			}
+		}
+	}
+	for _, variant := range product_entity.ProductVariantRel {
+		if variant.Options == nil {
+			continue
+		}
+		for attributeId, optionValueId := range *variant.Options {
+			addOption(attributeId, optionValueId)
+		}
+	}
+	return doc
+}
+
+// BuildIndexUntilReady retries a failed build until ctx is done, searches keep the MySQL
+// FULLTEXT fallback meanwhile. It is started from main with the process signal context
+func (s *SearchService) BuildIndexUntilReady(ctx context.Context, retryInterval time.Duration) {
+	for {
+		err := s.buildIndex(ctx)
+		if err == nil {
+			return
+		}
+		log_util.Print(s.serviceName, fmt.Sprintf("build search index, retry in %v: %v", retryInterval, err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// buildIndex loads every product with its variants into the index, the index is ready
+// only when every product was loaded
+func (s *SearchService) buildIndex(ctx context.Context) error {
+	product_table := "products"
+	variant_table := "productvariants"
+	builder := s.repo.GetById(&domain.Product{}, preloadVariants)
+	builder.
+		Select(repo.Col("Id", product_table)).
+		Select(repo.Col("Name", product_table)).
+		Select(repo.Col("Fields", product_table)).
+		Select(repo.Col("ProductTypeId", product_table).As("ProductTypeRel$Id")).
+		Select(repo.Col("Id", variant_table, repo.IFNULLINT).As("ProductVariantRel$Id")).
+		Select(repo.Col("Options", variant_table).As("ProductVariantRel$Options")).
+		OrderBy(repo.Col("Id", product_table), repo.ASC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.Product{})
+	if err != nil {
+		return err
+	}
+	for _, entity := range entities {
+		s.index.Upsert(productDocument(entity.(*domain.Product)))
+	}
+	s.ready.Store(true)
+	log_util.PrintFlag(s.serviceName, s.debug, fmt.Sprintf("products indexed [%v]", s.index.Len()))
+	return nil
+}
+
+// IndexProduct reads the product again and replaces its document, a deleted product leaves the index.
+// When the product can not be read the index keeps what it had
+func (s *SearchService) IndexProduct(ctx context.Context, productId uint32) {
+	product_entity, err := ProductServiceManager.findProductById(ctx, productId)
+	if err != nil {
+		log_util.Print(s.serviceName, fmt.Sprintf("index product [%v]: %v", productId, err))
+		return
+	}
+	if product_entity == nil {
+		s.index.Remove(productId)
+		return
+	}
+	s.index.Upsert(productDocument(product_entity))
+}
+
+// searchMysqlFullText ranks names with MATCH ... AGAINST, every word is required and may be a prefix.
+// It goes through RawQuery for the hooks and timeouts of the repo, deleted products are left out
+func (s *SearchService) searchMysqlFullText(ctx context.Context, q string, limit int) ([]*search.Hit, error) {
+	words := search.Tokenize(q)
+	if len(words) == 0 {
+		return []*search.Hit{}, nil
+	}
+	against := make([]string, 0, len(words))
+	for _, word := range words {
+		against = append(against, "+"+word+"*")
+	}
+	query := "SELECT `products`.`Id`, `products`.`Name`, MATCH(`products`.`Name`) AGAINST (? IN BOOLEAN MODE) AS `Score` " +
+		"FROM `products` WHERE MATCH(`products`.`Name`) AGAINST (? IN BOOLEAN MODE) AND `products`.`DeletedAt` IS NULL " +
+		"ORDER BY `Score` DESC, `products`.`Id` DESC LIMIT ?"
+	booleanQuery := strings.Join(against, " ")
+	entities, err := s.repo.RawQuery(ctx, query, []interface{}{booleanQuery, booleanQuery, limit}, &search.Hit{})
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]*search.Hit, 0, len(entities))
+	for _, entity := range entities {
+		hits = append(hits, entity.(*search.Hit))
+	}
+	return hits, nil
+}
+
+func (s *SearchService) SearchProducts(ctx context.Context, req *product.ProductSearchReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if len(search.Tokenize(req.Q)) == 0 {
+		base_response.TransformToBadRequest("q has no word to search")
+		return base_response
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = product.DefaultSearchLimit
+	}
+	if limit < 0 || limit > product.MaxSearchLimit {
+		base_response.TransformToBadRequest(fmt.Sprintf("limit must be between 1 and %v", product.MaxSearchLimit))
+		return base_response
+	}
+
+	source := SearchSourceIndex
+	var hits []*search.Hit
+	if s.ready.Load() {
+		hits = s.index.Search(req.Q, limit)
+	} else if infrastructure.SearchMysqlFullTextFallback {
+		var err error
+		source = SearchSourceMysqlFullText
+		if hits, err = s.searchMysqlFullText(ctx, req.Q, limit); err != nil {
+			base_response.TransformToError(err)
+			return base_response
+		}
+	} else {
+		// an index still loading would answer with missing products
+		base_response.StatusCode = http.StatusServiceUnavailable
+		base_response.ErrCodeString = "search index is loading"
+		return base_response
+	}
+
+	ids := make([]uint32, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.Id)
+	}
+	products, err := ProductServiceManager.findProductsByIds(ctx, ids)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	results := make([]*product.ProductSearchHit, 0, len(hits))
+	for _, hit := range hits {
+		// a product deleted after the index answered is skipped
+		if product_entity, ok := products[hit.Id]; ok {
+			results = append(results, &product.ProductSearchHit{Product: product_entity, Score: hit.Score})
+		}
+	}
+	base_response.TransformToStatusOk(&product.ProductSearchRes{
+		Source:  source,
+		Results: results,
+	})
+	return base_response
+}
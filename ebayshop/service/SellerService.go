@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/seller_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/repo"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const salesReportDayFormat = "2006-01-02"
+
+type SellerService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var SellerServiceManager *SellerService
+
+func NewSellerService(debug bool) *SellerService {
+	if SellerServiceManager == nil {
+		SellerServiceManager = &SellerService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "SellerService",
+		}
+	}
+	return SellerServiceManager
+}
+
+// salesReportRange parses the days of the request, to is the last day included
+func salesReportRange(req *seller_dto.SalesReportReq) (time.Time, time.Time, error) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.To != "" {
+		parsed, err := time.Parse(salesReportDayFormat, req.To)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be formatted as %v", salesReportDayFormat)
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(seller_dto.DefaultSalesReportDays - 1))
+	if req.From != "" {
+		parsed, err := time.Parse(salesReportDayFormat, req.From)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be formatted as %v", salesReportDayFormat)
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from is after to")
+	}
+	if to.Sub(from) >= seller_dto.MaxSalesReportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("range longer than %v days", seller_dto.MaxSalesReportDays)
+	}
+	return from, to, nil
+}
+
+// GetSalesReport sums the paid and completed order lines of the seller per product
+// type, day and currency, days are taken from the order in UTC
+func (s *SellerService) GetSalesReport(ctx context.Context, sellerId uint32, req *seller_dto.SalesReportReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if sellerId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	from, to, err := salesReportRange(req)
+	if err != nil {
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+
+	order_line_table, order_table, product_table := "orderlines", "orders", "products"
+	product_type := repo.Col("ProductTypeId", product_table).As("ProductTypeId")
+	day := repo.Col("CreatedAt", order_table).As("Day")
+	currency := repo.Col("Currency", order_line_table)
+	builder, err := repo.NewQueryRel(
+		repo.NewRel(order_line_table, "OrderId", order_table, "Id").
+			Where(repo.P("Status", order_table, repo.In, []string{string(domain.OrderPaid), string(domain.OrderCompleted)})).
+			Where(repo.P("CreatedAt", order_table, repo.Between, from, to.AddDate(0, 0, 1).Add(-time.Second))),
+		repo.NewRel(order_line_table, "ProductId", product_table, "Id").
+			Where(repo.P("SellerId", product_table, repo.Equal, sellerId)),
+	).Builder()
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	builder.
+		Select(product_type).
+		SelectDate(day, repo.NewDateTimeConverter("", "%Y-%m-%d")).
+		Select(currency).
+		Select(repo.CountDistinct(repo.Col("Id", order_table)).As("Orders")).
+		Select(repo.Sum(repo.Col("Quantity", order_line_table)).As("Quantity")).
+		Select(repo.Sum(repo.Col("LineTotalAmount", order_line_table)).As("Revenue")).
+		GroupBy(product_type, day, currency).
+		OrderBy(repo.Col("Day", ""), repo.ASC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &seller_dto.SalesReportRow{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	rows := make([]*seller_dto.SalesReportRow, 0, len(entities))
+	for _, entity := range entities {
+		rows = append(rows, entity.(*seller_dto.SalesReportRow))
+	}
+	base_response.TransformToStatusOk(&seller_dto.SalesReportRes{
+		From: from.Format(salesReportDayFormat),
+		To:   to.Format(salesReportDayFormat),
+		Rows: rows,
+	})
+	return base_response
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"ebayclone/changeset"
+	"ebayclone/domain"
+	"ebayclone/dto"
+	"ebayclone/dto/watchlist_dto"
+	"ebayclone/infrastructure"
+	"ebayclone/repo"
+	"ebayclone/valueobject"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	watchlist_item_table = "watchlistitems"
+	saved_search_table   = "savedsearchs"
+)
+
+type WatchlistService struct {
+	repo        *repo.Repo
+	debug       bool
+	serviceName string
+}
+
+var WatchlistServiceManager *WatchlistService
+
+func NewWatchlistService(debug bool) *WatchlistService {
+	if WatchlistServiceManager == nil {
+		WatchlistServiceManager = &WatchlistService{
+			repo:        repo.NewRepo(infrastructure.MysqlConfig, debug, infrastructure.MysqlPoolConfig),
+			debug:       debug,
+			serviceName: "WatchlistService",
+		}
+	}
+	return WatchlistServiceManager
+}
+
+// preloadWatchedProduct joins the product of a watchlist item
+func preloadWatchedProduct() (to interface{}, fk string, pk string, inverse bool, type_join repo.TYPEJOIN) {
+	return &domain.Product{}, "ProductId", "Id", true, repo.INNERJOIN
+}
+
+func (s *WatchlistService) listWatchlist(ctx context.Context, userId uint32) ([]*domain.WatchlistItem, error) {
+	product_table := "products"
+	builder := s.repo.GetById(&domain.WatchlistItem{}, preloadWatchedProduct)
+	builder.
+		Select(repo.Col("Id", watchlist_item_table)).
+		Select(repo.Col("UserId", watchlist_item_table)).
+		Select(repo.Col("CreatedAt", watchlist_item_table)).
+		Select(repo.Col("Id", product_table).As("ProductRel$Id")).
+		Select(repo.Col("Name", product_table).As("ProductRel$Name")).
+		Select(repo.Col("PriceAmount", product_table).As("ProductRel$PriceAmount")).
+		Select(repo.Col("PriceCurrency", product_table).As("ProductRel$PriceCurrency")).
+		Where(repo.P("UserId", watchlist_item_table, repo.Equal, userId)).
+		OrderBy(repo.Col("Id", watchlist_item_table), repo.DESC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.WatchlistItem{})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*domain.WatchlistItem, 0, len(entities))
+	for _, entity := range entities {
+		items = append(items, entity.(*domain.WatchlistItem))
+	}
+	return items, nil
+}
+
+func (s *WatchlistService) watchlistResponseOf(ctx context.Context, userId uint32, base_response *dto.BaseMessageResponse) *dto.BaseMessageResponse {
+	items, err := s.listWatchlist(ctx, userId)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&watchlist_dto.WatchlistRes{
+		Items: items,
+	})
+	return base_response
+}
+
+func (s *WatchlistService) GetWatchlist(ctx context.Context, userId uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	return s.watchlistResponseOf(ctx, userId, base_response)
+}
+
+// AddToWatchlist watches a product, watching it twice is not an error
+func (s *WatchlistService) AddToWatchlist(ctx context.Context, userId uint32, req *watchlist_dto.WatchlistAddReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	product_entity, err := ProductServiceManager.findProductById(ctx, req.ProductId)
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if product_entity == nil {
+		base_response.TransformToNotFoundEntity("Product")
+		return base_response
+	}
+	item_changeset := changeset.CastValues(&domain.WatchlistItem{}, map[string]any{
+		"UserId":     userId,
+		"CreatedAt":  time.Now().UTC(),
+		"ProductRel": &domain.Product{Id: req.ProductId},
+	})
+	if err = s.repo.Save(ctx, item_changeset); err != nil && repo.GetErrCode(err) != repo.ErrCodeDuplicate {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	return s.watchlistResponseOf(ctx, userId, base_response)
+}
+
+func (s *WatchlistService) RemoveFromWatchlist(ctx context.Context, userId uint32, req *watchlist_dto.WatchlistRemoveReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	deleted, err := s.repo.DeleteWhere(ctx, &domain.WatchlistItem{}, repo.And(
+		repo.P("UserId", watchlist_item_table, repo.Equal, userId),
+		repo.P("ProductId", watchlist_item_table, repo.Equal, req.ProductId),
+	))
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if deleted == 0 {
+		base_response.TransformToNotFoundEntity("WatchlistItem")
+		return base_response
+	}
+	return s.watchlistResponseOf(ctx, userId, base_response)
+}
+
+func savedSearchBuilder(r *repo.Repo) *repo.QueryBuilder {
+	builder := r.GetById(&domain.SavedSearch{})
+	builder.
+		Select(repo.Col("Id", saved_search_table)).
+		Select(repo.Col("UserId", saved_search_table)).
+		Select(repo.Col("Name", saved_search_table)).
+		Select(repo.Col("Filter", saved_search_table)).
+		Select(repo.Col("CreatedAt", saved_search_table)).
+		Select(repo.Col("ProductTypeId", saved_search_table).As("ProductTypeRel$Id"))
+	return builder
+}
+
+func toSavedSearches(entities []interface{}) []*domain.SavedSearch {
+	searches := make([]*domain.SavedSearch, 0, len(entities))
+	for _, entity := range entities {
+		searches = append(searches, entity.(*domain.SavedSearch))
+	}
+	return searches
+}
+
+// findSavedSearchesOfProductType is used by the matcher, only searches of the type can match
+func (s *WatchlistService) findSavedSearchesOfProductType(ctx context.Context, productTypeId uint32) ([]*domain.SavedSearch, error) {
+	builder := savedSearchBuilder(s.repo).
+		Where(repo.P("ProductTypeId", saved_search_table, repo.Equal, productTypeId)).
+		OrderBy(repo.Col("Id", saved_search_table), repo.ASC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.SavedSearch{})
+	if err != nil {
+		return nil, err
+	}
+	return toSavedSearches(entities), nil
+}
+
+func (s *WatchlistService) ListSavedSearches(ctx context.Context, userId uint32) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	builder := savedSearchBuilder(s.repo).
+		Where(repo.P("UserId", saved_search_table, repo.Equal, userId)).
+		OrderBy(repo.Col("Id", saved_search_table), repo.DESC)
+	query, args := builder.Query()
+	entities, err := s.repo.RawQuery(ctx, query, args, &domain.SavedSearch{})
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&watchlist_dto.SavedSearchListRes{
+		SavedSearches: toSavedSearches(entities),
+	})
+	return base_response
+}
+
+func (s *WatchlistService) CreateSavedSearch(ctx context.Context, userId uint32, req *watchlist_dto.SavedSearchCreateReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	product_type_entity := ProductTypeServiceManager.getProductTypeEntityExistById(req.ProductTypeId)
+	if product_type_entity == nil {
+		base_response.TransformToNotFoundEntity("ProductType")
+		return base_response
+	}
+	filter := valueobject.FieldsJSON{}
+	if req.Filter != nil {
+		filter = *req.Filter
+	}
+	if err := product_type_entity.ValidateFilter(filter); err != nil {
+		if errors.Is(err, domain.ErrNotFoundAttributeId) || errors.Is(err, domain.ErrNotFoundOptionValueId) {
+			base_response.TransformToNotFoundEntity(err.Error())
+			return base_response
+		}
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	name := req.Name
+	if name == "" {
+		name = product_type_entity.Name
+	}
+	saved_search_entity := &domain.SavedSearch{}
+	saved_search_changeset := changeset.CastValues(saved_search_entity, map[string]any{
+		"UserId":         userId,
+		"Name":           name,
+		"Filter":         &filter,
+		"CreatedAt":      time.Now().UTC(),
+		"ProductTypeRel": &domain.ProductType{Id: req.ProductTypeId},
+	})
+	if err := saved_search_changeset.ValidValues(); err != nil {
+		base_response.TransformToBadRequest(err.Error())
+		return base_response
+	}
+	if err := s.repo.Save(ctx, saved_search_changeset); err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	base_response.TransformToStatusOk(&watchlist_dto.SavedSearchCreateRes{
+		Id: saved_search_entity.Id,
+	})
+	return base_response
+}
+
+func (s *WatchlistService) DeleteSavedSearch(ctx context.Context, userId uint32, req *watchlist_dto.SavedSearchDeleteReq) *dto.BaseMessageResponse {
+	base_response := &dto.BaseMessageResponse{
+		StatusCode:    http.StatusInternalServerError,
+		ErrCodeString: "",
+		ReponseObject: nil,
+	}
+	if userId == 0 {
+		base_response.StatusCode = http.StatusUnauthorized
+		base_response.ErrCodeString = "login required"
+		return base_response
+	}
+	// the user id in the predicate keeps users from deleting searches of others
+	deleted, err := s.repo.DeleteWhere(ctx, &domain.SavedSearch{}, repo.And(
+		repo.P("Id", saved_search_table, repo.Equal, req.Id),
+		repo.P("UserId", saved_search_table, repo.Equal, userId),
+	))
+	if err != nil {
+		base_response.TransformToError(err)
+		return base_response
+	}
+	if deleted == 0 {
+		base_response.TransformToNotFoundEntity("SavedSearch")
+		return base_response
+	}
+	base_response.TransformToStatusOk(&watchlist_dto.SavedSearchCreateRes{
+		Id: req.Id,
+	})
+	return base_response
+}
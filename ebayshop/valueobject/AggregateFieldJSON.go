@@ -1,8 +1,59 @@
 package valueobject
 
+import "encoding/json"
+
 type AttributeId uint32
 type OptionValueId uint32
-type FieldsJSON map[AttributeId]OptionValueId
+
+// OptionValueIds accept a single id or a list of ids in json,
+// old clients still send one option id per attribute
+type OptionValueIds []OptionValueId
+
+func (o *OptionValueIds) UnmarshalJSON(data []byte) error {
+	var single OptionValueId
+	if err := json.Unmarshal(data, &single); err == nil {
+		*o = OptionValueIds{single}
+		return nil
+	}
+	var multiple []OptionValueId
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*o = multiple
+	return nil
+}
+
+type FieldsJSON map[AttributeId]OptionValueIds
 type AggregateFieldJSON struct {
 	Fields map[AttributeId]map[OptionValueId]int `json:"fields"`
 }
+
+func (a *AggregateFieldJSON) Clone() *AggregateFieldJSON {
+	if a == nil {
+		return nil
+	}
+	cloned := &AggregateFieldJSON{
+		Fields: make(map[AttributeId]map[OptionValueId]int, len(a.Fields)),
+	}
+	for attributeId, optionValues := range a.Fields {
+		cloned.Fields[attributeId] = make(map[OptionValueId]int, len(optionValues))
+		for optionValueId, count := range optionValues {
+			cloned.Fields[attributeId][optionValueId] = count
+		}
+	}
+	return cloned
+}
+
+func (a *AggregateFieldJSON) Increment(attributeId AttributeId, optionValueId OptionValueId, delta int) {
+	if a.Fields == nil {
+		a.Fields = map[AttributeId]map[OptionValueId]int{}
+	}
+	if _, ok := a.Fields[attributeId]; !ok {
+		a.Fields[attributeId] = map[OptionValueId]int{}
+	}
+	a.Fields[attributeId][optionValueId] += delta
+}
+
+// VariantOptionsJSON is the option selection of one variant, a variant is one concrete
+// combination so it has exactly one option per attribute
+type VariantOptionsJSON map[AttributeId]OptionValueId
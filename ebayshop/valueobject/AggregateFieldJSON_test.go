@@ -0,0 +1,89 @@
+package valueobject
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOptionValueIdsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    OptionValueIds
+		wantErr bool
+	}{
+		{data: `3`, want: OptionValueIds{3}},
+		{data: `[3, 4]`, want: OptionValueIds{3, 4}},
+		{data: `[]`, want: OptionValueIds{}},
+		{data: `"3"`, wantErr: true},
+		{data: `-1`, wantErr: true},
+		{data: `[3, "4"]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var got OptionValueIds
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// old clients send one id per attribute, new ones a list
+	var fields FieldsJSON
+	if err := json.Unmarshal([]byte(`{"10": 1, "11": [3, 4]}`), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if want := (FieldsJSON{10: {1}, 11: {3, 4}}); !reflect.DeepEqual(fields, want) {
+		t.Errorf("FieldsJSON = %v, want %v", fields, want)
+	}
+}
+
+func TestAggregateFieldIncrement(t *testing.T) {
+	tests := []struct {
+		name  string
+		start *AggregateFieldJSON
+		want  map[AttributeId]map[OptionValueId]int
+	}{
+		{name: "no fields", start: &AggregateFieldJSON{}, want: map[AttributeId]map[OptionValueId]int{1: {2: 1}}},
+		{name: "new attribute", start: &AggregateFieldJSON{Fields: map[AttributeId]map[OptionValueId]int{5: {1: 3}}}, want: map[AttributeId]map[OptionValueId]int{5: {1: 3}, 1: {2: 1}}},
+		{name: "existing option", start: &AggregateFieldJSON{Fields: map[AttributeId]map[OptionValueId]int{1: {2: 4}}}, want: map[AttributeId]map[OptionValueId]int{1: {2: 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.start.Increment(1, 2, 1)
+			if !reflect.DeepEqual(tt.start.Fields, tt.want) {
+				t.Errorf("Increment() = %v, want %v", tt.start.Fields, tt.want)
+			}
+		})
+	}
+
+	aggregate := &AggregateFieldJSON{}
+	aggregate.Increment(1, 2, 3)
+	aggregate.Increment(1, 2, -1)
+	if got := aggregate.Fields[1][2]; got != 2 {
+		t.Errorf("Increment() with a negative delta = %v, want 2", got)
+	}
+}
+
+func TestAggregateFieldClone(t *testing.T) {
+	var empty *AggregateFieldJSON
+	if cloned := empty.Clone(); cloned != nil {
+		t.Errorf("Clone() of nil = %v, want nil", cloned)
+	}
+
+	original := &AggregateFieldJSON{Fields: map[AttributeId]map[OptionValueId]int{1: {2: 4}}}
+	cloned := original.Clone()
+	if !reflect.DeepEqual(cloned, original) {
+		t.Fatalf("Clone() = %v, want %v", cloned, original)
+	}
+	// the cached product type is cloned before counting, its maps must stay untouched
+	cloned.Increment(1, 2, 1)
+	cloned.Increment(3, 1, 1)
+	if want := map[AttributeId]map[OptionValueId]int{1: {2: 4}}; !reflect.DeepEqual(original.Fields, want) {
+		t.Errorf("original changed by the clone: %v", original.Fields)
+	}
+}
@@ -9,6 +9,9 @@ type OptionValueRes struct {
 type OneAttributeObjectRes struct {
 	Id           AttributeId       `json:"id"`
 	Name         string            `json:"name"`
+	DisplayOrder int               `json:"display_order"`
+	Required     bool              `json:"required"`
+	MultiSelect  bool              `json:"multi_select"`
 	OptionValues []*OptionValueRes `json:"option_values"`
 }
 type AttributesObjectRes struct {
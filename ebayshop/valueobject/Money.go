@@ -0,0 +1,107 @@
+package valueobject
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MaxMoneyAmount bounds stored amounts so sums of a few thousand lines can not overflow int64
+const MaxMoneyAmount int64 = 1 << 50
+
+var (
+	ErrCurrencyMismatch    = errors.New("money currency mismatch")
+	ErrMoneyOverflow       = errors.New("money amount overflow")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+)
+
+// minor unit exponent of each supported ISO 4217 currency
+var supportedCurrencies = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"VND": 0,
+}
+
+func IsSupportedCurrency(code string) bool {
+	_, ok := supportedCurrencies[code]
+	return ok
+}
+
+func SupportedCurrencyCodes() []string {
+	codes := make([]string, 0, len(supportedCurrencies))
+	for code := range supportedCurrencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Money is an amount in minor units (cents for USD) of one ISO currency,
+// never use floats to compute prices or totals
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func NewMoney(amount int64, currency string) (Money, error) {
+	if !IsSupportedCurrency(currency) {
+		return Money{}, fmt.Errorf("%w [%v]", ErrUnsupportedCurrency, currency)
+	}
+	if amount < 0 || amount > MaxMoneyAmount {
+		return Money{}, fmt.Errorf("%w [%v]", ErrMoneyOverflow, amount)
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w [%v, %v]", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	if (other.Amount > 0 && m.Amount > math.MaxInt64-other.Amount) ||
+		(other.Amount < 0 && m.Amount < math.MinInt64-other.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) MulQuantity(quantity uint32) (Money, error) {
+	if quantity == 0 || m.Amount == 0 {
+		return Money{Amount: 0, Currency: m.Currency}, nil
+	}
+	q := int64(quantity)
+	if m.Amount > math.MaxInt64/q || m.Amount < math.MinInt64/q {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: m.Amount * q, Currency: m.Currency}, nil
+}
+
+// SumMoney adds amounts of the same currency, an empty list gives zero of the given currency
+func SumMoney(currency string, items ...Money) (Money, error) {
+	total := Money{Amount: 0, Currency: currency}
+	for _, item := range items {
+		var err error
+		total, err = total.Add(item)
+		if err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func (m Money) String() string {
+	exponent := supportedCurrencies[m.Currency]
+	if exponent == 0 {
+		return fmt.Sprintf("%d %v", m.Amount, m.Currency)
+	}
+	unit := int64(math.Pow10(exponent))
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%v%d.%0*d %v", sign, amount/unit, exponent, amount%unit, m.Currency)
+}
@@ -0,0 +1,123 @@
+package valueobject
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		currency string
+		wantErr  error
+	}{
+		{name: "zero", amount: 0, currency: "USD"},
+		{name: "max", amount: MaxMoneyAmount, currency: "JPY"},
+		{name: "over max", amount: MaxMoneyAmount + 1, currency: "USD", wantErr: ErrMoneyOverflow},
+		{name: "negative", amount: -1, currency: "USD", wantErr: ErrMoneyOverflow},
+		{name: "unknown currency", amount: 100, currency: "XYZ", wantErr: ErrUnsupportedCurrency},
+		{name: "lower case currency", amount: 100, currency: "usd", wantErr: ErrUnsupportedCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewMoney(tt.amount, tt.currency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewMoney() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Amount != tt.amount || got.Currency != tt.currency) {
+				t.Errorf("NewMoney() = %v", got)
+			}
+		})
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    Money
+		wantErr error
+	}{
+		{name: "same currency", a: Money{150, "USD"}, b: Money{250, "USD"}, want: Money{400, "USD"}},
+		{name: "negative", a: Money{150, "USD"}, b: Money{-200, "USD"}, want: Money{-50, "USD"}},
+		{name: "currency mismatch", a: Money{150, "USD"}, b: Money{150, "EUR"}, wantErr: ErrCurrencyMismatch},
+		{name: "overflow", a: Money{math.MaxInt64, "USD"}, b: Money{1, "USD"}, wantErr: ErrMoneyOverflow},
+		{name: "underflow", a: Money{math.MinInt64, "USD"}, b: Money{-1, "USD"}, wantErr: ErrMoneyOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Add(tt.b)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("Add() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMoneyMulQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		money    Money
+		quantity uint32
+		want     Money
+		wantErr  error
+	}{
+		{name: "quantity", money: Money{199, "USD"}, quantity: 3, want: Money{597, "USD"}},
+		{name: "zero quantity", money: Money{199, "USD"}, quantity: 0, want: Money{0, "USD"}},
+		{name: "zero amount", money: Money{0, "EUR"}, quantity: 7, want: Money{0, "EUR"}},
+		{name: "overflow", money: Money{math.MaxInt64 / 2, "USD"}, quantity: 3, wantErr: ErrMoneyOverflow},
+		{name: "max amount and quantity", money: Money{MaxMoneyAmount, "USD"}, quantity: math.MaxUint32, wantErr: ErrMoneyOverflow},
+		{name: "max quantity", money: Money{1000, "USD"}, quantity: math.MaxUint32, want: Money{1000 * math.MaxUint32, "USD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.money.MulQuantity(tt.quantity)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("MulQuantity() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSumMoney(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []Money
+		want    Money
+		wantErr error
+	}{
+		{name: "empty", want: Money{0, "GBP"}},
+		{name: "sum", items: []Money{{100, "GBP"}, {250, "GBP"}, {5, "GBP"}}, want: Money{355, "GBP"}},
+		{name: "other currency", items: []Money{{100, "GBP"}, {100, "USD"}}, wantErr: ErrCurrencyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SumMoney("GBP", tt.items...)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("SumMoney() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: Money{123456, "USD"}, want: "1234.56 USD"},
+		{money: Money{5, "EUR"}, want: "0.05 EUR"},
+		{money: Money{-250, "USD"}, want: "-2.50 USD"},
+		{money: Money{1500, "JPY"}, want: "1500 JPY"},
+		{money: Money{0, "VND"}, want: "0 VND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.money.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package valueobject
+
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// RatingAggregateJSON is kept next to the rated entity so reads never scan the reviews,
+// Histogram[0] counts 1 star ratings and Histogram[4] counts 5 star ratings
+type RatingAggregateJSON struct {
+	Count     int            `json:"count"`
+	Sum       int            `json:"sum"`
+	Average   float64        `json:"average"`
+	Histogram [MaxRating]int `json:"histogram"`
+}
+
+func (r *RatingAggregateJSON) Clone() *RatingAggregateJSON {
+	if r == nil {
+		return &RatingAggregateJSON{}
+	}
+	cloned := *r
+	return &cloned
+}
+
+// Add counts one rating, ratings outside MinRating..MaxRating are ignored
+func (r *RatingAggregateJSON) Add(rating uint8) {
+	if rating < MinRating || rating > MaxRating {
+		return
+	}
+	r.Count++
+	r.Sum += int(rating)
+	r.Histogram[rating-MinRating]++
+	r.Average = float64(r.Sum) / float64(r.Count)
+}
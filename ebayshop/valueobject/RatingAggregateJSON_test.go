@@ -0,0 +1,47 @@
+package valueobject
+
+import "testing"
+
+func TestRatingAggregateAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratings []uint8
+		want    RatingAggregateJSON
+	}{
+		{name: "none", want: RatingAggregateJSON{}},
+		{name: "one", ratings: []uint8{4}, want: RatingAggregateJSON{Count: 1, Sum: 4, Average: 4, Histogram: [MaxRating]int{0, 0, 0, 1, 0}}},
+		{name: "bounds", ratings: []uint8{1, 5}, want: RatingAggregateJSON{Count: 2, Sum: 6, Average: 3, Histogram: [MaxRating]int{1, 0, 0, 0, 1}}},
+		{name: "average is not rounded", ratings: []uint8{5, 4, 4}, want: RatingAggregateJSON{Count: 3, Sum: 13, Average: 13.0 / 3, Histogram: [MaxRating]int{0, 0, 0, 2, 1}}},
+		{name: "out of range ignored", ratings: []uint8{0, 6, 255, 3}, want: RatingAggregateJSON{Count: 1, Sum: 3, Average: 3, Histogram: [MaxRating]int{0, 0, 1, 0, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &RatingAggregateJSON{}
+			for _, rating := range tt.ratings {
+				got.Add(rating)
+			}
+			if *got != tt.want {
+				t.Errorf("Add() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRatingAggregateClone(t *testing.T) {
+	var empty *RatingAggregateJSON
+	if cloned := empty.Clone(); cloned == nil || *cloned != (RatingAggregateJSON{}) {
+		t.Errorf("Clone() of nil = %+v, want an empty aggregate", cloned)
+	}
+
+	original := &RatingAggregateJSON{}
+	original.Add(5)
+	cloned := original.Clone()
+	if cloned == original || *cloned != *original {
+		t.Fatalf("Clone() = %p %+v, want a copy of %p %+v", cloned, *cloned, original, *original)
+	}
+	// the histogram is an array, adding to the clone must not change the original
+	cloned.Add(1)
+	if original.Count != 1 || original.Histogram[0] != 0 {
+		t.Errorf("original changed by the clone: %+v", *original)
+	}
+}